@@ -0,0 +1,163 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"reflect"
+	"sort"
+)
+
+// MarshalArgs converts the current values of the options of the group back
+// into command line arguments, which result in the same values when parsed.
+// Only options which were explicitly set (see Option.IsSet) or whose value
+// differs from their default value (given by the default tag, or the zero
+// value) are included, in the order of the options of the group. Arguments are written in the canonical form --name=value (or
+// -n=value for options without a long name). Function options cannot be
+// marshalled and are omitted. This is useful to re-execute a program or to
+// spawn workers with the same options.
+func (g *Group) MarshalArgs() []string {
+	var ret []string
+
+	for _, option := range g.Options {
+		if option.isFunc() || option.sharesField() || (!option.IsSet() && option.isDefault()) {
+			continue
+		}
+
+		if option.counter != 0 {
+			ret = append(ret, g.marshalCounter(option)...)
+			continue
+		}
+
+		ret = append(ret, option.marshalArgs()...)
+	}
+
+	return ret
+}
+
+// MarshalArgs converts the current values of the options of all the groups
+// of the parser back into command line arguments (see Group.MarshalArgs).
+func (p *Parser) MarshalArgs() []string {
+	var ret []string
+
+	for _, grp := range p.groups() {
+		ret = append(ret, grp.MarshalArgs()...)
+	}
+
+	return ret
+}
+
+// isDefault returns whether the option has its default value, i.e. the value
+// given by its default tag or otherwise the zero value. Empty and nil slices
+// and maps are considered equal.
+func (option *Option) isDefault() bool {
+	def := reflect.New(option.value.Type()).Elem()
+
+	if option.hasDefault() {
+		if err := convert(option.Default, def, option.options); err != nil {
+			return false
+		}
+	}
+
+	switch option.value.Kind() {
+	case reflect.Slice, reflect.Map:
+		if option.value.Len() == 0 && def.Len() == 0 {
+			return true
+		}
+	}
+
+	return reflect.DeepEqual(option.value.Interface(), def.Interface())
+}
+
+// marshalCounter returns the flags of a counter option which change its
+// default value into its current value: the flag of the counter option to
+// increment the value, or the flag of its decrement option.
+func (g *Group) marshalCounter(option *Option) []string {
+	def := reflect.New(option.value.Type()).Elem()
+
+	if option.hasDefault() {
+		convert(option.Default, def, option.options)
+	}
+
+	n := option.value.Int() - def.Int()
+	flag := option.synopsis()
+
+	if n < 0 {
+		n = -n
+		flag = ""
+
+		for _, other := range g.Options {
+			if other.sharesField() && other.value.UnsafeAddr() == option.value.UnsafeAddr() {
+				flag = other.synopsis()
+			}
+		}
+
+		// Without a decrement option, the value cannot be given on the
+		// command line
+		if flag == "" {
+			return nil
+		}
+	}
+
+	var ret []string
+
+	for ; n > 0; n-- {
+		ret = append(ret, flag)
+	}
+
+	return ret
+}
+
+func (option *Option) marshalArgs() []string {
+	flag := option.synopsis()
+	val := option.value
+
+	if option.isBool() {
+		switch val.Kind() {
+		case reflect.Bool:
+			if val.Bool() {
+				return []string{flag}
+			}
+		case reflect.Slice:
+			var ret []string
+
+			for i := 0; i < val.Len(); i++ {
+				if val.Index(i).Bool() {
+					ret = append(ret, flag)
+				}
+			}
+
+			return ret
+		default:
+			return []string{flag}
+		}
+
+		return nil
+	}
+
+	var values []string
+
+	switch val.Kind() {
+	case reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			values = append(values, convertToString(val.Index(i), option.options))
+		}
+	case reflect.Map:
+		for _, key := range val.MapKeys() {
+			values = append(values, convertToString(key, option.options)+":"+convertToString(val.MapIndex(key), option.options))
+		}
+
+		sort.Strings(values)
+	default:
+		values = append(values, convertToString(val, option.options))
+	}
+
+	ret := make([]string, len(values))
+
+	for i, value := range values {
+		ret[i] = flag + "=" + value
+	}
+
+	return ret
+}
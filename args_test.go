@@ -0,0 +1,41 @@
+package flags
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalArgs(t *testing.T) {
+	var opts struct {
+		Verbose []bool         `short:"v"`
+		Port    int            `long:"port" default:"80"`
+		Name    string         `long:"name"`
+		Hosts   []string       `long:"host"`
+		Labels  map[string]int `long:"label"`
+		Debug   bool           `long:"debug"`
+		Timeout time.Duration  `long:"timeout"`
+		Call    func()         `long:"call"`
+	}
+
+	opts.Call = func() {}
+
+	newParser := func() *Parser {
+		return NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	}
+
+	args := []string{"-vv", "--host", "a", "--label", "y:2", "--host", "-b", "--label", "x:1", "--name", "a b", "--call"}
+
+	if _, err := newParser().ParseArgs(args); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	opts.Timeout = time.Second
+
+	marshalled := newParser().MarshalArgs()
+	expected := "-v -v --name=a b --host=a --host=-b --label=x:1 --label=y:2 --timeout=1s"
+
+	if strings.Join(marshalled, " ") != expected {
+		t.Errorf("Expected %q but got %q", expected, marshalled)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Auto is the type of tri-state options whose default depends on whether
+// the output is a terminal, such as --color=auto or --progress=auto. The
+// arguments auto, always and never are accepted, as well as the usual bool
+// values (e.g. yes and no) for always and never. The zero value is
+// AutoDetect. Use Parser.Enabled to resolve the value:
+//
+//	type Options struct {
+//		Color flags.Auto `long:"color" description:"Colorize the output (auto, always or never)"`
+//	}
+//
+//	color := parser.Enabled(opts.Color, os.Stdout)
+type Auto int
+
+const (
+	// Enabled when the output is a terminal
+	AutoDetect Auto = iota
+
+	// Always enabled
+	AutoAlways
+
+	// Never enabled
+	AutoNever
+)
+
+// Get the argument representing the value.
+func (a Auto) String() string {
+	switch a {
+	case AutoAlways:
+		return "always"
+	case AutoNever:
+		return "never"
+	}
+
+	return "auto"
+}
+
+func parseAuto(s string) (interface{}, error) {
+	switch strings.ToLower(s) {
+	case "auto", "":
+		return AutoDetect, nil
+	case "always", "true", "yes", "on", "1":
+		return AutoAlways, nil
+	case "never", "false", "no", "off", "0":
+		return AutoNever, nil
+	}
+
+	return nil, fmt.Errorf("expected auto, always or never")
+}
+
+func init() {
+	RegisterConverter(reflect.TypeOf(AutoDetect), Converter{
+		Parse: parseAuto,
+	})
+}
+
+// Enabled resolves a tri-state option for the given output. AutoDetect is
+// enabled when the output is a terminal, according to the IsTerminal
+// function of the parser.
+func (p *Parser) Enabled(value Auto, output *os.File) bool {
+	switch value {
+	case AutoAlways:
+		return true
+	case AutoNever:
+		return false
+	}
+
+	if p.IsTerminal != nil {
+		return p.IsTerminal(output)
+	}
+
+	return isTerminal(output)
+}
+
+// isTerminal returns whether the file is a terminal (a character device).
+func isTerminal(file *os.File) bool {
+	if file == nil {
+		return false
+	}
+
+	info, err := file.Stat()
+	return err == nil && (info.Mode()&os.ModeCharDevice) != 0
+}
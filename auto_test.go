@@ -0,0 +1,62 @@
+package flags
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAuto(t *testing.T) {
+	var opts struct {
+		Color    Auto `long:"color" description:"Colorize"`
+		Progress Auto `long:"progress" default:"never"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+
+	if !strings.Contains(help.String(), "Colorize (auto)") {
+		t.Errorf("Expected the default to be shown as auto but got:\n%s", help.String())
+	}
+
+	if _, err := p.ParseArgs([]string{"--color=always"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Color != AutoAlways || opts.Progress != AutoNever {
+		t.Errorf("Expected always and never but got %s and %s", opts.Color, opts.Progress)
+	}
+
+	terminal := false
+	p.IsTerminal = func(file *os.File) bool {
+		return terminal
+	}
+
+	tests := []struct {
+		value    Auto
+		terminal bool
+		enabled  bool
+	}{
+		{AutoDetect, false, false},
+		{AutoDetect, true, true},
+		{AutoAlways, false, true},
+		{AutoNever, true, false},
+	}
+
+	for _, test := range tests {
+		terminal = test.terminal
+
+		if enabled := p.Enabled(test.value, os.Stdout); enabled != test.enabled {
+			t.Errorf("Expected %s on a terminal (%v) to be %v", test.value, test.terminal, test.enabled)
+		}
+	}
+
+	_, err := p.ParseArgs([]string{"--color=sometimes"})
+
+	if err == nil || err.Error() != "invalid argument for flag `--color': expected auto, always or never" {
+		t.Errorf("Expected an invalid argument error but got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OptionDescription is the description of an option in a catalog (see
+// DescriptionCatalog).
+type OptionDescription struct {
+	Description     string `json:"description,omitempty"`
+	LongDescription string `json:"longDescription,omitempty"`
+}
+
+// DescriptionCatalog maps the long names of options, or the short names of
+// options without a long name, to their descriptions (see
+// Parser.SetDescriptions). Catalogs can be decoded from JSON files, for
+// example to load translated help messages at runtime:
+//
+//	var catalog flags.DescriptionCatalog
+//
+//	if err := json.Unmarshal(data, &catalog); err != nil {
+//		return err
+//	}
+//
+//	parser.SetDescriptions(catalog)
+type DescriptionCatalog map[string]OptionDescription
+
+// SetDescriptions overrides the descriptions of the options of the parser,
+// including the builtin help option, with those of the catalog. This allows
+// the descriptions of generated structs to be given separately, and help
+// messages to be translated. Empty descriptions in the catalog do not
+// override the descriptions of options. The descriptions of the options
+// which are in the catalog are set even when the catalog refers to unknown
+// options, which are reported by an error of type ErrUnknownFlag. ErrFrozen
+// is returned for a frozen parser.
+func (p *Parser) SetDescriptions(catalog DescriptionCatalog) error {
+	if p.frozen {
+		return ErrFrozen
+	}
+
+	p.addHelpGroup()
+
+	used := make(map[string]bool)
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			d, ok := catalog[option.configKey()]
+
+			if !ok {
+				continue
+			}
+
+			used[option.configKey()] = true
+
+			if d.Description != "" {
+				option.Description = d.Description
+			}
+
+			if d.LongDescription != "" {
+				option.LongDescription = d.LongDescription
+			}
+		}
+	}
+
+	var unknown []string
+
+	for name := range catalog {
+		if !used[name] {
+			unknown = append(unknown, "`"+name+"'")
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Strings(unknown)
+
+	if len(unknown) == 1 {
+		return newError(ErrUnknownFlag, fmt.Sprintf("unknown flag %s in description catalog", unknown[0]))
+	}
+
+	return newError(ErrUnknownFlag,
+		fmt.Sprintf("unknown flags %s in description catalog", strings.Join(unknown, ", ")))
+}
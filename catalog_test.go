@@ -0,0 +1,58 @@
+package flags
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSetDescriptions(t *testing.T) {
+	var opts struct {
+		Name  string `long:"name" description:"Name"`
+		Level int    `short:"l" description:"Level"`
+		Port  int    `long:"port" description:"Port"`
+	}
+
+	p := NewNamedParser("test", HelpFlag, NewGroup("Application Options", &opts))
+
+	var catalog DescriptionCatalog
+
+	data := `{
+		"name": {"description": "Nom", "longDescription": "Le nom de l'utilisateur."},
+		"l": {"description": "Niveau"},
+		"port": {},
+		"help": {"description": "Afficher l'aide"},
+		"host": {"description": "Hôte"}
+	}`
+
+	if err := json.Unmarshal([]byte(data), &catalog); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	err := p.SetDescriptions(catalog)
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrUnknownFlag || e.Message != "unknown flag `host' in description catalog" {
+		t.Errorf("Expected an error for the unknown flag but got %v", err)
+	}
+
+	expected := map[string]string{"name": "Nom", "port": "Port", "help": "Afficher l'aide"}
+
+	for name, description := range expected {
+		if option := p.FindOptionByLongName(name); option.Description != description {
+			t.Errorf("Expected description %q of %s but got %q", description, name, option.Description)
+		}
+	}
+
+	if option := p.FindOptionByShortName('l'); option.Description != "Niveau" {
+		t.Errorf("Expected the description of -l to be set but got %q", option.Description)
+	}
+
+	if long := p.FindOptionByLongName("name").LongDescription; long != "Le nom de l'utilisateur." {
+		t.Errorf("Expected the long description to be set but got %q", long)
+	}
+
+	p.Freeze()
+
+	if err := p.SetDescriptions(catalog); err != ErrFrozen {
+		t.Errorf("Expected ErrFrozen but got %v", err)
+	}
+}
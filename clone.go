@@ -0,0 +1,164 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"reflect"
+)
+
+// Clone returns a copy of the group whose options are bound to a copy of
+// the data struct of the group (see Group.Data), such that the copy can be
+// parsed independently of the original. The options of the copy have the
+// values they had when the original group was created. Options added using
+// AddOption are bound to new variables, except for functions, which are
+// shared with the original. Callbacks such as Option.Validator are shared
+// as well, except for the default functions given by default-func tags,
+// which call the method of the copy of the data struct. The values wrapped by Getter fields and the flags added using
+// AddFlagSet are not copied: the copy sets the same flag.Value as the
+// original.
+func (g *Group) Clone() *Group {
+	var data interface{}
+
+	if g.data != nil {
+		data = cloneStruct(reflect.ValueOf(g.data), g.tags).Interface()
+	}
+
+	return g.clone(data)
+}
+
+// Clone returns a copy of the parser whose groups are cloned (see
+// Group.Clone), such that the same definition of options can be parsed
+// concurrently, e.g. once for each request of a server. The callbacks of the
+// parser (e.g. Parser.OptionValidator) are shared by the copy and must be
+// safe for concurrent use when the parsers are used concurrently. Options
+// backed by a flag.Value of the standard flag package, i.e. Getter fields
+// and options added using Group.AddFlagSet, are shared by the copy as well:
+// parsing the copy sets the values of the original flags, so such options
+// must not be used when parsing copies concurrently. The copy of a frozen
+// parser (see Parser.Freeze) is not frozen.
+func (p *Parser) Clone() *Parser {
+	ret := *p
+
+	ret.Groups = nil
+	ret.frozen = false
+	ret.helpGroup = nil
+	ret.helpRequested = false
+	ret.layer = nil
+	ret.edits = nil
+	ret.debug = nil
+
+	for _, grp := range p.Groups {
+		// The builtin help group refers to the original parser and is
+		// added again by the copy when needed
+		if grp == p.helpGroup {
+			ret.Options |= HelpFlag
+			continue
+		}
+
+		ret.Groups = append(ret.Groups, grp.Clone())
+	}
+
+	return &ret
+}
+
+func (g *Group) clone(data interface{}) *Group {
+	ret := &Group{
+		Name:         g.Name,
+		Description:  g.Description,
+		LongNames:    make(map[string]*Option),
+		ShortNames:   make(map[rune]*Option),
+		Error:        g.Error,
+		data:         data,
+		namespace:    g.namespace,
+		envNamespace: g.envNamespace,
+		index:        g.index,
+		tags:         g.tags,
+	}
+
+	if g.values != nil {
+		ret.values = make(map[string]interface{})
+	}
+
+	var elem reflect.Value
+
+	if data != nil {
+		elem = reflect.ValueOf(data).Elem()
+	}
+
+	for _, option := range g.Options {
+		o := *option
+
+		if option.index != nil {
+			o.value = elem.FieldByIndex(option.index)
+		} else {
+			o.value = reflect.New(option.value.Type()).Elem()
+		}
+
+		o.value.Set(copyValue(option.initial))
+
+		// Setters refer to the variables of the original options
+		o.Setter = nil
+
+		// Default functions given by the default-func tag are methods of
+		// the original data struct
+		if o.defaultMethod != "" {
+			o.DefaultFunc, _ = ret.defaultFunc(o.defaultMethod)
+		}
+
+		o.initial = copyValue(option.initial)
+		o.layer = nil
+		o.origin = Origin{}
+		o.occurrences = 0
+
+		if o.mapValues != nil {
+			o.mapValues = ret.values
+			o.store()
+		}
+
+		// The names of the options cannot conflict as they did not in
+		// the original group
+		ret.addOption(&o)
+	}
+
+	for _, grp := range g.Groups {
+		ptr, _ := structPointer(elem.FieldByIndex(grp.index))
+		ret.Groups = append(ret.Groups, grp.clone(ptr.Interface()))
+	}
+
+	return ret
+}
+
+// cloneStruct returns a pointer to a copy of the struct pointed to by ptr.
+// The structs pointed to by anonymous embedded fields and sub-group fields
+// are copied as well, since they contain options.
+func cloneStruct(ptr reflect.Value, tags TagNames) reflect.Value {
+	ret := reflect.New(ptr.Type().Elem())
+	ret.Elem().Set(ptr.Elem())
+
+	cloneFields(ret.Elem(), tags)
+	return ret
+}
+
+func cloneFields(val reflect.Value, tags TagNames) {
+	stype := val.Type()
+	fields := tags.structFields(stype)
+
+	for i := 0; i < stype.NumField(); i++ {
+		field := stype.Field(i)
+
+		if tag := fields[i].tag; !field.Anonymous && tag.Get("group") == "" {
+			continue
+		}
+
+		fval := val.Field(i)
+
+		switch {
+		case fval.Kind() == reflect.Struct:
+			cloneFields(fval, tags)
+		case fval.Kind() == reflect.Ptr && fval.Type().Elem().Kind() == reflect.Struct && !fval.IsNil() && fval.CanSet():
+			fval.Set(cloneStruct(fval, tags))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package flags
+
+import (
+	"strings"
+	"testing"
+)
+
+type cloneOptions struct {
+	loggingOptions
+
+	Port    int            `long:"port" default:"80"`
+	Hosts   []string       `long:"host"`
+	Server  serverOptions  `group:"Server Options" namespace:"server"`
+	Client  *serverOptions `group:"Client Options" namespace:"client"`
+	Comment string
+}
+
+func TestClone(t *testing.T) {
+	opts := cloneOptions{Comment: "original"}
+
+	p := NewNamedParser("test", HelpFlag, NewGroup("Application Options", &opts))
+
+	var extra string
+
+	if _, err := p.Groups[0].AddOption("extra", 0, "", &extra); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	p.AddGroup("Plugin Options", "", nil)
+	p.Groups = append(p.Groups, NewMapGroup("Map Options", map[string]interface{}{"level": 1}))
+
+	if _, err := p.ParseArgs([]string{"--port", "1", "--host", "a", "--log-level", "x", "--server.port", "2", "--client.tls.cert", "c", "--level", "3"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	c := p.Clone()
+
+	if _, err := c.ParseArgs([]string{"--host", "b", "--extra", "e", "--client.port", "4"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	cloned := c.FindGroup("Application Options").Data().(*cloneOptions)
+
+	if cloned == &opts || cloned.Client == opts.Client {
+		t.Fatalf("Expected a copy of the data")
+	}
+
+	if cloned.Port != 80 || strings.Join(cloned.Hosts, ",") != "b" || cloned.Level != "" || cloned.Server.Port != 0 || cloned.Client.Port != 4 || cloned.Client.TLS.Cert != "" || cloned.Comment != "original" {
+		t.Errorf("Unexpected cloned values: %+v", cloned)
+	}
+
+	if opts.Port != 1 || strings.Join(opts.Hosts, ",") != "a" || opts.Client.Port != 0 || opts.Client.TLS.Cert != "c" || extra != "" {
+		t.Errorf("Unexpected original values: %+v, %q", opts, extra)
+	}
+
+	if v := c.FindOptionByLongName("extra").Value(); v != "e" {
+		t.Errorf("Unexpected value of cloned option: %v", v)
+	}
+
+	if v := c.FindGroup("Map Options").Data().(map[string]interface{})["level"]; v != 1 {
+		t.Errorf("Unexpected value of cloned map option: %v", v)
+	}
+
+	if _, err := c.ParseArgs([]string{"--help"}); err == nil || !strings.Contains(err.Error(), "Help Options") || len(c.Groups) != len(p.Groups) {
+		t.Errorf("Expected help of the clone but got %v", err)
+	}
+}
+
+type cloneDefaultFuncOptions struct {
+	Home  string `long:"home" default:"/home"`
+	Cache string `long:"cache" default-func:"DefaultCache"`
+}
+
+func (o *cloneDefaultFuncOptions) DefaultCache() string {
+	return o.Home + "/cache"
+}
+
+func TestCloneDefaultFunc(t *testing.T) {
+	var opts cloneDefaultFuncOptions
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	c := p.Clone()
+
+	if _, err := c.ParseArgs([]string{"--home", "/h"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cloned := c.Groups[0].Data().(*cloneDefaultFuncOptions); cloned.Cache != "/h/cache" {
+		t.Errorf("Expected the default of the copy but got %q", cloned.Cache)
+	}
+
+	if opts.Home != "/home" || opts.Cache != "" {
+		t.Errorf("Unexpected original values: %+v", opts)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Change is a difference between two versions of the options of a
+// command line interface (see CompareSpecs).
+type Change struct {
+	// The option which changed, e.g. --port
+	Option string
+
+	// Whether command lines, environments or configuration files which were
+	// accepted by the previous version may be rejected or interpreted
+	// differently by the current version
+	Breaking bool
+
+	// The description of the change
+	Message string
+}
+
+// String returns a description of the change, such as
+// "--port: type changed from int to string (breaking)".
+func (c Change) String() string {
+	if c.Breaking {
+		return fmt.Sprintf("%s: %s (breaking)", c.Option, c.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", c.Option, c.Message)
+}
+
+// ReadSpec reads a spec written by Parser.WriteSpec.
+func ReadSpec(reader io.Reader) (Spec, error) {
+	var ret Spec
+
+	err := json.NewDecoder(reader).Decode(&ret)
+	return ret, err
+}
+
+// CompareSpecs compares the spec of a previous version of a command line
+// interface with its current spec (see Parser.Spec), and returns the changes
+// of the options. Options are matched by their flag names regardless of
+// their groups. Breaking changes include removed options and flags, changed
+// types, arguments which are no longer accepted or are now required, removed
+// choices, tightened ranges, newly required options and renamed environment
+// variables. This allows a release to be gated on the compatibility of its
+// command line interface, for example:
+//
+//	previous, err := flags.ReadSpec(file)
+//
+//	for _, change := range flags.CompareSpecs(previous, parser.Spec()) {
+//		if change.Breaking {
+//			t.Error(change)
+//		}
+//	}
+func CompareSpecs(previous Spec, current Spec) []Change {
+	var ret []Change
+
+	// The current options by their flag names
+	flags := make(map[string]*OptionSpec)
+	matched := make(map[*OptionSpec]bool)
+
+	for i := range current.Groups {
+		for j := range current.Groups[i].Options {
+			option := &current.Groups[i].Options[j]
+
+			for _, name := range option.flagNames() {
+				flags[name] = option
+			}
+		}
+	}
+
+	for _, grp := range previous.Groups {
+		for _, option := range grp.Options {
+			var cur *OptionSpec
+
+			for _, name := range option.flagNames() {
+				if cur = flags[name]; cur != nil {
+					break
+				}
+			}
+
+			if cur == nil {
+				ret = append(ret, Change{option.String(), true, "option was removed"})
+				continue
+			}
+
+			matched[cur] = true
+			ret = append(ret, compareOptions(option, *cur, flags)...)
+		}
+	}
+
+	for _, grp := range current.Groups {
+		for i := range grp.Options {
+			option := &grp.Options[i]
+
+			if matched[option] {
+				continue
+			}
+
+			if option.Required {
+				ret = append(ret, Change{option.String(), true, "required option was added"})
+			} else {
+				ret = append(ret, Change{option.String(), false, "option was added"})
+			}
+		}
+	}
+
+	return ret
+}
+
+// compareOptions returns the changes between the previous and the current
+// version of an option.
+func compareOptions(prev OptionSpec, cur OptionSpec, flags map[string]*OptionSpec) []Change {
+	var ret []Change
+
+	name := prev.String()
+
+	change := func(breaking bool, format string, a ...interface{}) {
+		ret = append(ret, Change{name, breaking, fmt.Sprintf(format, a...)})
+	}
+
+	curNames := cur.flagNames()
+
+	for _, flag := range prev.flagNames() {
+		if !containsString(curNames, flag) {
+			if other := flags[flag]; other != nil {
+				change(true, "flag %s now refers to %s", flag, other)
+			} else {
+				change(true, "flag %s was removed", flag)
+			}
+		}
+	}
+
+	for _, flag := range curNames {
+		if !containsString(prev.flagNames(), flag) {
+			change(false, "flag %s was added", flag)
+		}
+	}
+
+	prevType, curType := schemaType(prev.Schema), schemaType(cur.Schema)
+
+	if prevType != curType {
+		change(true, "type changed from %s to %s", prev.Type, cur.Type)
+	} else if prev.Type != cur.Type {
+		change(false, "type changed from %s to %s", prev.Type, cur.Type)
+	}
+
+	switch {
+	case prev.HasArgument && !cur.HasArgument:
+		change(true, "argument is no longer accepted")
+	case !prev.HasArgument && cur.HasArgument && !cur.OptionalArgument:
+		change(true, "argument is now required")
+	case prev.HasArgument && prev.OptionalArgument && !cur.OptionalArgument:
+		change(true, "argument is no longer optional")
+	case prev.HasArgument && !prev.OptionalArgument && cur.OptionalArgument:
+		// Optional arguments must be given in the same argument as the flag
+		change(true, "argument is now optional and must be given as %s=%s", name, cur.valueName())
+	}
+
+	if len(cur.Choices) != 0 {
+		if len(prev.Choices) == 0 {
+			change(true, "choices were introduced: %s", strings.Join(cur.Choices, ", "))
+		} else {
+			if removed := subtractStrings(prev.Choices, cur.Choices); removed != nil {
+				change(true, "choices were removed: %s", strings.Join(removed, ", "))
+			}
+
+			if added := subtractStrings(cur.Choices, prev.Choices); added != nil {
+				change(false, "choices were added: %s", strings.Join(added, ", "))
+			}
+		}
+	} else if len(prev.Choices) != 0 {
+		change(false, "choices were lifted")
+	}
+
+	prevElem, curElem := schemaElem(prev.Schema), schemaElem(cur.Schema)
+
+	if min, ok := schemaNumber(curElem, "minimum"); ok {
+		if pmin, ok := schemaNumber(prevElem, "minimum"); !ok || min > pmin {
+			change(true, "minimum was raised to %v", min)
+		}
+	}
+
+	if max, ok := schemaNumber(curElem, "maximum"); ok {
+		if pmax, ok := schemaNumber(prevElem, "maximum"); !ok || max < pmax {
+			change(true, "maximum was lowered to %v", max)
+		}
+	}
+
+	if pattern, _ := curElem["pattern"].(string); pattern != "" && pattern != prevElem["pattern"] {
+		change(true, "pattern changed to %s", pattern)
+	}
+
+	if !prev.Required && cur.Required {
+		change(true, "option is now required")
+	}
+
+	if prev.Repeatable && !cur.Repeatable {
+		change(true, "option can no longer be specified more than once")
+	}
+
+	switch {
+	case prev.EnvName != "" && cur.EnvName == "":
+		change(true, "environment variable %s was removed", prev.EnvName)
+	case prev.EnvName != "" && cur.EnvName != prev.EnvName:
+		change(true, "environment variable %s was renamed to %s", prev.EnvName, cur.EnvName)
+	case prev.EnvName == "" && cur.EnvName != "":
+		change(false, "environment variable %s was added", cur.EnvName)
+	}
+
+	if prev.EnvName != "" && cur.EnvName != "" && prev.EnvDelim != cur.EnvDelim {
+		change(true, "delimiter of environment variable %s changed from %q to %q", cur.EnvName, prev.EnvDelim, cur.EnvDelim)
+	}
+
+	for _, source := range []string{"cli", "env", "config"} {
+		if prev.allowsSource(source) && !cur.allowsSource(source) {
+			change(true, "option can no longer be set by %s", source)
+		}
+	}
+
+	if prevDefault, curDefault := jsonString(prev.Default), jsonString(cur.Default); prevDefault != curDefault {
+		change(false, "default changed from %s to %s", prevDefault, curDefault)
+	}
+
+	if prev.Deprecated == "" && cur.Deprecated != "" {
+		change(false, "option was deprecated")
+	}
+
+	return ret
+}
+
+// String returns the first flag name of the option, e.g. --port.
+func (option OptionSpec) String() string {
+	return option.flagNames()[0]
+}
+
+// flagNames returns the flags of the option, with the long names first.
+func (option OptionSpec) flagNames() []string {
+	var ret []string
+
+	if option.LongName != "" {
+		ret = append(ret, "--"+option.LongName)
+	}
+
+	for _, alias := range option.LongAliases {
+		ret = append(ret, "--"+alias)
+	}
+
+	if option.ShortName != "" {
+		ret = append(ret, "-"+option.ShortName)
+	}
+
+	for _, alias := range option.ShortAliases {
+		ret = append(ret, "-"+alias)
+	}
+
+	return ret
+}
+
+func (option OptionSpec) valueName() string {
+	if option.ValueName != "" {
+		return option.ValueName
+	}
+
+	return "VALUE"
+}
+
+// allowsSource returns whether the option may be set by the given kind of
+// source (cli, env or config).
+func (option OptionSpec) allowsSource(source string) bool {
+	return len(option.Sources) == 0 || containsString(option.Sources, source)
+}
+
+// schemaType returns the JSON type of a schema, including the type of its
+// elements, e.g. array of integer.
+func schemaType(schema map[string]interface{}) string {
+	tp, _ := schema["type"].(string)
+
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		return tp + " of " + schemaType(items)
+	}
+
+	if values, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		return tp + " of " + schemaType(values)
+	}
+
+	return tp
+}
+
+// schemaElem returns the schema of the elements of an array or object, or
+// the schema itself.
+func schemaElem(schema map[string]interface{}) map[string]interface{} {
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		return items
+	}
+
+	if values, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		return values
+	}
+
+	return schema
+}
+
+// schemaNumber returns a numeric keyword of a schema, which is a float64
+// when the schema was decoded from JSON.
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	switch v := schema[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+
+	return 0, false
+}
+
+func jsonString(v interface{}) string {
+	if v == nil {
+		return "none"
+	}
+
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subtractStrings returns the strings of a which are not in b.
+func subtractStrings(a []string, b []string) []string {
+	var ret []string
+
+	for _, s := range a {
+		if !containsString(b, s) {
+			ret = append(ret, s)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,417 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Completion is a single completion candidate for the word being completed.
+type Completion struct {
+	// The completed item
+	Item string
+
+	// A description of the item (optional). Shells which support it show
+	// the description next to the item.
+	Description string
+}
+
+// Completer is an interface which can be implemented by types used as option
+// values to provide the completions of an option argument. Complete returns
+// the candidates for the partial argument match.
+type Completer interface {
+	Complete(match string) []Completion
+}
+
+// Filename is a string type which can be used for options whose argument is
+// a file name. The arguments of such options complete to file names. Use
+// the complete tag to only complete directories (complete:"dirs") or files
+// matching a glob pattern (complete:"*.yaml"). The tag can also be used on
+// options of other types.
+type Filename string
+
+// The requested shell is not supported by the completion script generator
+var ErrUnsupportedShell = errors.New("unsupported completion shell")
+
+var completionWriters = map[string]func(p *Parser, writer *bufio.Writer){
+	"bash":       (*Parser).writeBashCompletion,
+	"fish":       (*Parser).writeFishCompletion,
+	"powershell": (*Parser).writePowerShellCompletion,
+	"zsh":        (*Parser).writeZshCompletion,
+}
+
+// WriteCompletion writes a completion script for the given shell to the
+// provided writer. The script covers all the options of all the groups in
+// the parser. Supported shells are: bash, zsh, fish and powershell.
+// ErrUnsupportedShell is returned for any other shell. Applications typically
+// expose this using an option or environment variable, such that users can
+// install the script with for example:
+//
+//	source <(myprog --completion=bash)
+//
+// Where static information is insufficient (for example for arguments of
+// options whose type implements Completer), the generated script calls back
+// into the application using the dynamic completion protocol: the
+// application is run with the GO_FLAGS_COMPLETION environment variable set
+// and the words of the command line up to and including the word being
+// completed as arguments. Parse then prints the completion candidates, one
+// per line, and exits instead of parsing the arguments. When
+// GO_FLAGS_COMPLETION is set to "verbose", each candidate is followed by a
+// tab and its description.
+func (p *Parser) WriteCompletion(writer io.Writer, shell string) error {
+	fn, ok := completionWriters[shell]
+
+	if !ok {
+		return ErrUnsupportedShell
+	}
+
+	p.addHelpGroup()
+
+	wr := bufio.NewWriter(writer)
+	fn(p, wr)
+
+	return wr.Flush()
+}
+
+// completionName returns the application name made safe for use as
+// (part of) an identifier in a shell script.
+func (p *Parser) completionName() string {
+	ret := []rune(p.ApplicationName)
+
+	for i, r := range ret {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			ret[i] = '_'
+		}
+	}
+
+	return string(ret)
+}
+
+// completionOptions returns all the options of the parser which should be
+// completed, in the order in which they appear in the help.
+func (p *Parser) completionOptions() []*Option {
+	var ret []*Option
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if !option.NoCompletion && option.allowsOrigin(OriginCommandLine) {
+				ret = append(ret, option)
+			}
+		}
+	}
+
+	return ret
+}
+
+// flagNames returns the command line forms of the option (-v, --verbose),
+// including its aliases: the short name, the short aliases, the long name
+// and the long aliases.
+func (option *Option) flagNames() []string {
+	var ret []string
+
+	if option.ShortName != 0 {
+		ret = append(ret, "-"+string(option.ShortName))
+	}
+
+	for _, alias := range option.ShortAliases {
+		ret = append(ret, "-"+string(alias))
+	}
+
+	if option.LongName != "" {
+		ret = append(ret, "--"+option.LongName)
+	}
+
+	for _, alias := range option.LongAliases {
+		ret = append(ret, "--"+alias)
+	}
+
+	return ret
+}
+
+// argumentType returns the type of the argument of the option.
+func (option *Option) argumentType() reflect.Type {
+	tp := option.value.Type()
+
+	switch tp.Kind() {
+	case reflect.Slice, reflect.Map:
+		return tp.Elem()
+	case reflect.Func:
+		if tp.NumIn() > 0 {
+			return tp.In(0)
+		}
+	}
+
+	return tp
+}
+
+// completionValueName returns the placeholder used for the argument of the
+// option. It defaults to the name of the argument type.
+func (option *Option) completionValueName() string {
+	if option.ValueName != "" {
+		return option.ValueName
+	}
+
+	return option.argumentType().Name()
+}
+
+// fileCompletion returns whether the argument of the option completes to
+// file names and if so, whether only directories are completed or the glob
+// pattern to which completed files are restricted.
+func (option *Option) fileCompletion() (ok bool, dirs bool, pattern string) {
+	filter := option.options.Get("complete")
+
+	if filter == "" && option.argumentType() != reflect.TypeOf(Filename("")) {
+		return false, false, ""
+	}
+
+	if filter == "dirs" {
+		return true, true, ""
+	}
+
+	return true, false, filter
+}
+
+// isFilteredFile returns whether the argument of the option completes to a
+// subset of the file names.
+func (option *Option) isFilteredFile() bool {
+	ok, dirs, pattern := option.fileCompletion()
+	return ok && (dirs || pattern != "")
+}
+
+// completer returns the Completer of the argument of the option, or nil if
+// the argument type does not implement Completer.
+func (option *Option) completer() Completer {
+	val := option.value
+	tp := val.Type()
+
+	switch tp.Kind() {
+	case reflect.Slice:
+		val = reflect.New(tp.Elem())
+	case reflect.Func:
+		if tp.NumIn() == 0 {
+			return nil
+		}
+
+		val = reflect.New(tp.In(0))
+	default:
+		if val.CanAddr() {
+			val = val.Addr()
+		}
+	}
+
+	if completer, ok := val.Interface().(Completer); ok {
+		return completer
+	}
+
+	return nil
+}
+
+// isRepeatable returns whether the option is meaningful to specify more
+// than once.
+func (option *Option) isRepeatable() bool {
+	if option.counter != 0 {
+		return true
+	}
+
+	switch option.value.Type().Kind() {
+	case reflect.Slice, reflect.Map:
+		return true
+	}
+
+	return false
+}
+
+// completionArgumentOption returns the option which takes the next command
+// line argument as its argument when arg is specified, or nil if arg does
+// not expect a separate argument.
+func (p *Parser) completionArgumentOption(arg string) *Option {
+	if strings.Contains(arg, "=") {
+		return nil
+	}
+
+	if strings.HasPrefix(arg, "--") {
+		for _, grp := range p.groups() {
+			if option := grp.LongNames[arg[2:]]; option != nil && option.canArgument() {
+				return option
+			}
+		}
+
+		return nil
+	}
+
+	if !strings.HasPrefix(arg, "-") {
+		return nil
+	}
+
+	short := []rune(arg[1:])
+
+	for i, c := range short {
+		if option, _ := p.getShort(c); option != nil && option.canArgument() {
+			// Any remaining characters are the argument of the option
+			if i == len(short)-1 {
+				return option
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// completeValue returns the completions for the argument of the given
+// option. Each item is prefixed with prefix.
+func (p *Parser) completeValue(option *Option, prefix string, match string) []Completion {
+	var ret []Completion
+
+	if len(option.Choices) != 0 {
+		for _, choice := range option.Choices {
+			if strings.HasPrefix(choice, match) {
+				ret = append(ret, Completion{Item: choice})
+			}
+		}
+	} else if completer := option.completer(); completer != nil {
+		ret = completer.Complete(match)
+	} else if ok, dirs, pattern := option.fileCompletion(); ok {
+		ret = completeFilename(match, dirs, pattern)
+	}
+
+	for i := range ret {
+		ret[i].Item = prefix + ret[i].Item
+	}
+
+	return ret
+}
+
+// completeFilename returns the files and directories which start with match.
+// Directories are completed with a trailing separator, such that completion
+// can continue inside them.
+func completeFilename(match string, dirs bool, pattern string) []Completion {
+	var ret []Completion
+
+	files, _ := filepath.Glob(match + "*")
+
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			ret = append(ret, Completion{Item: file + string(filepath.Separator)})
+			continue
+		}
+
+		if dirs {
+			continue
+		}
+
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, filepath.Base(file)); !ok {
+				continue
+			}
+		}
+
+		ret = append(ret, Completion{Item: file})
+	}
+
+	return ret
+}
+
+// completeOption returns the option flags which start with match.
+func (p *Parser) completeOption(match string) []Completion {
+	var ret []Completion
+
+	for _, option := range p.completionOptions() {
+		for _, name := range option.flagNames() {
+			if strings.HasPrefix(name, match) {
+				ret = append(ret, Completion{
+					Item:        name,
+					Description: option.description(),
+				})
+			}
+		}
+	}
+
+	return ret
+}
+
+// complete returns the completions of the last of the given command line
+// arguments.
+func (p *Parser) complete(args []string) []Completion {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+
+	match := args[len(args)-1]
+	var prev *Option
+
+	for _, arg := range args[:len(args)-1] {
+		if prev != nil {
+			prev = nil
+			continue
+		}
+
+		// Everything after a double dash is a positional argument, like
+		// everything after a non-option with PassAfterNonOption
+		if (p.Options&PassDoubleDash) != None && arg == "--" {
+			return nil
+		}
+
+		if (p.Options&PassAfterNonOption) != None && (arg == "" || arg[0] != '-') {
+			return nil
+		}
+
+		prev = p.completionArgumentOption(arg)
+	}
+
+	if prev != nil {
+		if prev.NoCompletion {
+			return nil
+		}
+
+		return p.completeValue(prev, "", match)
+	}
+
+	if strings.HasPrefix(match, "--") {
+		if pos := strings.Index(match, "="); pos >= 0 {
+			for _, grp := range p.groups() {
+				if option := grp.LongNames[match[2:pos]]; option != nil && !option.NoCompletion {
+					return p.completeValue(option, match[:pos+1], match[pos+1:])
+				}
+			}
+
+			return nil
+		}
+	}
+
+	if strings.HasPrefix(match, "-") {
+		return p.completeOption(match)
+	}
+
+	return nil
+}
+
+// printCompletions writes the completions of args to writer using the
+// dynamic completion protocol.
+func (p *Parser) printCompletions(writer io.Writer, args []string, verbose bool) {
+	for _, c := range p.complete(args) {
+		if verbose && c.Description != "" {
+			fmt.Fprintf(writer, "%s\t%s\n", c.Item, c.Description)
+		} else {
+			fmt.Fprintln(writer, c.Item)
+		}
+	}
+}
+
+// completionMode returns the value of the GO_FLAGS_COMPLETION environment
+// variable (see Parser.LookupEnv), which when not empty switches Parse into
+// completion mode.
+func (p *Parser) completionMode() string {
+	return p.getenv("GO_FLAGS_COMPLETION")
+}
@@ -0,0 +1,88 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+func bashQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// bashValueAction returns the bash code which completes the argument of the
+// option.
+func bashValueAction(option *Option) string {
+	if len(option.Choices) != 0 {
+		return fmt.Sprintf("COMPREPLY=( $(compgen -W %s -- \"$cur\") )", bashQuote(strings.Join(option.Choices, " ")))
+	}
+
+	if option.completer() != nil {
+		return "COMPREPLY=( $(GO_FLAGS_COMPLETION=1 \"${COMP_WORDS[0]}\" \"$prev\" \"$cur\") )"
+	}
+
+	if _, dirs, pattern := option.fileCompletion(); dirs {
+		return "COMPREPLY=( $(compgen -d -- \"$cur\") )"
+	} else if pattern != "" {
+		// Directories are still completed to allow navigating to the files
+		return fmt.Sprintf("COMPREPLY=( $(compgen -d -- \"$cur\") $(compgen -f -X %s -- \"$cur\") )", bashQuote("!"+pattern))
+	}
+
+	return "COMPREPLY=( $(compgen -f -- \"$cur\") )"
+}
+
+func (p *Parser) writeBashCompletion(writer *bufio.Writer) {
+	name := p.completionName()
+
+	var words []string
+	var withArgument []*Option
+
+	for _, option := range p.completionOptions() {
+		words = append(words, option.flagNames()...)
+
+		if option.canArgument() {
+			withArgument = append(withArgument, option)
+		}
+	}
+
+	fmt.Fprintf(writer, "_%s() {\n", name)
+	writer.WriteString("    local cur prev\n")
+	writer.WriteString("    COMPREPLY=()\n")
+	writer.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	writer.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+
+	if len(withArgument) != 0 {
+		writer.WriteString("    case \"$prev\" in\n")
+
+		for _, option := range withArgument {
+			var patterns []string
+
+			for _, n := range option.flagNames() {
+				patterns = append(patterns, bashQuote(n))
+			}
+
+			fmt.Fprintf(writer, "    %s)\n", strings.Join(patterns, "|"))
+			fmt.Fprintf(writer, "        %s\n", bashValueAction(option))
+			writer.WriteString("        return 0\n")
+			writer.WriteString("        ;;\n")
+		}
+
+		writer.WriteString("    esac\n\n")
+	}
+
+	writer.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(writer, "        COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(words, " "))
+	writer.WriteString("        return 0\n")
+	writer.WriteString("    fi\n\n")
+
+	writer.WriteString("    COMPREPLY=( $(compgen -f -- \"$cur\") )\n")
+	writer.WriteString("}\n\n")
+
+	// Fall back to filename completion when the application does not
+	// provide any candidates
+	fmt.Fprintf(writer, "complete -o default -F _%s %s\n", name, p.ApplicationName)
+}
@@ -0,0 +1,52 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+var fishEscaper = strings.NewReplacer(`\`, `\\`, "'", `\'`)
+
+func fishQuote(s string) string {
+	return "'" + fishEscaper.Replace(s) + "'"
+}
+
+func (p *Parser) writeFishCompletion(writer *bufio.Writer) {
+	for _, option := range p.completionOptions() {
+		fmt.Fprintf(writer, "complete -c %s", fishQuote(p.ApplicationName))
+
+		for _, name := range option.flagNames() {
+			if strings.HasPrefix(name, "--") {
+				fmt.Fprintf(writer, " -l %s", fishQuote(name[2:]))
+			} else {
+				fmt.Fprintf(writer, " -s %s", fishQuote(name[1:]))
+			}
+		}
+
+		if option.canArgument() {
+			writer.WriteString(" -r")
+
+			if len(option.Choices) != 0 {
+				fmt.Fprintf(writer, " -f -a %s", fishQuote(strings.Join(option.Choices, " ")))
+			} else if _, dirs, _ := option.fileCompletion(); dirs && option.completer() == nil {
+				writer.WriteString(" -f -a '(__fish_complete_directories (commandline -ct))'")
+			} else if option.completer() != nil || option.isFilteredFile() {
+				// Call back into the application for the candidates
+				fmt.Fprintf(writer, " -f -a %s", fishQuote(fmt.Sprintf("(env GO_FLAGS_COMPLETION=verbose %s %s (commandline -ct))",
+					fishQuote(p.ApplicationName),
+					fishQuote(option.flagNames()[0]))))
+			}
+		}
+
+		if description := option.description(); description != "" {
+			fmt.Fprintf(writer, " -d %s", fishQuote(description))
+		}
+
+		writer.WriteString("\n")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+func powerShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func (p *Parser) writePowerShellCompletion(writer *bufio.Writer) {
+	fmt.Fprintf(writer, "Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {\n", powerShellQuote(p.ApplicationName))
+	writer.WriteString("    param($wordToComplete, $commandAst, $cursorPosition)\n\n")
+
+	var dynamic []string
+	var choices []string
+
+	for _, option := range p.completionOptions() {
+		if !option.canArgument() {
+			continue
+		}
+
+		for _, name := range option.flagNames() {
+			if len(option.Choices) != 0 {
+				var quoted []string
+
+				for _, choice := range option.Choices {
+					quoted = append(quoted, powerShellQuote(choice))
+				}
+
+				choices = append(choices, fmt.Sprintf("%s = @(%s)", powerShellQuote(name), strings.Join(quoted, ", ")))
+			} else if option.completer() != nil || option.isFilteredFile() {
+				dynamic = append(dynamic, powerShellQuote(name))
+			}
+		}
+	}
+
+	if len(dynamic) != 0 || len(choices) != 0 {
+		// Find the option preceding the word being completed
+		writer.WriteString("    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })\n\n")
+		writer.WriteString("    if ($wordToComplete -ne '' -and $words.Count -gt 1) {\n")
+		writer.WriteString("        $words = $words[0..($words.Count - 2)]\n")
+		writer.WriteString("    }\n\n")
+	}
+
+	if len(choices) != 0 {
+		writer.WriteString("    $choices = @{\n")
+
+		for _, choice := range choices {
+			fmt.Fprintf(writer, "        %s\n", choice)
+		}
+
+		writer.WriteString("    }\n\n")
+		writer.WriteString("    if ($choices.ContainsKey($words[-1])) {\n")
+		writer.WriteString("        $choices[$words[-1]] | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+		writer.WriteString("            [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)\n")
+		writer.WriteString("        }\n\n")
+		writer.WriteString("        return\n")
+		writer.WriteString("    }\n\n")
+	}
+
+	if len(dynamic) != 0 {
+		// Call back into the application to complete option arguments
+		fmt.Fprintf(writer, "    if (@(%s) -contains $words[-1]) {\n", strings.Join(dynamic, ", "))
+		writer.WriteString("        $env:GO_FLAGS_COMPLETION = 'verbose'\n")
+		writer.WriteString("        $results = & $words[0] $words[-1] $wordToComplete\n")
+		writer.WriteString("        Remove-Item Env:GO_FLAGS_COMPLETION\n\n")
+		writer.WriteString("        $results | ForEach-Object {\n")
+		writer.WriteString("            $item, $description = $_ -split \"`t\", 2\n")
+		writer.WriteString("            if (-not $description) { $description = $item }\n")
+		writer.WriteString("            [System.Management.Automation.CompletionResult]::new($item, $item, 'ParameterValue', $description)\n")
+		writer.WriteString("        }\n\n")
+		writer.WriteString("        return\n")
+		writer.WriteString("    }\n\n")
+	}
+
+	writer.WriteString("    $options = @(\n")
+
+	for _, option := range p.completionOptions() {
+		for _, name := range option.flagNames() {
+			// The tooltip of a completion result cannot be empty
+			description := option.description()
+
+			if description == "" {
+				description = name
+			}
+
+			fmt.Fprintf(writer, "        @{ Name = %s; Description = %s }\n",
+				powerShellQuote(name),
+				powerShellQuote(description))
+		}
+	}
+
+	writer.WriteString("    )\n\n")
+	writer.WriteString("    $options | Where-Object { $_.Name -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	writer.WriteString("        [System.Management.Automation.CompletionResult]::new($_.Name, $_.Name, 'ParameterName', $_.Description)\n")
+	writer.WriteString("    }\n")
+	writer.WriteString("}\n")
+}
@@ -0,0 +1,321 @@
+package flags
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testCompleter string
+
+func (t *testCompleter) Complete(match string) []Completion {
+	var ret []Completion
+
+	for _, s := range []string{"alpha", "beta", "bravo"} {
+		if strings.HasPrefix(s, match) {
+			ret = append(ret, Completion{Item: s})
+		}
+	}
+
+	return ret
+}
+
+type completionOptions struct {
+	Verbose []bool        `short:"v" long:"verbose" description:"Show verbose debug information"`
+	Output  string        `short:"o" long:"output" description:"Output file"`
+	Level   int           `long:"level" description:"Level"`
+	Host    testCompleter `long:"host" description:"Host"`
+	Config  Filename      `long:"config" complete:"*.yaml" description:"Config file"`
+	Dir     string        `long:"dir" complete:"dirs" description:"Directory"`
+	Format  string        `long:"format" choice:"json" choice:"text" description:"Format"`
+	Secret  string        `long:"secret" no-completion:"true" choice:"a" description:"Secret"`
+}
+
+func newCompletionParser() *Parser {
+	var opts completionOptions
+
+	return NewNamedParser("my-prog", HelpFlag, NewGroup("Application Options", &opts))
+}
+
+func writeCompletion(t *testing.T, p *Parser, shell string) string {
+	var b bytes.Buffer
+
+	if err := p.WriteCompletion(&b, shell); err != nil {
+		t.Fatalf("Unexpected error writing %s completion: %s", shell, err)
+	}
+
+	return b.String()
+}
+
+func TestCompletionUnsupportedShell(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := newCompletionParser().WriteCompletion(&b, "csh"); err != ErrUnsupportedShell {
+		t.Errorf("Expected ErrUnsupportedShell but got %v", err)
+	}
+}
+
+func TestCompletionBash(t *testing.T) {
+	s := writeCompletion(t, newCompletionParser(), "bash")
+
+	expected := []string{
+		"_my_prog() {",
+		"compgen -W \"-h --help -v --verbose -o --output --level --host --config --dir --format\"",
+		"'--format')\n        COMPREPLY=( $(compgen -W 'json text' -- \"$cur\") )",
+		"'--config')\n        COMPREPLY=( $(compgen -d -- \"$cur\") $(compgen -f -X '!*.yaml' -- \"$cur\") )",
+		"'--dir')\n        COMPREPLY=( $(compgen -d -- \"$cur\") )",
+		"'-o'|'--output')\n        COMPREPLY=( $(compgen -f -- \"$cur\") )",
+		"'--host')\n        COMPREPLY=( $(GO_FLAGS_COMPLETION=1 \"${COMP_WORDS[0]}\" \"$prev\" \"$cur\") )",
+		"complete -o default -F _my_prog my-prog",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(s, e) {
+			t.Errorf("Expected bash completion to contain %q, got:\n%s", e, s)
+		}
+	}
+
+	if strings.Contains(s, "--secret") {
+		t.Errorf("Expected no-completion option to be excluded, got:\n%s", s)
+	}
+
+	if strings.Contains(s, "'-v'") {
+		t.Errorf("Expected bool option to not complete an argument, got:\n%s", s)
+	}
+}
+
+func TestCompletionZsh(t *testing.T) {
+	s := writeCompletion(t, newCompletionParser(), "zsh")
+
+	expected := []string{
+		"#compdef my-prog",
+		"'(-h --help)'{-h,--help}'[Show this help message]'",
+		"'*'{-v,--verbose}'[Show verbose debug information]'",
+		"'(-o --output)'{-o,--output=}'[Output file]:string:_files'",
+		"'(--level)--level=[Level]:int:_files'",
+		"'(--host)--host=[Host]:testCompleter:{_my_prog_values --host}'",
+		"'(--config)--config=[Config file]:Filename:_files -g \"*.yaml\"'",
+		"'(--dir)--dir=[Directory]:string:_files -/'",
+		"'(--format)--format=[Format]:string:(json text)'",
+		"_my_prog \"$@\"",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(s, e) {
+			t.Errorf("Expected zsh completion to contain %q, got:\n%s", e, s)
+		}
+	}
+}
+
+func TestCompletionFish(t *testing.T) {
+	s := writeCompletion(t, newCompletionParser(), "fish")
+
+	expected := []string{
+		"complete -c 'my-prog' -s 'h' -l 'help' -d 'Show this help message'\n",
+		"complete -c 'my-prog' -s 'o' -l 'output' -r -d 'Output file'\n",
+		"complete -c 'my-prog' -l 'level' -r -d 'Level'\n",
+		"complete -c 'my-prog' -l 'host' -r -f -a '(env GO_FLAGS_COMPLETION=verbose \\'my-prog\\' \\'--host\\' (commandline -ct))' -d 'Host'\n",
+		"complete -c 'my-prog' -l 'dir' -r -f -a '(__fish_complete_directories (commandline -ct))' -d 'Directory'\n",
+		"complete -c 'my-prog' -l 'format' -r -f -a 'json text' -d 'Format'\n",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(s, e) {
+			t.Errorf("Expected fish completion to contain %q, got:\n%s", e, s)
+		}
+	}
+}
+
+func TestCompletionPowerShell(t *testing.T) {
+	s := writeCompletion(t, newCompletionParser(), "powershell")
+
+	expected := []string{
+		"Register-ArgumentCompleter -Native -CommandName 'my-prog' -ScriptBlock {",
+		"@{ Name = '--verbose'; Description = 'Show verbose debug information' }",
+		"@{ Name = '-o'; Description = 'Output file' }",
+		"if (@('--host', '--config', '--dir') -contains $words[-1]) {",
+		"'--format' = @('json', 'text')",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(s, e) {
+			t.Errorf("Expected powershell completion to contain %q, got:\n%s", e, s)
+		}
+	}
+}
+
+func TestCompletionAliases(t *testing.T) {
+	var opts struct {
+		Output string `short:"o" long:"output" short-alias:"O" long-alias:"out" description:"Output file"`
+		Format string `long:"format" long-alias:"fmt" choice:"json" choice:"text" description:"Format"`
+	}
+
+	p := NewNamedParser("my-prog", None, NewGroup("Application Options", &opts))
+
+	tests := []struct {
+		shell    string
+		expected []string
+	}{
+		{"bash", []string{
+			"compgen -W \"-o -O --output --out --format --fmt\"",
+			"'-o'|'-O'|'--output'|'--out')",
+			"'--format'|'--fmt')",
+		}},
+		{"zsh", []string{
+			"'(-o -O --output --out)'{-o,-O,--output=,--out=}'[Output file]:string:_files'",
+			"'(--format --fmt)'{--format=,--fmt=}'[Format]:string:(json text)'",
+		}},
+		{"fish", []string{
+			"complete -c 'my-prog' -s 'o' -s 'O' -l 'output' -l 'out' -r -d 'Output file'\n",
+			"complete -c 'my-prog' -l 'format' -l 'fmt' -r -f -a 'json text' -d 'Format'\n",
+		}},
+		{"powershell", []string{
+			"@{ Name = '-O'; Description = 'Output file' }",
+			"@{ Name = '--out'; Description = 'Output file' }",
+			"'--fmt' = @('json', 'text')",
+		}},
+	}
+
+	for _, test := range tests {
+		s := writeCompletion(t, p, test.shell)
+
+		for _, e := range test.expected {
+			if !strings.Contains(s, e) {
+				t.Errorf("Expected %s completion to contain %q, got:\n%s", test.shell, e, s)
+			}
+		}
+	}
+
+	dynamic := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"--o"}, []string{"--output", "--out"}},
+		{[]string{"-O"}, []string{"-O"}},
+		{[]string{"--fmt", "t"}, []string{"text"}},
+		{[]string{"--fmt=j"}, []string{"--fmt=json"}},
+	}
+
+	for _, test := range dynamic {
+		items := completionItems(p.complete(test.args))
+
+		if strings.Join(items, " ") != strings.Join(test.expected, " ") {
+			t.Errorf("Expected completions %v for %v but got %v", test.expected, test.args, items)
+		}
+	}
+}
+
+func completionItems(completions []Completion) []string {
+	var ret []string
+
+	for _, c := range completions {
+		ret = append(ret, c.Item)
+	}
+
+	return ret
+}
+
+func TestCompletionDynamic(t *testing.T) {
+	p := newCompletionParser()
+	p.addHelpGroup()
+
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"--"}, []string{"--help", "--verbose", "--output", "--level", "--host", "--config", "--dir", "--format"}},
+		{[]string{"--ve"}, []string{"--verbose"}},
+		{[]string{"-"}, []string{"-h", "--help", "-v", "--verbose", "-o", "--output", "--level", "--host", "--config", "--dir", "--format"}},
+		{[]string{"--host", "b"}, []string{"beta", "bravo"}},
+		{[]string{"--host=a"}, []string{"--host=alpha"}},
+		{[]string{"--format", ""}, []string{"json", "text"}},
+		{[]string{"--format=t"}, []string{"--format=text"}},
+		{[]string{"--se"}, nil},
+		{[]string{"--secret", ""}, nil},
+		{[]string{"--secret", "x", "--ve"}, []string{"--verbose"}},
+		{[]string{"--secret="}, nil},
+		{[]string{"-o", "--ve"}, nil},
+		{[]string{"-o", "file", "--ve"}, []string{"--verbose"}},
+		{[]string{"-vo", "--ve"}, nil},
+		{[]string{"-ofile", "--ve"}, []string{"--verbose"}},
+		{[]string{"arg"}, nil},
+	}
+
+	for _, test := range tests {
+		items := completionItems(p.complete(test.args))
+
+		if strings.Join(items, " ") != strings.Join(test.expected, " ") {
+			t.Errorf("Expected completions %v for %v but got %v", test.expected, test.args, items)
+		}
+	}
+}
+
+func TestCompletionFilename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-flags")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.yaml", "b.txt", "sub/c.yaml"} {
+		name = filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(name), 0755)
+
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := newCompletionParser()
+	prefix := dir + string(filepath.Separator)
+
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"--config", prefix}, []string{prefix + "a.yaml", prefix + "sub/"}},
+		{[]string{"--dir", prefix}, []string{prefix + "sub/"}},
+		{[]string{"--output", prefix}, nil},
+	}
+
+	for _, test := range tests {
+		items := completionItems(p.complete(test.args))
+
+		if strings.Join(items, " ") != strings.Join(test.expected, " ") {
+			t.Errorf("Expected completions %v for %v but got %v", test.expected, test.args, items)
+		}
+	}
+}
+
+func TestCompletionPrintVerbose(t *testing.T) {
+	var b bytes.Buffer
+
+	p := newCompletionParser()
+	p.addHelpGroup()
+	p.printCompletions(&b, []string{"--ve"}, true)
+
+	if s := b.String(); s != "--verbose\tShow verbose debug information\n" {
+		t.Errorf("Unexpected verbose completion output %q", s)
+	}
+}
+
+func TestChoiceInvalid(t *testing.T) {
+	var opts completionOptions
+
+	p := NewNamedParser("my-prog", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--format", "text"}); err != nil || opts.Format != "text" {
+		t.Fatalf("Unexpected result for valid choice: %v (%q)", err, opts.Format)
+	}
+
+	_, err := p.ParseArgs([]string{"--format", "xml"})
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrInvalidChoice {
+		t.Fatalf("Expected ErrInvalidChoice but got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+var zshEscaper = strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+
+var zshChoiceEscaper = strings.NewReplacer("'", `'\''`, " ", `\ `, "(", `\(`, ")", `\)`, ":", `\:`)
+
+func zshEscape(s string) string {
+	return zshEscaper.Replace(s)
+}
+
+// zshValueAction returns the _arguments action which completes the argument
+// of the option.
+func (p *Parser) zshValueAction(option *Option) string {
+	if len(option.Choices) != 0 {
+		var choices []string
+
+		for _, choice := range option.Choices {
+			choices = append(choices, zshChoiceEscaper.Replace(choice))
+		}
+
+		return "(" + strings.Join(choices, " ") + ")"
+	}
+
+	if option.completer() != nil {
+		return fmt.Sprintf("{_%s_values %s}", p.completionName(), option.flagNames()[0])
+	}
+
+	if _, dirs, pattern := option.fileCompletion(); dirs {
+		return "_files -/"
+	} else if pattern != "" {
+		return fmt.Sprintf("_files -g \"%s\"", strings.Replace(pattern, "'", `'\''`, -1))
+	}
+
+	return "_files"
+}
+
+// zshOptionSpec returns the _arguments specification of the option. The
+// short and long forms of an option exclude each other, unless the option
+// can be specified more than once.
+func (p *Parser) zshOptionSpec(option *Option) string {
+	names := option.flagNames()
+
+	var exclusion string
+
+	if !option.isRepeatable() {
+		exclusion = fmt.Sprintf("(%s)", strings.Join(names, " "))
+	} else {
+		exclusion = "*"
+	}
+
+	if option.canArgument() {
+		for i, name := range names {
+			if strings.HasPrefix(name, "--") {
+				names[i] += "="
+			}
+		}
+	}
+
+	spec := fmt.Sprintf("[%s]", zshEscape(option.description()))
+
+	if option.canArgument() {
+		sep := ":"
+
+		if option.OptionalArgument {
+			sep = "::"
+		}
+
+		spec += fmt.Sprintf("%s%s:%s", sep, zshEscape(option.completionValueName()), p.zshValueAction(option))
+	}
+
+	if len(names) == 1 {
+		return "'" + exclusion + names[0] + spec + "'"
+	}
+
+	return fmt.Sprintf("'%s'{%s}'%s'", exclusion, strings.Join(names, ","), spec)
+}
+
+func (p *Parser) writeZshCompletion(writer *bufio.Writer) {
+	name := p.completionName()
+
+	fmt.Fprintf(writer, "#compdef %s\n\n", p.ApplicationName)
+
+	// Completes option arguments by calling back into the application,
+	// falling back to filenames
+	fmt.Fprintf(writer, "_%s_values() {\n", name)
+	writer.WriteString("    local -a completions\n")
+	writer.WriteString("    completions=(${(f)\"$(GO_FLAGS_COMPLETION=1 ${words[1]} \"$1\" \"$PREFIX\" 2>/dev/null)\"})\n\n")
+	writer.WriteString("    if (( ${#completions} )); then\n")
+	writer.WriteString("        compadd -a completions\n")
+	writer.WriteString("    else\n")
+	writer.WriteString("        _files\n")
+	writer.WriteString("    fi\n")
+	writer.WriteString("}\n\n")
+	fmt.Fprintf(writer, "_%s() {\n", name)
+	writer.WriteString("    _arguments -s \\\n")
+
+	for _, option := range p.completionOptions() {
+		fmt.Fprintf(writer, "        %s \\\n", p.zshOptionSpec(option))
+	}
+
+	writer.WriteString("        '*:file:_files'\n")
+	writer.WriteString("}\n\n")
+
+	fmt.Fprintf(writer, "_%s \"$@\"\n", name)
+}
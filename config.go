@@ -0,0 +1,236 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigError contains information on where an error occurred while
+// reading option values from a configuration file.
+type ConfigError struct {
+	// The error message
+	Message string
+
+	// The filename of the file in which the error occurred
+	File string
+
+	// The line number at which the error occurred (0 if unknown)
+	LineNumber uint
+
+	// The path of keys leading to the value which caused the error
+	// (e.g. "Application Options.verbose")
+	Key string
+
+	// The underlying error (e.g. the *Error of the option), if any
+	Err error
+}
+
+// Get the error message including the location of the error.
+func (e *ConfigError) Error() string {
+	var loc []string
+
+	if e.File != "" {
+		loc = append(loc, e.File)
+	}
+
+	if e.LineNumber != 0 {
+		loc = append(loc, strconv.FormatUint(uint64(e.LineNumber), 10))
+	}
+
+	if e.Key != "" {
+		loc = append(loc, e.Key)
+	}
+
+	if len(loc) == 0 {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", strings.Join(loc, ":"), e.Message)
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// parseConfigBool parses a boolean value as found in configuration files.
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// setConfigValue sets the value of the option from its representation in a
+// configuration file. Boolean options are set to the value and other
+// options without an argument are set when the value is true, while
+// counter options are set to the value.
+func (p *Parser) setConfigValue(option *Option, value string) error {
+	if option.canArgument() || option.counter != 0 {
+		return option.marshalError(p.setOption(option, &value))
+	}
+
+	b, err := parseConfigBool(value)
+
+	if err != nil {
+		return newOptionError(ErrMarshal, option,
+			fmt.Sprintf("invalid boolean value `%s' for option `%s'", value, option))
+	}
+
+	if b {
+		return option.marshalError(p.setOption(option, nil))
+	} else if option.value.Kind() == reflect.Bool {
+		// Set the option like any other value, e.g. to validate it
+		v := "false"
+		return option.marshalError(p.setOption(option, &v))
+	}
+
+	return nil
+}
+
+// configString converts a scalar value of a decoded configuration file to
+// its string representation.
+func configString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	}
+
+	return fmt.Sprint(value)
+}
+
+// setConfig sets the option from a decoded configuration value. Lists set
+// repeatable options once for each element and maps set map options once
+// for each key.
+func (p *Parser) setConfig(option *Option, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		if !option.isRepeatable() {
+			return newOptionError(ErrOccurrences, option,
+				fmt.Sprintf("option `%s' cannot be specified more than once", option))
+		}
+
+		for _, elem := range v {
+			if err := p.setConfig(option, elem); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case map[string]interface{}:
+		if option.value.Kind() != reflect.Map {
+			return newOptionError(ErrMarshal, option,
+				fmt.Sprintf("option `%s' does not accept a map of values", option))
+		}
+
+		for _, key := range sortedConfigKeys(v) {
+			if err := p.setConfigValue(option, key+":"+configString(v[key])); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return p.setConfigValue(option, configString(value))
+}
+
+func sortedConfigKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// applyConfig sets the options of the parser from a decoded configuration
+// file. Keys of the top level map are either group names (case insensitive),
+// mapping to the option values of that group, or long option names, which
+// are looked up in all the groups. Options which were already set by another
+// source (e.g. the command line) are not modified, such that those take
+// precedence over configuration files.
+func (p *Parser) applyConfig(values map[string]interface{}, filename string) error {
+	l := newConfigLayer(filename)
+
+	for _, key := range sortedConfigKeys(values) {
+		value := values[key]
+
+		if m, ok := value.(map[string]interface{}); ok {
+			if grp := p.groupByName(key); grp != nil {
+				for _, name := range sortedConfigKeys(m) {
+					if err := p.applyConfigOption(l, []*Group{grp}, name, m[name], filename, key+"."+name); err != nil {
+						return err
+					}
+				}
+
+				continue
+			}
+		}
+
+		if err := p.applyConfigOption(l, p.groups(), key, value, filename, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) applyConfigOption(l *layer, groups []*Group, name string, value interface{}, filename string, path string) error {
+	var option *Option
+
+	for _, grp := range groups {
+		if option = grp.LongNames[name]; option != nil {
+			break
+		}
+	}
+
+	if option == nil {
+		return &ConfigError{
+			Message: fmt.Sprintf("unknown option `%s'", name),
+			File:    filename,
+			Key:     path,
+		}
+	}
+
+	origin := Origin{
+		Type: OriginFile,
+		File: filename,
+		Name: path,
+	}
+
+	if !p.claimOption(option, l, origin) {
+		return nil
+	}
+
+	if err := p.setConfig(option, value); err != nil {
+		return &ConfigError{
+			Message: err.Error(),
+			File:    filename,
+			Key:     path,
+			Err:     err,
+		}
+	}
+
+	return nil
+}
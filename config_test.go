@@ -0,0 +1,1068 @@
+package flags
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type configOptions struct {
+	Verbose []bool            `short:"v" long:"verbose" description:"Show verbose debug information"`
+	Enabled bool              `long:"enabled" description:"Enabled"`
+	Name    string            `short:"n" long:"name" description:"Name"`
+	Values  []int             `long:"value" description:"Values"`
+	Map     map[string]string `long:"map" description:"Map"`
+}
+
+type configOtherOptions struct {
+	Level int `long:"level" description:"Level"`
+}
+
+func newConfigParser(opts *configOptions, other *configOtherOptions) *Parser {
+	return NewNamedParser("test", None,
+		NewGroup("Application Options", opts),
+		NewGroup("Other Options", other))
+}
+
+func TestYAML(t *testing.T) {
+	var opts configOptions
+	var other configOtherOptions
+
+	opts.Enabled = true
+
+	yaml := `---
+# comment
+Application Options:
+    verbose: [true, yes]
+    enabled: false   # trailing comment
+    name: "hello # world"
+    value:
+    - 1
+    - 2
+    map: {a: b, "c": d}
+
+level: 3
+`
+
+	if err := newConfigParser(&opts, &other).ParseYAML(strings.NewReader(yaml)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := configOptions{
+		Verbose: []bool{true, true},
+		Name:    "hello # world",
+		Values:  []int{1, 2},
+		Map:     map[string]string{"a": "b", "c": "d"},
+	}
+
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, opts)
+	}
+
+	if other.Level != 3 {
+		t.Errorf("Expected level 3 but got %d", other.Level)
+	}
+}
+
+func TestYAMLBeneathCommandLine(t *testing.T) {
+	var opts configOptions
+	var other configOtherOptions
+
+	p := newConfigParser(&opts, &other)
+
+	if _, err := p.ParseArgs([]string{"-n", "cli"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := p.ParseYAML(strings.NewReader("name: yaml\nlevel: 2\n")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Name != "cli" || other.Level != 2 {
+		t.Errorf("Expected command line to take precedence, got %q and %d", opts.Name, other.Level)
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		message string
+	}{
+		{"- a\n- b\n", "1: expected a mapping at the top level"},
+		{"name: a\n  level: 2\n", "2: unexpected indentation"},
+		{"name: a\nname: b\n", "2: duplicate key `name'"},
+		{"name: &a b\n", "1: unsupported YAML syntax `&a b'"},
+		{"name: [a, b\n", "1: expected `,' or `]' in flow sequence"},
+		{"unknown: a\n", "unknown: unknown option `unknown'"},
+		{"Other Options:\n    name: a\n", "Other Options.name: unknown option `name'"},
+		{"name: [a, b]\n", "name: option `-n, --name' cannot be specified more than once"},
+		{"level: a\n", "level: invalid argument for flag `--level' (expected int)"},
+	}
+
+	for _, test := range tests {
+		var opts configOptions
+		var other configOtherOptions
+
+		err := newConfigParser(&opts, &other).ParseYAML(strings.NewReader(test.yaml))
+
+		if err == nil {
+			t.Errorf("Expected error for %q", test.yaml)
+		} else if _, ok := err.(*ConfigError); !ok || err.Error() != test.message {
+			t.Errorf("Expected error %q for %q but got %q", test.message, test.yaml, err)
+		}
+	}
+}
+
+func TestTOML(t *testing.T) {
+	var opts configOptions
+	var other configOtherOptions
+
+	opts.Enabled = true
+
+	toml := `# comment
+level = 0x10
+
+["Application Options"]
+verbose = [
+    true, # first
+    true,
+]
+enabled = false   # trailing comment
+name = "hello\tworld \u00e9"
+value = [1_000, 2]
+map = { a = "b", 'c' = 'd' }
+`
+
+	if err := newConfigParser(&opts, &other).ParseTOML(strings.NewReader(toml)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := configOptions{
+		Verbose: []bool{true, true},
+		Name:    "hello\tworld \u00e9",
+		Values:  []int{1000, 2},
+		Map:     map[string]string{"a": "b", "c": "d"},
+	}
+
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, opts)
+	}
+
+	if other.Level != 16 {
+		t.Errorf("Expected level 16 but got %d", other.Level)
+	}
+}
+
+func TestTOMLErrors(t *testing.T) {
+	tests := []struct {
+		toml    string
+		message string
+	}{
+		{"[[Application Options]]\n", "1: arrays of tables are not supported"},
+		{"name = \"a\nlevel = 2\n", "1: unterminated string"},
+		{"name = 'a'\nname = 'b'\n", "2: duplicate key `name'"},
+		{"\nname = 'a' 'b'\n", "2: expected end of line"},
+		{"level = 012\n", "1: invalid value `012'"},
+		{"name = \"\\q\"\n", "1: invalid escape sequence \\q"},
+		{"unknown = 1\n", "unknown: unknown option `unknown'"},
+	}
+
+	for _, test := range tests {
+		var opts configOptions
+		var other configOtherOptions
+
+		err := newConfigParser(&opts, &other).ParseTOML(strings.NewReader(test.toml))
+
+		if err == nil {
+			t.Errorf("Expected error for %q", test.toml)
+		} else if _, ok := err.(*ConfigError); !ok || err.Error() != test.message {
+			t.Errorf("Expected error %q for %q but got %q", test.message, test.toml, err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var opts configOptions
+	var other configOtherOptions
+
+	opts.Enabled = true
+
+	json := `{
+    "level": 3,
+    "Application Options": {
+        "verbose": [true, true],
+        "enabled": false,
+        "name": "hello",
+        "value": [1, 2],
+        "map": {"a": "b", "c": 1.5}
+    }
+}`
+
+	if err := newConfigParser(&opts, &other).ParseJSON(strings.NewReader(json)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := configOptions{
+		Verbose: []bool{true, true},
+		Name:    "hello",
+		Values:  []int{1, 2},
+		Map:     map[string]string{"a": "b", "c": "1.5"},
+	}
+
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, opts)
+	}
+
+	if other.Level != 3 {
+		t.Errorf("Expected level 3 but got %d", other.Level)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		json    string
+		message string
+	}{
+		{"[1, 2]", "1: expected an object at the top level"},
+		{"{\n\"name\": \"a\",\n}", "3: invalid character '}' looking for beginning of object key string"},
+		{"{\"level\": true}", "level: invalid argument for flag `--level' (expected int)"},
+	}
+
+	for _, test := range tests {
+		var opts configOptions
+		var other configOtherOptions
+
+		err := newConfigParser(&opts, &other).ParseJSON(strings.NewReader(test.json))
+
+		if err == nil {
+			t.Errorf("Expected error for %q", test.json)
+		} else if _, ok := err.(*ConfigError); !ok || err.Error() != test.message {
+			t.Errorf("Expected error %q for %q but got %q", test.message, test.json, err)
+		}
+	}
+}
+
+type sourcesOptions struct {
+	Name   string   `long:"name" env:"GO_FLAGS_TEST_NAME"`
+	Level  int      `long:"level" env:"GO_FLAGS_TEST_LEVEL"`
+	Values []string `long:"value" env:"GO_FLAGS_TEST_VALUES" env-delim:","`
+	Other  string   `long:"other"`
+}
+
+func TestParseSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-flags")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.yaml")
+
+	if err := ioutil.WriteFile(filename, []byte("name: file\nlevel: 1\nvalue: [x]\nother: file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("GO_FLAGS_TEST_LEVEL", "2")
+	os.Setenv("GO_FLAGS_TEST_VALUES", "a,b")
+	defer os.Unsetenv("GO_FLAGS_TEST_LEVEL")
+	defer os.Unsetenv("GO_FLAGS_TEST_VALUES")
+
+	var opts sourcesOptions
+	opts.Name = "default"
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	args, err := p.ParseSources(
+		CommandLineSource([]string{"--level", "3", "rest"}),
+		EnvironmentSource(),
+		FileSource(filename),
+		OptionalFileSource(filepath.Join(dir, "missing.json")))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := sourcesOptions{
+		Name:   "file",
+		Level:  3,
+		Values: []string{"a", "b"},
+		Other:  "file",
+	}
+
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, opts)
+	}
+
+	if len(args) != 1 || args[0] != "rest" {
+		t.Errorf("Expected remaining arguments [rest] but got %v", args)
+	}
+
+	origins := map[string]string{
+		"name":  filename + " (name)",
+		"level": "command line (--level)",
+		"value": "environment ($GO_FLAGS_TEST_VALUES)",
+	}
+
+	for name, origin := range origins {
+		if s := p.Groups[0].LongNames[name].Origin().String(); s != origin {
+			t.Errorf("Expected origin %q for %s but got %q", origin, name, s)
+		}
+	}
+
+	// The environment can take precedence over the command line
+	var opts2 sourcesOptions
+	p = NewNamedParser("test", None, NewGroup("Application Options", &opts2))
+
+	if _, err := p.ParseSources(EnvironmentSource(), CommandLineSource([]string{"--level", "3", "--value", "c"})); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts2.Level != 2 || !reflect.DeepEqual(opts2.Values, []string{"a", "b"}) {
+		t.Errorf("Expected environment to take precedence but got %+v", opts2)
+	}
+
+	if _, err := p.ParseSources(FileSource("config.xml")); err != ErrUnknownConfigFormat {
+		t.Errorf("Expected ErrUnknownConfigFormat but got %v", err)
+	}
+}
+
+func TestEditOperators(t *testing.T) {
+	type options struct {
+		Tags   []string       `long:"tag"`
+		Labels map[string]int `long:"label"`
+		Port   int            `long:"port"`
+	}
+
+	opts := options{Tags: []string{"a", "b"}}
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--tag+=c", "--tag-=a", "--label=x:1", "--label+=y:2"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(opts.Tags, []string{"b", "c"}) || !reflect.DeepEqual(opts.Labels, map[string]int{"x": 1, "y": 2}) {
+		t.Errorf("Expected the values to be edited but got %+v", opts)
+	}
+
+	if s := p.Groups[0].LongNames["tag"].Origin().String(); s != "command line (--tag)" {
+		t.Errorf("Expected the command line to be the origin of edited values but got %q", s)
+	}
+
+	// Edits apply to the values of sources of lower precedence
+	dir, err := ioutil.TempDir("", "go-flags")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.yaml")
+
+	if err := ioutil.WriteFile(filename, []byte("tag: [x, y, z]\nlabel: {x: 1, y: 2}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var opts2 options
+	p = NewNamedParser("test", None, NewGroup("Application Options", &opts2))
+
+	if _, err := p.ParseSources(CommandLineSource([]string{"--tag-=y", "--tag+=w", "--label-=x"}), FileSource(filename)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(opts2.Tags, []string{"x", "z", "w"}) || !reflect.DeepEqual(opts2.Labels, map[string]int{"y": 2}) {
+		t.Errorf("Expected the values of the file to be edited but got %+v", opts2)
+	}
+
+	// Values of configuration files parsed before the command line are
+	// replaced by plain values and edited by operators
+	for _, test := range []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"--tag", "c"}, []string{"c"}},
+		{[]string{"--tag+=c"}, []string{"a", "b", "c"}},
+		{[]string{"--tag-=a", "--tag+=c"}, []string{"b", "c"}},
+	} {
+		var opts3 options
+		p := NewNamedParser("test", None, NewGroup("Application Options", &opts3))
+
+		if err := NewIniParser(p).Parse(strings.NewReader("[Application Options]\ntag = a\ntag = b\n")); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if _, err := p.ParseArgs(test.args); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if !reflect.DeepEqual(opts3.Tags, test.expected) {
+			t.Errorf("Expected %v for %v after the ini file but got %v", test.expected, test.args, opts3.Tags)
+		}
+	}
+
+	for _, arg := range []string{"--port+=1", "--port-=1", "--tag+"} {
+		if _, err := p.ParseArgs([]string{arg}); err == nil {
+			t.Errorf("Expected an error for %s", arg)
+		}
+	}
+}
+
+func TestConfigFileOption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-flags")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.json")
+
+	if err := ioutil.WriteFile(filename, []byte(`{"name": "file", "level": 1, "value": ["x"], "other": "file"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var opts struct {
+		Name   string   `long:"name"`
+		Level  int      `long:"level"`
+		Values []string `long:"value"`
+		Other  string   `long:"other"`
+		Config string   `long:"config" config-file:"true"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	args := []string{"--name", "cli", "--config", filename, "--level", "2", "--value", "y"}
+
+	if _, err := p.ParseArgs(args); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Name != "cli" || opts.Level != 2 || opts.Other != "file" || !reflect.DeepEqual(opts.Values, []string{"y"}) {
+		t.Errorf("Expected the command line to take precedence but got %+v", opts)
+	}
+
+	expected := Origin{Type: OriginFile, File: filename, Name: "other"}
+
+	if origin := p.Groups[0].LongNames["other"].Origin(); origin != expected {
+		t.Errorf("Expected origin %+v but got %+v", expected, origin)
+	}
+
+	if _, err := p.ParseArgs([]string{"--config", filepath.Join(dir, "missing.json")}); err == nil {
+		t.Errorf("Expected error for missing configuration file")
+	}
+}
+
+func TestDotEnv(t *testing.T) {
+	dotenv := `# comment
+export GO_FLAGS_TEST_NAME = "hello \"world\"\n"
+GO_FLAGS_TEST_LEVEL=3 # trailing comment
+GO_FLAGS_TEST_VALUES='a,#b'
+EMPTY=
+`
+
+	vars, err := ParseDotEnv(strings.NewReader(dotenv))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"GO_FLAGS_TEST_NAME":   "hello \"world\"\n",
+		"GO_FLAGS_TEST_LEVEL":  "3",
+		"GO_FLAGS_TEST_VALUES": "a,#b",
+		"EMPTY":                "",
+	}
+
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v but got %v", expected, vars)
+	}
+
+	tests := []struct {
+		dotenv  string
+		message string
+	}{
+		{"NAME", "1: malformed NAME=value (NAME)"},
+		{"\n1NAME=a", "2: invalid variable name `1NAME'"},
+		{"NAME=\"a", "1: unterminated quoted value"},
+		{"NAME='a' b", "1: unexpected `b' after quoted value"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseDotEnv(strings.NewReader(test.dotenv))
+
+		if err == nil {
+			t.Errorf("Expected error for %q", test.dotenv)
+		} else if _, ok := err.(*ConfigError); !ok || err.Error() != test.message {
+			t.Errorf("Expected error %q for %q but got %q", test.message, test.dotenv, err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "go-flags")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, ".env")
+
+	if err := ioutil.WriteFile(filename, []byte(dotenv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("GO_FLAGS_TEST_LEVEL", "2")
+	defer os.Unsetenv("GO_FLAGS_TEST_LEVEL")
+
+	var opts sourcesOptions
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseSources(EnvironmentSource(), DotEnvSource(filename), OptionalDotEnvSource(filepath.Join(dir, "missing"))); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Name != expected["GO_FLAGS_TEST_NAME"] || opts.Level != 2 || !reflect.DeepEqual(opts.Values, []string{"a", "#b"}) {
+		t.Errorf("Unexpected options from .env file: %+v", opts)
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	var opts configOptions
+	var other configOtherOptions
+
+	p := newConfigParser(&opts, &other)
+
+	if _, err := p.ParseArgs([]string{"-n", "cli", "-vv"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := p.ParseYAML(strings.NewReader("level: 2\n")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var b bytes.Buffer
+
+	if err := p.WriteConfig(&b, ConfigText); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := `Application Options:
+  -v, --verbose  [true, true]  command line (-v)
+  --enabled      false         default
+  -n, --name     cli           command line (-n)
+  --value        []            default
+  --map          []            default
+
+Other Options:
+  --level  2  configuration (level)
+`
+
+	if b.String() != expected {
+		t.Errorf("Expected config:\n%s\nbut got:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+
+	if err := p.WriteConfig(&b, ConfigJSON); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var config map[string]map[string]map[string]interface{}
+
+	if err := json.Unmarshal(b.Bytes(), &config); err != nil {
+		t.Fatalf("Unexpected error decoding JSON: %s", err)
+	}
+
+	if name := config["Application Options"]["name"]; name["value"] != "cli" || name["origin"] != "command line" || name["name"] != "-n" {
+		t.Errorf("Unexpected JSON for name: %v", name)
+	}
+
+	if level := config["Other Options"]["level"]; level["value"] != 2.0 || level["origin"] != "file" {
+		t.Errorf("Unexpected JSON for level: %v", level)
+	}
+
+	b.Reset()
+
+	if err := p.WriteConfig(&b, ConfigIni); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !strings.Contains(b.String(), "; Name\n; origin: command line (-n)\nname = cli\n") {
+		t.Errorf("Expected origin in ini but got:\n%s", b.String())
+	}
+}
+
+func TestValues(t *testing.T) {
+	var opts struct {
+		Name  string   `short:"n" long:"name"`
+		Level int      `short:"l"`
+		Tags  []string `long:"tag"`
+		Run   func()   `long:"run"`
+
+		Output struct {
+			Format string `long:"format" default:"text"`
+		} `group:"Output Options"`
+	}
+
+	p := NewNamedParser("test", HelpFlag, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"-n", "cli", "-l", "2", "--tag", "a"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	values := p.Values()
+
+	expected := map[string]interface{}{
+		"Application Options": map[string]interface{}{
+			"name": "cli",
+			"l":    2,
+			"tag":  []string{"a"},
+			"Output Options": map[string]interface{}{
+				"format": "text",
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected values %v but got %v", expected, values)
+	}
+
+	opts.Tags[0] = "b"
+
+	if tag := values["Application Options"].(map[string]interface{})["tag"]; !reflect.DeepEqual(tag, []string{"a"}) {
+		t.Errorf("Expected the snapshot not to change but got %v", tag)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	var opts struct {
+		Port    uint          `short:"p" long:"port" description:"Port" default:"8080" max:"65535" env:"PORT"`
+		Mode    string        `long:"mode" choice:"fast" choice:"slow" required:"true"`
+		Timeout time.Duration `long:"timeout" default:"1m"`
+		Tags    []string      `long:"tag" pattern:"^[a-z]+$"`
+		Token   string        `long:"token" default:"t0ken" secret:"true"`
+		Run     func()        `long:"run"`
+	}
+
+	p := NewNamedParser("test", HelpFlag, NewGroup("Application Options", &opts))
+
+	spec := p.Spec()
+
+	if len(spec.Groups) != 2 || spec.Groups[0].Name != "Help Options" || len(spec.Groups[1].Options) != 6 {
+		t.Fatalf("Unexpected groups: %v", spec.Groups)
+	}
+
+	port := spec.Groups[1].Options[0]
+
+	if port.ShortName != "p" || port.Type != "uint" || port.Default != uint(8080) || port.EnvName != "PORT" || !port.HasArgument {
+		t.Errorf("Unexpected spec of port: %+v", port)
+	}
+
+	if token := spec.Groups[1].Options[4]; token.Default != nil || !token.Secret {
+		t.Errorf("Expected the default of the secret to be omitted but got %+v", token)
+	}
+
+	var b bytes.Buffer
+
+	if err := p.WriteSpec(&b); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if strings.Contains(b.String(), "t0ken") || !strings.Contains(b.String(), `"timeout",`) {
+		t.Errorf("Unexpected spec:\n%s", b.String())
+	}
+
+	b.Reset()
+
+	if err := p.WriteJSONSchema(&b); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var schema struct {
+		Properties map[string]struct {
+			Properties map[string]map[string]interface{}
+			Required   []string
+		}
+	}
+
+	if err := json.Unmarshal(b.Bytes(), &schema); err != nil {
+		t.Fatalf("Unexpected error decoding JSON: %s", err)
+	}
+
+	if _, ok := schema.Properties["Help Options"]; ok {
+		t.Errorf("Expected function options to be omitted from the schema")
+	}
+
+	grp := schema.Properties["Application Options"]
+
+	expected := map[string]string{
+		"port":  `{"default":8080,"description":"Port","maximum":65535,"minimum":0,"type":"integer"}`,
+		"mode":  `{"enum":["fast","slow"],"type":"string"}`,
+		"tag":   `{"items":{"pattern":"^[a-z]+$","type":"string"},"type":"array"}`,
+		"token": `{"type":"string","writeOnly":true}`,
+	}
+
+	for name, e := range expected {
+		data, _ := json.Marshal(grp.Properties[name])
+
+		if string(data) != e {
+			t.Errorf("Expected schema of %s to be %s but got %s", name, e, data)
+		}
+	}
+
+	if _, ok := grp.Properties["run"]; ok {
+		t.Errorf("Expected the function option to be omitted from the schema")
+	}
+
+	if def := grp.Properties["timeout"]["default"]; def != "1m0s" {
+		t.Errorf("Expected the default of timeout to be 1m0s but got %v", def)
+	}
+
+	if !reflect.DeepEqual(grp.Required, []string{"mode"}) {
+		t.Errorf("Expected mode to be required but got %v", grp.Required)
+	}
+}
+
+func TestCompareSpecs(t *testing.T) {
+	var previous struct {
+		Port    int      `short:"p" long:"port" default:"80" env:"PORT"`
+		Mode    string   `long:"mode" choice:"fast" choice:"slow"`
+		Level   int      `long:"level" max:"10"`
+		Include []string `short:"I" long:"include"`
+		Old     bool     `long:"old"`
+	}
+
+	var current struct {
+		Port    string   `long:"port" default:"8080" env:"APP_PORT"`
+		Mode    string   `long:"mode" choice:"fast" choice:"safe"`
+		Level   int64    `long:"level" max:"5"`
+		Include []string `short:"I" long:"include" long-alias:"inc"`
+		User    string   `long:"user" required:"true"`
+	}
+
+	var b bytes.Buffer
+
+	if err := NewNamedParser("test", None, NewGroup("Application Options", &previous)).WriteSpec(&b); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	spec, err := ReadSpec(&b)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	changes := CompareSpecs(spec, NewNamedParser("test", None, NewGroup("Options", &current)).Spec())
+
+	var descriptions []string
+
+	for _, change := range changes {
+		descriptions = append(descriptions, change.String())
+	}
+
+	expected := []string{
+		"--port: flag -p was removed (breaking)",
+		"--port: type changed from int to string (breaking)",
+		"--port: environment variable PORT was renamed to APP_PORT (breaking)",
+		`--port: default changed from 80 to "8080"`,
+		"--mode: choices were removed: slow (breaking)",
+		"--mode: choices were added: safe",
+		"--level: type changed from int to int64",
+		"--level: maximum was lowered to 5 (breaking)",
+		"--include: flag --inc was added",
+		"--old: option was removed (breaking)",
+		"--user: required option was added (breaking)",
+	}
+
+	if !reflect.DeepEqual(descriptions, expected) {
+		t.Errorf("Expected changes:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(descriptions, "\n"))
+	}
+
+	if changes := CompareSpecs(spec, spec); changes != nil {
+		t.Errorf("Expected no changes but got %v", changes)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	var opts struct {
+		Token string             `long:"token" description:"API token" default:"t0ken" secret:"true" choice:"a" choice:"t0ken"`
+		PIN   int                `long:"pin" secret:"true"`
+		Key   func(string) error `long:"key" secret:"true"`
+	}
+
+	opts.Key = func(key string) error {
+		return fmt.Errorf("invalid key %s", key)
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if !strings.Contains(b.String(), "API token (***)") || strings.Contains(b.String(), "t0ken") {
+		t.Errorf("Expected the default to be masked but got:\n%s", b.String())
+	}
+
+	for _, format := range []ConfigFormat{ConfigText, ConfigJSON, ConfigIni} {
+		b.Reset()
+
+		if err := p.WriteConfig(&b, format); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if strings.Contains(b.String(), "t0ken") || !strings.Contains(b.String(), "***") {
+			t.Errorf("Expected the value to be masked but got:\n%s", b.String())
+		}
+	}
+
+	tests := []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"--token", "s3cret"}, "invalid argument `***' for flag `--token' (expected one of: a, t0ken)"},
+		{[]string{"--pin", "12x4"}, "invalid argument for flag `--pin' (expected int)"},
+		{[]string{"--key", "k3y"}, "invalid argument for flag `--key'"},
+	}
+
+	for _, test := range tests {
+		_, err := p.ParseArgs(test.args)
+
+		if err == nil || err.Error() != test.message {
+			t.Errorf("Expected error %q but got %v", test.message, err)
+		}
+	}
+}
+
+func TestSecretTrace(t *testing.T) {
+	var opts struct {
+		Token   string `short:"t" long:"token" secret:"true"`
+		Verbose bool   `short:"v"`
+	}
+
+	var messages []string
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	p.Trace = func(message string) {
+		messages = append(messages, message)
+	}
+
+	if _, err := p.ParseArgs([]string{"--token=hunter2", "-vthunter2", "-t=hunter2", "-t", "hunter2", "-v"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	trace := strings.Join(messages, "\n")
+
+	for _, expected := range []string{"argument `--token=***'", "argument `-vt***'", "argument `-t=***'", "argument `-t'", "argument `-v'"} {
+		if !strings.Contains(trace, expected) {
+			t.Errorf("Expected %s in the trace:\n%s", expected, trace)
+		}
+	}
+
+	if strings.Contains(trace, "hunter2") {
+		t.Errorf("Expected the secret to be masked in the trace:\n%s", trace)
+	}
+}
+
+func TestValuesFromFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-flags")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "token")
+
+	if err := ioutil.WriteFile(filename, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var opts struct {
+		Token string `long:"token"`
+		User  string `long:"user"`
+	}
+
+	p := NewNamedParser("test", ValuesFromFiles, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--token", "@" + filename, "--user", "@@jane"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Token != "s3cret" || opts.User != "@jane" {
+		t.Errorf("Expected the token to be read from the file but got %+v", opts)
+	}
+
+	_, err = p.ParseArgs([]string{"--token", "@" + filepath.Join(dir, "missing")})
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrMarshal || !os.IsNotExist(errors.Unwrap(err)) {
+		t.Errorf("Expected an error reading the file but got %v", err)
+	}
+
+	// Without the option, arguments are not read from files
+	p = NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--user", "@jane"}); err != nil || opts.User != "@jane" {
+		t.Errorf("Expected the argument to be used as is but got %q (%v)", opts.User, err)
+	}
+}
+
+func TestEnvironmentFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-flags")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "password")
+
+	if err := ioutil.WriteFile(filename, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	os.Setenv("GO_FLAGS_TEST_PASSWORD_FILE", filename)
+	os.Setenv("GO_FLAGS_TEST_USER", "jane")
+	os.Setenv("GO_FLAGS_TEST_USER_FILE", filepath.Join(dir, "missing"))
+	defer os.Unsetenv("GO_FLAGS_TEST_PASSWORD_FILE")
+	defer os.Unsetenv("GO_FLAGS_TEST_USER")
+	defer os.Unsetenv("GO_FLAGS_TEST_USER_FILE")
+
+	var opts struct {
+		Password string `long:"password" env:"GO_FLAGS_TEST_PASSWORD"`
+		User     string `long:"user" env:"GO_FLAGS_TEST_USER"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseSources(EnvironmentSource()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// The variable itself takes precedence over the file
+	if opts.Password != "s3cret" || opts.User != "jane" {
+		t.Errorf("Expected the password to be read from the file but got %+v", opts)
+	}
+
+	expected := filename + " ($GO_FLAGS_TEST_PASSWORD_FILE)"
+
+	if origin := p.Groups[0].LongNames["password"].Origin().String(); origin != expected {
+		t.Errorf("Expected origin %q but got %q", expected, origin)
+	}
+
+	os.Unsetenv("GO_FLAGS_TEST_USER")
+	p = NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	var e *ConfigError
+
+	if _, err := p.ParseSources(EnvironmentSource()); !errors.As(err, &e) || e.Key != "$GO_FLAGS_TEST_USER_FILE" {
+		t.Errorf("Expected an error reading the file but got %v", err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	os.Setenv("GO_FLAGS_TEST_DIR", "/data")
+	os.Setenv("GO_FLAGS_TEST_EXPAND", "$GO_FLAGS_TEST_DIR/cache")
+	defer os.Unsetenv("GO_FLAGS_TEST_DIR")
+	defer os.Unsetenv("GO_FLAGS_TEST_EXPAND")
+
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	var opts struct {
+		Dir   string   `long:"dir" expand:"env,home"`
+		Cache string   `long:"cache" expand:"env" env:"GO_FLAGS_TEST_EXPAND"`
+		Paths []string `long:"path" expand:"home"`
+		Plain string   `long:"plain"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	_, err = p.ParseSources(
+		CommandLineSource([]string{"--dir", "~/x${GO_FLAGS_TEST_DIR}/$$x", "--path", "~", "--path", "a/~", "--plain", "~/$GO_FLAGS_TEST_DIR"}),
+		EnvironmentSource())
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []string{
+		home + "/x/data/$x",
+		"/data/cache",
+		home,
+		"a/~",
+		"~/$GO_FLAGS_TEST_DIR",
+	}
+
+	if actual := []string{opts.Dir, opts.Cache, opts.Paths[0], opts.Paths[1], opts.Plain}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected expanded values %q but got %q", expected, actual)
+	}
+}
+
+func TestRestrictedSources(t *testing.T) {
+	os.Setenv("GO_FLAGS_TEST_TOKEN", "env")
+	os.Setenv("GO_FLAGS_TEST_DEBUG", "true")
+	defer os.Unsetenv("GO_FLAGS_TEST_TOKEN")
+	defer os.Unsetenv("GO_FLAGS_TEST_DEBUG")
+
+	var opts struct {
+		Token string `long:"token" env:"GO_FLAGS_TEST_TOKEN" sources:"env,config"`
+		Debug bool   `long:"debug" env:"GO_FLAGS_TEST_DEBUG" sources:"cli"`
+	}
+
+	var warnings []*Error
+
+	newParser := func() *Parser {
+		p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+		p.Warning = func(warning *Error) {
+			warnings = append(warnings, warning)
+		}
+
+		return p
+	}
+
+	if _, err := newParser().ParseSources(EnvironmentSource()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Token != "env" || opts.Debug {
+		t.Errorf("Expected the debug option to be ignored in the environment but got %+v", opts)
+	}
+
+	if len(warnings) != 1 || warnings[0].Type != ErrRestricted || warnings[0].Option.LongName != "debug" {
+		t.Errorf("Expected a warning for the debug option but got %v", warnings)
+	}
+
+	_, err := newParser().ParseArgs([]string{"--token", "cli"})
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrRestricted || e.Message != "flag `--token' cannot be specified on the command line" {
+		t.Errorf("Expected a restricted error but got %v", err)
+	}
+
+	p := newParser()
+
+	if _, err := p.ParseArgs([]string{"--debug"}); err != nil || !opts.Debug {
+		t.Errorf("Expected the debug option to be set on the command line but got %v", err)
+	}
+
+	for _, option := range p.completionOptions() {
+		if option.LongName == "token" {
+			t.Errorf("Expected the token option not to be completed")
+		}
+	}
+}
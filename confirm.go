@@ -0,0 +1,100 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Confirmation is the type of options confirming destructive actions, such
+// as --yes or --force. Confirmation options are bool flags, which are
+// checked using Parser.Confirm before performing the action:
+//
+//	type Options struct {
+//		Yes flags.Confirmation `short:"y" long:"yes" description:"Do not ask for confirmation"`
+//	}
+//
+//	if err := parser.Confirm(&opts.Yes, "Delete all files?"); err != nil {
+//		return err
+//	}
+type Confirmation bool
+
+// Confirm returns whether the action guarded by the given confirmation
+// option is confirmed. The action is confirmed when the option is set.
+// Otherwise, the question is asked using the Confirmer of the parser or,
+// when the parser has no Confirmer and its input is interactive (see
+// Parser.Stdin), on the terminal with the answers y and N. An error of type ErrConfirmation
+// is returned when the action is declined or when the question cannot be
+// asked.
+func (p *Parser) Confirm(confirmation *Confirmation, question string) error {
+	if *confirmation {
+		return nil
+	}
+
+	option := p.optionByValue(confirmation)
+	confirmer := p.Confirmer
+
+	if confirmer == nil {
+		if !p.interactive() {
+			msg := "confirmation required"
+
+			if option != nil {
+				msg = fmt.Sprintf("confirmation required (specify flag `%s')", option)
+			}
+
+			return newOptionError(ErrConfirmation, option, msg)
+		}
+
+		confirmer = p.confirmTerminal
+	}
+
+	confirmed, err := confirmer(question)
+
+	if err != nil {
+		return newOptionError(ErrConfirmation, option,
+			fmt.Sprintf("failed to ask for confirmation: %s", err)).wrap(err)
+	}
+
+	if !confirmed {
+		return newOptionError(ErrConfirmation, option, "the action was not confirmed")
+	}
+
+	return nil
+}
+
+// confirmTerminal asks the question on the terminal, confirming the action
+// only when the answer is yes.
+func (p *Parser) confirmTerminal(question string) (bool, error) {
+	fmt.Fprintf(p.promptWriter(), "%s [y/N]: ", question)
+
+	line, err := readLine(p.stdin())
+
+	if err != nil && line == "" {
+		fmt.Fprintln(p.promptWriter())
+		return false, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// optionByValue returns the option storing its value in the variable the
+// given pointer points to, or nil if there is no such option.
+func (p *Parser) optionByValue(ptr interface{}) *Option {
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if option.value.CanAddr() && option.value.Addr().Interface() == ptr {
+				return option
+			}
+		}
+	}
+
+	return nil
+}
@@ -5,13 +5,107 @@
 package flags
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-func getBase(options reflect.StructTag, base int) (int, error) {
+// The converted value is outside of the range given by the min and max tags
+var errOutOfRange = errors.New("value out of range")
+
+// checkRange checks a converted value against the min and max tags. The
+// compare function returns the sign of the difference between the value and
+// the given bound.
+func checkRange(options *fieldTags, compare func(bound string) (int, error)) error {
+	if min := options.Get("min"); min != "" {
+		c, err := compare(min)
+
+		if err != nil {
+			return err
+		}
+
+		if c < 0 {
+			return errOutOfRange
+		}
+	}
+
+	if max := options.Get("max"); max != "" {
+		c, err := compare(max)
+
+		if err != nil {
+			return err
+		}
+
+		if c > 0 {
+			return errOutOfRange
+		}
+	}
+
+	return nil
+}
+
+// checkBounds checks that the min and max tags of an option of the given
+// type are valid values of the type, or of the type of the elements of
+// slices and maps, such that invalid bounds are reported when the group is
+// created instead of rejecting every argument.
+func checkBounds(tp reflect.Type, options *fieldTags) error {
+	if !hasRange(options) {
+		return nil
+	}
+
+	if tp.Kind() == reflect.Slice || tp.Kind() == reflect.Map {
+		tp = tp.Elem()
+	}
+
+	var parse func(bound string) error
+
+	if tp == durationType {
+		parse = func(bound string) error {
+			_, err := time.ParseDuration(bound)
+			return err
+		}
+	} else if _, ok := lookupConverter(tp); !ok {
+		switch tp.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			parse = func(bound string) error {
+				_, err := strconv.ParseInt(bound, 0, tp.Bits())
+				return err
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			parse = func(bound string) error {
+				_, err := strconv.ParseUint(bound, 0, tp.Bits())
+				return err
+			}
+		case reflect.Float32, reflect.Float64:
+			parse = func(bound string) error {
+				_, err := strconv.ParseFloat(bound, tp.Bits())
+				return err
+			}
+		}
+	}
+
+	if parse == nil {
+		return fmt.Errorf("min and max are not supported for values of type %s", tp)
+	}
+
+	for _, key := range []string{"min", "max"} {
+		if bound := options.Get(key); bound != "" {
+			if err := parse(bound); err != nil {
+				return fmt.Errorf("%s `%s' is not a valid %s", key, bound, tp)
+			}
+		}
+	}
+
+	return nil
+}
+
+func getBase(options *fieldTags, base int) (int, error) {
 	sbase := options.Get("base")
 
 	var err error
@@ -25,18 +119,35 @@ func getBase(options reflect.StructTag, base int) (int, error) {
 	return base, err
 }
 
-func convertToString(val reflect.Value, options reflect.StructTag) string {
+func convertToString(val reflect.Value, options *fieldTags) string {
 	tp := val.Type()
 
+	if c, ok := lookupConverter(tp); ok {
+		if c.Format != nil {
+			return c.Format(val.Interface())
+		}
+
+		return fmt.Sprint(val.Interface())
+	}
+
+	// Support for time.Duration
+	if tp == durationType {
+		return time.Duration(val.Int()).String()
+	}
+
+	if tp == getterType {
+		return getterString(val)
+	}
+
 	switch tp.Kind() {
 	case reflect.String:
 		return val.String()
 	case reflect.Bool:
 		return ""
-	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		base, _ := getBase(options, 10)
 		return strconv.FormatInt(val.Int(), base)
-	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		base, _ := getBase(options, 10)
 		return strconv.FormatUint(val.Uint(), base)
 	case reflect.Float32, reflect.Float64:
@@ -54,14 +165,28 @@ func convertToString(val reflect.Value, options reflect.StructTag) string {
 
 		return ret + "]"
 	case reflect.Map:
+		// The values are sorted by key, such that the result is
+		// deterministic
+		keys := make([]string, 0, val.Len())
+		values := make(map[string]string, val.Len())
+
+		for _, key := range val.MapKeys() {
+			k := convertToString(key, options)
+
+			keys = append(keys, k)
+			values[k] = convertToString(val.MapIndex(key), options)
+		}
+
+		sort.Strings(keys)
+
 		ret := "{"
 
-		for i, key := range val.MapKeys() {
+		for i, k := range keys {
 			if i != 0 {
 				ret += ", "
 			}
 
-			ret += convertToString(val.MapIndex(key), options)
+			ret += values[k]
 		}
 
 		return ret + "}"
@@ -70,103 +195,361 @@ func convertToString(val reflect.Value, options reflect.StructTag) string {
 	return ""
 }
 
-func convert(val string, retval reflect.Value, options reflect.StructTag) error {
-	tp := retval.Type()
+// isSupportedType returns whether values of the given type can be converted
+// from strings.
+func isSupportedType(tp reflect.Type) bool {
+	if _, ok := lookupConverter(tp); ok {
+		return true
+	}
 
 	switch tp.Kind() {
-	case reflect.String:
-		retval.SetString(val)
-	case reflect.Bool:
-		retval.SetBool(true)
-	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
-		base, err := getBase(options, 10)
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return isSupportedType(tp.Elem())
+	case reflect.Map:
+		return isSupportedType(tp.Key()) && isSupportedType(tp.Elem())
+	}
 
-		if err != nil {
-			return err
-		}
+	return false
+}
 
-		parsed, err := strconv.ParseInt(val, base, tp.Bits())
+// A converter converts a string into a value of a specific kind.
+type converter func(val string, retval reflect.Value, options *fieldTags) error
 
-		if err != nil {
-			return err
-		}
+// converters are the converters of the supported kinds of values, indexed by
+// kind. They are initialized in init since converting slices and maps refers
+// back to convert.
+var converters [reflect.UnsafePointer + 1]converter
 
-		retval.SetInt(parsed)
-	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		base, err := getBase(options, 10)
+var durationType = reflect.TypeOf(time.Duration(0))
 
-		if err != nil {
-			return err
-		}
+// A Converter converts strings to and from values of a custom type (see
+// RegisterConverter).
+type Converter struct {
+	// Parse converts an argument into a value of the type
+	Parse func(s string) (interface{}, error)
 
-		parsed, err := strconv.ParseUint(val, base, tp.Bits())
+	// Format converts a value of the type into a string, which is used to
+	// show default values and to write ini files. When Format is nil, the
+	// value is formatted using fmt.Sprint
+	Format func(v interface{}) string
+}
 
-		if err != nil {
-			return err
+// The registered converters are stored in a map which is replaced, instead
+// of modified, when a converter is registered. Converters are therefore
+// looked up without locking.
+var registeredConverters struct {
+	sync.Mutex
+	m atomic.Pointer[map[reflect.Type]Converter]
+}
+
+// RegisterConverter registers the converter of options of the given type,
+// which can then be used like the builtin types, including as elements of
+// slices and maps. The converter takes precedence over the conversion based
+// on the kind of the type, and replaces any converter previously registered
+// for the type.
+//
+// RegisterConverter can be called concurrently with other registrations and
+// with parsing, such that converters can be registered from init functions
+// and from parallel tests.
+func RegisterConverter(tp reflect.Type, converter Converter) {
+	registeredConverters.Lock()
+	defer registeredConverters.Unlock()
+
+	m := make(map[reflect.Type]Converter)
+
+	if old := registeredConverters.m.Load(); old != nil {
+		for t, c := range *old {
+			m[t] = c
 		}
+	}
 
-		retval.SetUint(parsed)
-	case reflect.Float32, reflect.Float64:
-		parsed, err := strconv.ParseFloat(val, tp.Bits())
+	m[tp] = converter
+	registeredConverters.m.Store(&m)
+}
+
+func lookupConverter(tp reflect.Type) (Converter, bool) {
+	m := registeredConverters.m.Load()
+
+	if m == nil {
+		return Converter{}, false
+	}
+
+	c, ok := (*m)[tp]
+	return c, ok
+}
+
+// convertRegistered converts the value using a registered converter.
+func convertRegistered(c Converter, val string, retval reflect.Value) error {
+	v, err := c.Parse(val)
+
+	if err != nil {
+		return err
+	}
+
+	if v == nil {
+		retval.Set(reflect.Zero(retval.Type()))
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	if !rv.Type().ConvertibleTo(retval.Type()) {
+		return fmt.Errorf("converter of %s returned a value of type %s", retval.Type(), rv.Type())
+	}
+
+	retval.Set(rv.Convert(retval.Type()))
+	return nil
+}
+
+func init() {
+	converters[reflect.String] = convertString
+	converters[reflect.Bool] = convertBool
+
+	for _, kind := range []reflect.Kind{reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64} {
+		converters[kind] = convertInt
+	}
+
+	for _, kind := range []reflect.Kind{reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64} {
+		converters[kind] = convertUint
+	}
+
+	converters[reflect.Float32] = convertFloat
+	converters[reflect.Float64] = convertFloat
+	converters[reflect.Slice] = convertSlice
+	converters[reflect.Map] = convertMap
+}
+
+func convert(val string, retval reflect.Value, options *fieldTags) error {
+	tp := retval.Type()
+
+	if c, ok := lookupConverter(tp); ok {
+		return convertRegistered(c, val, retval)
+	}
+
+	// Special cases, which take precedence over the kind of the type
+	switch tp {
+	case getterType:
+		return setGetter(retval, val)
+	case durationType:
+		return convertDuration(val, retval, options)
+	}
+
+	if c := converters[tp.Kind()]; c != nil {
+		return c(val, retval, options)
+	}
+
+	return nil
+}
+
+// hasRange returns whether the min or max tags are present, such that values
+// without a range are not checked.
+func hasRange(options *fieldTags) bool {
+	return options.Get("min") != "" || options.Get("max") != ""
+}
+
+func convertString(val string, retval reflect.Value, options *fieldTags) error {
+	retval.SetString(val)
+	return nil
+}
+
+// convertBool sets a boolean option which is specified without an argument
+// to true, and parses other values, such as defaults, using
+// strconv.ParseBool.
+func convertBool(val string, retval reflect.Value, options *fieldTags) error {
+	if val == "" {
+		retval.SetBool(true)
+		return nil
+	}
+
+	b, err := strconv.ParseBool(val)
+
+	if err != nil {
+		return err
+	}
+
+	retval.SetBool(b)
+	return nil
+}
+
+func convertInt(val string, retval reflect.Value, options *fieldTags) error {
+	base, err := getBase(options, 10)
+
+	if err != nil {
+		return err
+	}
+
+	parsed, err := strconv.ParseInt(val, base, retval.Type().Bits())
+
+	if err != nil {
+		return err
+	}
+
+	if hasRange(options) {
+		err = checkRange(options, func(bound string) (int, error) {
+			b, err := strconv.ParseInt(bound, 0, 64)
+
+			switch {
+			case parsed < b:
+				return -1, err
+			case parsed > b:
+				return 1, err
+			}
+
+			return 0, err
+		})
 
 		if err != nil {
 			return err
 		}
+	}
 
-		retval.SetFloat(parsed)
-	case reflect.Slice:
-		elemtp := tp.Elem()
-		elemval := reflect.New(elemtp)
+	retval.SetInt(parsed)
+	return nil
+}
 
-		if err := convert(val, elemval, options); err != nil {
-			return err
-		}
+func convertUint(val string, retval reflect.Value, options *fieldTags) error {
+	base, err := getBase(options, 10)
 
-		retval.Set(reflect.Append(retval, reflect.Indirect(elemval)))
-	case reflect.Map:
-		parts := strings.SplitN(val, ":", 2)
+	if err != nil {
+		return err
+	}
 
-		key := parts[0]
-		var value string
+	parsed, err := strconv.ParseUint(val, base, retval.Type().Bits())
 
-		if len(parts) == 2 {
-			value = parts[1]
-		}
+	if err != nil {
+		return err
+	}
+
+	if hasRange(options) {
+		err = checkRange(options, func(bound string) (int, error) {
+			b, err := strconv.ParseUint(bound, 0, 64)
+
+			switch {
+			case parsed < b:
+				return -1, err
+			case parsed > b:
+				return 1, err
+			}
 
-		keytp := tp.Key()
-		keyval := reflect.New(keytp)
+			return 0, err
+		})
 
-		if err := convert(key, keyval, options); err != nil {
+		if err != nil {
 			return err
 		}
+	}
+
+	retval.SetUint(parsed)
+	return nil
+}
 
-		valuetp := tp.Elem()
-		valueval := reflect.New(valuetp)
+func convertFloat(val string, retval reflect.Value, options *fieldTags) error {
+	parsed, err := strconv.ParseFloat(val, retval.Type().Bits())
 
-		if err := convert(value, valueval, options); err != nil {
+	if err != nil {
+		return err
+	}
+
+	if hasRange(options) {
+		err = checkRange(options, func(bound string) (int, error) {
+			b, err := strconv.ParseFloat(bound, 64)
+
+			switch {
+			case parsed < b:
+				return -1, err
+			case parsed > b:
+				return 1, err
+			}
+
+			return 0, err
+		})
+
+		if err != nil {
 			return err
 		}
+	}
 
-		if retval.IsNil() {
-			retval.Set(reflect.MakeMap(tp))
-		}
+	retval.SetFloat(parsed)
+	return nil
+}
+
+func convertDuration(val string, retval reflect.Value, options *fieldTags) error {
+	parsed, err := time.ParseDuration(val)
 
-		retval.SetMapIndex(reflect.Indirect(keyval), reflect.Indirect(valueval))
+	if err != nil {
+		return err
 	}
 
-	// Special cases
+	if hasRange(options) {
+		err = checkRange(options, func(bound string) (int, error) {
+			b, err := time.ParseDuration(bound)
 
-	// Support for time.Duration
-	if tp == reflect.TypeOf((*time.Duration)(nil)).Elem() {
-		parsed, err := time.ParseDuration(val)
+			switch {
+			case parsed < b:
+				return -1, err
+			case parsed > b:
+				return 1, err
+			}
+
+			return 0, err
+		})
 
 		if err != nil {
 			return err
 		}
+	}
+
+	retval.SetInt(int64(parsed))
+	return nil
+}
+
+func convertSlice(val string, retval reflect.Value, options *fieldTags) error {
+	// Convert directly into the new element, which avoids allocating a
+	// temporary value when the slice has spare capacity
+	n := retval.Len()
+	elemtp := retval.Type().Elem()
+
+	if n < retval.Cap() {
+		retval.SetLen(n + 1)
+		retval.Index(n).Set(reflect.Zero(elemtp))
+	} else {
+		retval.Set(reflect.Append(retval, reflect.Zero(elemtp)))
+	}
+
+	if err := convert(val, retval.Index(n), options); err != nil {
+		retval.SetLen(n)
+		return err
+	}
+
+	return nil
+}
+
+func convertMap(val string, retval reflect.Value, options *fieldTags) error {
+	key, value, _ := strings.Cut(val, ":")
+	tp := retval.Type()
+
+	keyval := reflect.New(tp.Key()).Elem()
+
+	if err := convert(key, keyval, options); err != nil {
+		return err
+	}
+
+	valueval := reflect.New(tp.Elem()).Elem()
+
+	if err := convert(value, valueval, options); err != nil {
+		return err
+	}
 
-		retval.SetInt(int64(parsed))
+	if retval.IsNil() {
+		retval.Set(reflect.MakeMap(tp))
 	}
 
+	retval.SetMapIndex(keyval, valueval)
 	return nil
 }
 
@@ -176,6 +559,11 @@ func wrapText(s string, l int, prefix string) string {
 
 	s = strings.TrimSpace(s)
 
+	// Text cannot be wrapped at less than two columns
+	if l <= 1 {
+		return s
+	}
+
 	for len(s) > l {
 		// Try to split on space
 		suffix := ""
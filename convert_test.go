@@ -0,0 +1,173 @@
+package flags
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testPoint struct {
+	X, Y int
+}
+
+// registerTestPoint registers the converter of testPoint for the duration
+// of the test, restoring the registered converters when the test ends.
+func registerTestPoint(t *testing.T) {
+	old := registeredConverters.m.Load()
+
+	t.Cleanup(func() {
+		registeredConverters.m.Store(old)
+	})
+
+	RegisterConverter(reflect.TypeOf(testPoint{}), Converter{
+		Parse: func(s string) (interface{}, error) {
+			var p testPoint
+
+			x, y, ok := strings.Cut(s, ",")
+
+			if !ok {
+				return nil, errors.New("expected x,y")
+			}
+
+			var err error
+
+			if p.X, err = strconv.Atoi(x); err == nil {
+				p.Y, err = strconv.Atoi(y)
+			}
+
+			return p, err
+		},
+		Format: func(v interface{}) string {
+			p := v.(testPoint)
+			return strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)
+		},
+	})
+}
+
+func TestRegisterConverter(t *testing.T) {
+	registerTestPoint(t)
+
+	var opts struct {
+		Origin testPoint   `long:"origin" default:"1,2"`
+		Points []testPoint `long:"point"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--point", "3,4", "--point", "5,6"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Origin != (testPoint{1, 2}) || !reflect.DeepEqual(opts.Points, []testPoint{{3, 4}, {5, 6}}) {
+		t.Errorf("Expected the points to be converted but got %+v", opts)
+	}
+
+	if args := p.MarshalArgs(); !reflect.DeepEqual(args, []string{"--point=3,4", "--point=5,6"}) {
+		t.Errorf("Expected the points to be formatted but got %v", args)
+	}
+
+	_, err := p.ParseArgs([]string{"--origin", "7"})
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrMarshal || e.Message != "invalid argument for flag `--origin': expected x,y" {
+		t.Errorf("Expected the error of the converter but got %v", err)
+	}
+}
+
+func TestRegisterConverterConcurrent(t *testing.T) {
+	registerTestPoint(t)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			// Register a distinct type while concurrently parsing
+			tp := reflect.ArrayOf(i+1, reflect.TypeOf(testPoint{}))
+			RegisterConverter(tp, Converter{
+				Parse: func(s string) (interface{}, error) {
+					return reflect.Zero(tp).Interface(), nil
+				},
+			})
+
+			var opts struct {
+				Origin testPoint `long:"origin"`
+			}
+
+			p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+			if _, err := p.ParseArgs([]string{"--origin", "1,1"}); err != nil || opts.Origin != (testPoint{1, 1}) {
+				t.Errorf("Expected the origin to be converted but got %+v (%v)", opts, err)
+			}
+
+			if _, ok := lookupConverter(tp); !ok {
+				t.Errorf("Expected the converter of %s to be registered", tp)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func BenchmarkConvertSlice(b *testing.B) {
+	var ints []int
+	var durations []time.Duration
+
+	val := reflect.ValueOf(&ints).Elem()
+	dval := reflect.ValueOf(&durations).Elem()
+	tag := newFieldTags("Int", []int{0}, `short:"i" long:"int" description:"An integer"`)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ints = ints[:0]
+		durations = durations[:0]
+
+		for j := 0; j < 100; j++ {
+			if err := convert("12345", val, tag); err != nil {
+				b.Fatal(err)
+			}
+
+			if err := convert("1m30s", dval, tag); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParseSlices(b *testing.B) {
+	var opts struct {
+		Ints    []int           `short:"i"`
+		Floats  []float64       `short:"f"`
+		Strings []string        `short:"s"`
+		Times   []time.Duration `short:"t"`
+	}
+
+	var args []string
+
+	for i := 0; i < 100; i++ {
+		n := strconv.Itoa(i)
+		args = append(args, "-i", n, "-f", n+".5", "-s", n, "-t", n+"ms")
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		opts.Ints = opts.Ints[:0]
+		opts.Floats = opts.Floats[:0]
+		opts.Strings = opts.Strings[:0]
+		opts.Times = opts.Times[:0]
+
+		if _, err := p.ParseArgs(args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ParseDotEnv reads variable definitions from a dotenv formatted reader.
+// Each line contains a definition of the form NAME=value, optionally
+// prefixed by "export". Empty lines and lines starting with # are ignored.
+// Values may be enclosed in single quotes, which are taken literally, or in
+// double quotes, in which the escape sequences \n, \t, \", \\ and \$ are
+// recognized. Unquoted values end at a # preceded by whitespace and are
+// trimmed. The returned error is of type *ConfigError when the data could
+// not be parsed.
+func ParseDotEnv(reader io.Reader) (map[string]string, error) {
+	return parseDotEnv(reader, "")
+}
+
+// ParseDotEnvFile reads variable definitions from a dotenv file. See
+// ParseDotEnv for more information.
+func ParseDotEnvFile(filename string) (map[string]string, error) {
+	fp, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer fp.Close()
+
+	return parseDotEnv(fp, filename)
+}
+
+func parseDotEnv(reader io.Reader, filename string) (map[string]string, error) {
+	scanner := bufio.NewScanner(reader)
+	ret := make(map[string]string)
+
+	var lineno uint
+
+	dotEnvError := func(format string, a ...interface{}) error {
+		return &ConfigError{
+			Message:    fmt.Sprintf(format, a...),
+			File:       filename,
+			LineNumber: lineno,
+		}
+	}
+
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(line[len("export "):])
+		}
+
+		pos := strings.Index(line, "=")
+
+		if pos < 0 {
+			return nil, dotEnvError("malformed NAME=value (%s)", line)
+		}
+
+		name := strings.TrimSpace(line[:pos])
+
+		if !isDotEnvName(name) {
+			return nil, dotEnvError("invalid variable name `%s'", name)
+		}
+
+		value, err := dotEnvValue(strings.TrimSpace(line[pos+1:]))
+
+		if err != nil {
+			return nil, dotEnvError("%s", err)
+		}
+
+		ret[name] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func isDotEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func dotEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	quote := value[0]
+
+	if quote != '"' && quote != '\'' {
+		for i := 1; i < len(value); i++ {
+			if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+				return strings.TrimSpace(value[:i]), nil
+			}
+		}
+
+		return value, nil
+	}
+
+	var ret []byte
+
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+
+		if c == quote {
+			rest := strings.TrimSpace(value[i+1:])
+
+			if rest != "" && rest[0] != '#' {
+				return "", fmt.Errorf("unexpected `%s' after quoted value", rest)
+			}
+
+			return string(ret), nil
+		}
+
+		if c == '\\' && quote == '"' && i+1 < len(value) {
+			i++
+
+			switch value[i] {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case '"', '\\', '$':
+				c = value[i]
+			default:
+				return "", fmt.Errorf("invalid escape sequence \\%c", value[i])
+			}
+		}
+
+		ret = append(ret, c)
+	}
+
+	return "", fmt.Errorf("unterminated quoted value")
+}
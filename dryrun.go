@@ -0,0 +1,188 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An Assignment is a value to which an option would be set (see DryRun).
+type Assignment struct {
+	// The option of the parser
+	Option *Option
+
+	// The converted value of the option. For function options, this is the
+	// converted argument of the call, or nil for functions without an
+	// argument. For Getter options, this is the argument which would be
+	// passed to the Set method of the wrapped value
+	Value interface{}
+
+	// Where the value came from
+	Origin Origin
+}
+
+// String returns a description of the assignment, such as
+// "--port = 8080 from command line (--port)". Values of secret options are
+// masked.
+func (a Assignment) String() string {
+	if a.Value == nil {
+		return fmt.Sprintf("%s from %s", a.Option, a.Origin)
+	}
+
+	value := convertToString(reflect.ValueOf(a.Value), a.Option.options)
+	return fmt.Sprintf("%s = %s from %s", a.Option, a.Option.argument(value), a.Origin)
+}
+
+// DryRun parses the sources like ParseSources, without setting the options
+// of the parser. Instead, the values to which the options would be set are
+// returned, in the order of the options in the help message, along with
+// the remaining command line arguments. This allows explaining how a
+// command line would be interpreted, and validating command lines supplied
+// by users without acting on them. For example:
+//
+//	assignments, _, err := parser.DryRun(flags.CommandLineSource(args))
+//
+//	for _, a := range assignments {
+//		fmt.Println(a)
+//	}
+//
+// The sources are parsed by a clone of the parser (see Parser.Clone), which
+// can therefore be frozen. Function options are not called and the Set
+// method of the value of Getter options is not called either, each call
+// is reported as an assignment instead. Validators are run as usual, while
+// nothing is printed or prompted for, and the environment variables of the
+// parser itself (GO_FLAGS_DEBUG and GO_FLAGS_COMPLETION) are ignored.
+// Options which are not set by any source are only reported when their
+// default is computed by DefaultFunc.
+func (p *Parser) DryRun(sources ...Source) ([]Assignment, []string, error) {
+	if err := p.groupError(); err != nil {
+		return nil, nil, err
+	}
+
+	clone := p.Clone()
+	clone.isolated = true
+	clone.Options &^= PrintErrors | HelpOnError | HelpNoError
+
+	// The options of the parser by the options of the clone
+	originals := make(map[*Option]*Option)
+	i := 0
+
+	for _, grp := range p.Groups {
+		if grp != p.helpGroup {
+			pairOptions(grp, clone.Groups[i], originals)
+			i++
+		}
+	}
+
+	var calls []Assignment
+
+	for _, grp := range clone.groups() {
+		for _, option := range grp.Options {
+			// The function is also the initial value, to which options
+			// are reset
+			if option.isFunc() {
+				option.value.Set(recordCalls(option, originals[option], &calls))
+				option.initial = copyValue(option.value)
+			} else if option.value.Type() == getterType {
+				// The clone shares the wrapped value with the parser
+				option.Setter = recordSets(option, originals[option], &calls)
+			}
+		}
+	}
+
+	ret, err := clone.ParseSources(sources...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var assignments []Assignment
+
+	for _, grp := range clone.groups() {
+		for _, option := range grp.Options {
+			original := originals[option]
+
+			if original == nil {
+				continue
+			}
+
+			if option.isFunc() || option.value.Type() == getterType {
+				for _, call := range calls {
+					if call.Option == original {
+						assignments = append(assignments, call)
+					}
+				}
+			} else if option.IsSet() || !option.isInitial() {
+				assignments = append(assignments, Assignment{
+					Option: original,
+					Value:  option.Value(),
+					Origin: option.origin,
+				})
+			}
+		}
+	}
+
+	return assignments, ret, nil
+}
+
+// pairOptions maps the options of a clone of a group, including its
+// sub-groups, to the options of the group.
+func pairOptions(grp *Group, clone *Group, originals map[*Option]*Option) {
+	for i, option := range grp.Options {
+		originals[clone.Options[i]] = option
+	}
+
+	for i, sub := range grp.Groups {
+		pairOptions(sub, clone.Groups[i], originals)
+	}
+}
+
+// recordCalls returns a function of the type of the function option which
+// records its calls as assignments of the original option, instead of
+// calling the function.
+func recordCalls(option *Option, original *Option, calls *[]Assignment) reflect.Value {
+	tp := option.value.Type()
+
+	return reflect.MakeFunc(tp, func(args []reflect.Value) []reflect.Value {
+		call := Assignment{
+			Option: original,
+			Origin: option.origin,
+		}
+
+		if len(args) == 1 {
+			call.Value = args[0].Interface()
+		}
+
+		*calls = append(*calls, call)
+
+		ret := make([]reflect.Value, tp.NumOut())
+
+		for i := range ret {
+			ret[i] = reflect.Zero(tp.Out(i))
+		}
+
+		return ret
+	})
+}
+
+// recordSets returns a setter of a Getter option which records the values
+// to which the option would be set as assignments of the original option,
+// instead of setting the value wrapped by the Getter.
+func recordSets(option *Option, original *Option, calls *[]Assignment) func(string) error {
+	return func(value string) error {
+		if value == "" && option.value.Interface().(Getter).isBoolFlag() {
+			value = "true"
+		}
+
+		*calls = append(*calls, Assignment{
+			Option: original,
+			Value:  value,
+			Origin: option.origin,
+		})
+
+		return nil
+	}
+}
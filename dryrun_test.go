@@ -0,0 +1,129 @@
+package flags
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDryRun(t *testing.T) {
+	var calls []string
+
+	var opts struct {
+		Port     int           `short:"p" long:"port" default:"80" env:"DRY_RUN_PORT"`
+		Hosts    []string      `long:"host"`
+		Password string        `long:"password" secret:"true"`
+		Timeout  time.Duration `long:"timeout"`
+		Call     func(string)  `long:"call"`
+		Server   struct {
+			Name string `long:"name"`
+		} `group:"Server Options" namespace:"server"`
+	}
+
+	opts.Call = func(s string) {
+		calls = append(calls, s)
+	}
+
+	p := NewNamedParser("test", HelpFlag|PrintErrors, NewGroup("Application Options", &opts))
+	p.ErrorWriter = &bytes.Buffer{}
+	p.FindOptionByLongName("timeout").DefaultFunc = func() (string, error) {
+		return "1m", nil
+	}
+	p.Freeze()
+
+	t.Setenv("DRY_RUN_PORT", "8080")
+
+	assignments, rest, err := p.DryRun(
+		CommandLineSource([]string{"--host", "a", "--call", "x", "--password", "secret", "--host", "b", "--server.name", "s", "--call", "y", "file"}),
+		EnvironmentSource(),
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var descriptions []string
+
+	for _, a := range assignments {
+		descriptions = append(descriptions, a.String())
+	}
+
+	expected := []string{
+		"-p, --port = 8080 from environment ($DRY_RUN_PORT)",
+		"--host = [a, b] from command line (--host)",
+		"--password = *** from command line (--password)",
+		"--timeout = 1m0s from default",
+		"--call = x from command line (--call)",
+		"--call = y from command line (--call)",
+		"--server.name = s from command line (--server.name)",
+	}
+
+	if !reflect.DeepEqual(descriptions, expected) {
+		t.Errorf("Expected assignments:\n%q\nbut got:\n%q", expected, descriptions)
+	}
+
+	if len(rest) != 1 || rest[0] != "file" {
+		t.Errorf("Expected the remaining arguments but got %v", rest)
+	}
+
+	if assignments[0].Option != p.FindOptionByLongName("port") || assignments[0].Value != 8080 {
+		t.Errorf("Expected the option of the parser and the converted value but got %+v", assignments[0])
+	}
+
+	if opts.Port != 80 || opts.Hosts != nil || opts.Server.Name != "" || len(calls) != 0 || p.FindOptionByLongName("port").IsSet() {
+		t.Errorf("Expected the options not to be set but got %+v and calls %v", opts, calls)
+	}
+
+	if _, _, err := p.DryRun(CommandLineSource([]string{"--port", "x"})); err == nil {
+		t.Errorf("Expected an error for an invalid argument")
+	}
+
+	if b := p.ErrorWriter.(*bytes.Buffer); b.Len() != 0 {
+		t.Errorf("Expected no errors to be printed but got %q", b.String())
+	}
+}
+
+func TestDryRunGetter(t *testing.T) {
+	var level testLevel
+	var trace bool
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.BoolVar(&trace, "trace", false, "Trace")
+
+	opts := struct {
+		Level Getter `long:"level"`
+		Trace Getter `long:"trace"`
+	}{
+		Level: Getter{&level},
+		Trace: Getter{set.Lookup("trace").Value.(flag.Getter)},
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	assignments, _, err := p.DryRun(CommandLineSource([]string{"--level", "debug", "--trace"}))
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var descriptions []string
+
+	for _, a := range assignments {
+		descriptions = append(descriptions, a.String())
+	}
+
+	expected := []string{
+		"--level = debug from command line (--level)",
+		"--trace = true from command line (--trace)",
+	}
+
+	if !reflect.DeepEqual(descriptions, expected) {
+		t.Errorf("Expected assignments:\n%q\nbut got:\n%q", expected, descriptions)
+	}
+
+	if level != 0 || trace {
+		t.Errorf("Expected the values not to be set but got %v and %v", level, trace)
+	}
+}
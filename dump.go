@@ -0,0 +1,196 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ConfigFormat is the format in which Parser.WriteConfig writes the
+// resolved configuration.
+type ConfigFormat uint
+
+const (
+	// A human readable table of options, values and origins
+	ConfigText ConfigFormat = iota
+
+	// A JSON object mapping group names to objects describing the value
+	// and origin of each option
+	ConfigJSON
+
+	// An ini file (see IniParser.Write) which includes all values and
+	// their origins as comments
+	ConfigIni
+)
+
+type jsonConfigValue struct {
+	Value  interface{} `json:"value"`
+	Origin string      `json:"origin"`
+	File   string      `json:"file,omitempty"`
+	Name   string      `json:"name,omitempty"`
+}
+
+// WriteConfig writes the resolved configuration, i.e. the final value and
+// origin (see Option.Origin) of every option of the parser, in the given
+// format. This is useful to implement a --print-config option for debugging
+// purposes. Function options are not written and the values of secret
+// options are masked. ErrUnknownConfigFormat is returned for an unsupported
+// format.
+func (p *Parser) WriteConfig(writer io.Writer, format ConfigFormat) error {
+	switch format {
+	case ConfigText:
+		return p.writeConfigText(writer)
+	case ConfigJSON:
+		return p.writeConfigJSON(writer)
+	case ConfigIni:
+		return NewIniParser(p).Write(writer, IniIncludeComments|IniIncludeDefaults|IniIncludeOrigins|IniMaskSecrets)
+	}
+
+	return ErrUnknownConfigFormat
+}
+
+func (p *Parser) writeConfigText(writer io.Writer) error {
+	wr := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+
+	for i, grp := range p.groups() {
+		if i != 0 {
+			fmt.Fprintln(wr)
+		}
+
+		fmt.Fprintf(wr, "%s:\n", grp.Name)
+
+		for _, option := range grp.Options {
+			if option.isFunc() || option.sharesField() {
+				continue
+			}
+
+			values := option.iniValues()
+			value := strings.Join(values, ", ")
+
+			if kind := option.value.Kind(); kind == reflect.Slice || kind == reflect.Map {
+				value = "[" + value + "]"
+			}
+
+			if option.Secret {
+				value = "***"
+			}
+
+			fmt.Fprintf(wr, "  %s\t%s\t%s\n", option, value, option.origin)
+		}
+	}
+
+	return wr.Flush()
+}
+
+func (p *Parser) writeConfigJSON(writer io.Writer) error {
+	config := make(map[string]map[string]jsonConfigValue)
+
+	for _, grp := range p.groups() {
+		values := make(map[string]jsonConfigValue)
+
+		for _, option := range grp.Options {
+			if option.isFunc() || option.sharesField() {
+				continue
+			}
+
+			var value interface{} = "***"
+
+			if !option.Secret {
+				value = jsonValue(option.value)
+			}
+
+			values[option.configKey()] = jsonConfigValue{
+				Value:  value,
+				Origin: option.origin.Type.String(),
+				File:   option.origin.File,
+				Name:   option.origin.Name,
+			}
+		}
+
+		config[grp.Name] = values
+	}
+
+	return writeJSON(writer, config)
+}
+
+// writeJSON writes the value as indented JSON.
+func writeJSON(writer io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(writer, "%s\n", data)
+	return err
+}
+
+// configKey returns the name under which the option is written by
+// Parser.WriteConfig, which is its long name if it has one.
+func (option *Option) configKey() string {
+	if option.LongName != "" {
+		return option.LongName
+	}
+
+	return string(option.ShortName)
+}
+
+// jsonValue returns the value to be encoded as JSON, representing durations
+// as strings such that they can be read back.
+func jsonValue(val reflect.Value) interface{} {
+	if val.Type() == durationType {
+		return time.Duration(val.Int()).String()
+	}
+
+	if g, ok := val.Interface().(Getter); ok && g.Getter != nil {
+		return g.Get()
+	}
+
+	return val.Interface()
+}
+
+// Values returns a snapshot of the values of the options of the group,
+// keyed by their long names (or short names for options without a long
+// name), and of its sub-groups, which are nested maps keyed by the group
+// names. Slices and maps are copied, such that the snapshot is not changed
+// by subsequent parses. This is useful to log the configuration or to pass
+// it to code which does not depend on the data struct of the group.
+// Function options are not included, while the values of secret options
+// are.
+func (g *Group) Values() map[string]interface{} {
+	ret := make(map[string]interface{})
+
+	for _, option := range g.Options {
+		if !option.isFunc() && !option.sharesField() {
+			ret[option.configKey()] = copyValue(option.value).Interface()
+		}
+	}
+
+	for _, grp := range g.Groups {
+		ret[grp.Name] = grp.Values()
+	}
+
+	return ret
+}
+
+// Values returns a snapshot of the values of the options of the parser, as
+// a map of the group names to the values of the groups (see Group.Values).
+func (p *Parser) Values() map[string]interface{} {
+	ret := make(map[string]interface{})
+
+	for _, grp := range p.Groups {
+		if grp != p.helpGroup {
+			ret[grp.Name] = grp.Values()
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,15 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package flags
+
+import (
+	"errors"
+)
+
+func disableEcho() (func(), error) {
+	return nil, errors.New("disabling the echo of the terminal is not supported")
+}
@@ -0,0 +1,44 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package flags
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func ioctlTermios(request uintptr, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
+		uintptr(0),
+		request,
+		uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// disableEcho disables the echo of the input of the terminal of the
+// standard input, returning a function which restores the previous state.
+func disableEcho() (func(), error) {
+	var termios syscall.Termios
+
+	if err := ioctlTermios(ioctlGetTermios, &termios); err != nil {
+		return nil, err
+	}
+
+	old := termios
+	termios.Lflag &^= syscall.ECHO
+
+	if err := ioctlTermios(ioctlSetTermios, &termios); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ioctlTermios(ioctlSetTermios, &old)
+	}, nil
+}
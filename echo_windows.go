@@ -0,0 +1,32 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"syscall"
+)
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// The ENABLE_ECHO_INPUT flag of the console mode
+const enableEchoInput = 0x4
+
+// disableEcho disables the echo of the input of the console of the standard
+// input, returning a function which restores the previous state.
+func disableEcho() (func(), error) {
+	var mode uint32
+
+	if err := syscall.GetConsoleMode(syscall.Stdin, &mode); err != nil {
+		return nil, err
+	}
+
+	if r, _, err := setConsoleMode.Call(uintptr(syscall.Stdin), uintptr(mode&^enableEchoInput)); r == 0 {
+		return nil, err
+	}
+
+	return func() {
+		setConsoleMode.Call(uintptr(syscall.Stdin), uintptr(mode))
+	}, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"reflect"
+)
+
+// edit is an argument of a slice or map option given on the command line as
+// --name+=value or --name-=value, which adds the argument to the values of
+// the option or removes it, instead of replacing the values.
+type edit struct {
+	option   *Option
+	flag     string
+	operator string
+	value    string
+}
+
+// isCollection returns whether the values of the option can be edited on
+// the command line, which is the case for slice and map options without a
+// converter of the whole slice or map.
+func (option *Option) isCollection() bool {
+	tp := option.value.Type()
+
+	if tp.Kind() != reflect.Slice && tp.Kind() != reflect.Map {
+		return false
+	}
+
+	if _, ok := lookupConverter(tp); ok {
+		return false
+	}
+
+	return option.canArgument()
+}
+
+// editOption applies an edit given on the command line. Within
+// Parser.ParseSources, the edit is applied once all the sources were
+// applied, such that it edits the values of sources of lower precedence,
+// such as configuration files.
+func (p *Parser) editOption(e edit) error {
+	if p.layer != nil {
+		p.edits = append(p.edits, e)
+		return nil
+	}
+
+	return p.applyEdit(e)
+}
+
+// applyEdits applies the edits deferred by ParseSources, in the order in
+// which they were given.
+func (p *Parser) applyEdits() error {
+	for _, e := range p.edits {
+		if err := p.applyEdit(e); err != nil {
+			return err
+		}
+	}
+
+	p.edits = nil
+	return nil
+}
+
+// applyEdit adds the argument of the edit to the values of its option, or
+// removes it. The values which the option had, given by its default tag or
+// by another source, are kept. The source of the option becomes the command
+// line.
+func (p *Parser) applyEdit(e edit) error {
+	option := e.option
+
+	option.layer = commandLineLayer
+	option.origin = Origin{
+		Type: OriginCommandLine,
+		Name: e.flag,
+	}
+
+	if e.operator == "+" {
+		return option.marshalError(p.setOption(option, &e.value))
+	}
+
+	if err := option.remove(e.value); err != nil {
+		return option.marshalError(err)
+	}
+
+	p.traceSet(option)
+	return nil
+}
+
+// remove removes a value from a slice option, or a key from a map option.
+func (option *Option) remove(value string) error {
+	val := option.value
+
+	if val.Kind() == reflect.Map {
+		key := reflect.New(val.Type().Key()).Elem()
+
+		if err := convert(value, key, option.options); err != nil {
+			return err
+		}
+
+		if !val.IsNil() {
+			val.SetMapIndex(key, reflect.Value{})
+		}
+
+		option.store()
+		return nil
+	}
+
+	removed := reflect.New(val.Type().Elem()).Elem()
+
+	if err := convert(value, removed, option.options); err != nil {
+		return err
+	}
+
+	ret := reflect.MakeSlice(val.Type(), 0, val.Len())
+
+	for i := 0; i < val.Len(); i++ {
+		if !reflect.DeepEqual(val.Index(i).Interface(), removed.Interface()) {
+			ret = reflect.Append(ret, val.Index(i))
+		}
+	}
+
+	val.Set(ret)
+	option.store()
+	return nil
+}
@@ -1,5 +1,9 @@
 package flags
 
+import (
+	"strings"
+)
+
 // ErrorType represents the type of error.
 type ErrorType uint
 
@@ -21,16 +25,90 @@ const (
 
 	// An argument for a boolean value was specified
 	ErrNoArgumentForBool
+
+	// The argument is not one of the choices of the option
+	ErrInvalidChoice
+
+	// The value of an option was rejected by a validator
+	ErrValidation
+
+	// The argument is outside of the range of valid values of the option
+	ErrOutOfRange
+
+	// A required option was not specified
+	ErrRequired
+
+	// An option was specified too often or not often enough
+	ErrOccurrences
+
+	// A deprecated option was used (only used for warnings)
+	ErrDeprecated
+
+	// The value of an option was overridden by a later value (only used
+	// for warnings)
+	ErrOverridden
+
+	// An action was not confirmed (see Parser.Confirm)
+	ErrConfirmation
+
+	// An option was set by a source which may not set it (see the sources
+	// tag). Sources other than the command line are ignored with a warning
+	ErrRestricted
 )
 
+// Get a human friendly readable name of the error type.
+func (e ErrorType) String() string {
+	switch e {
+	case ErrExpectedArgument:
+		return "expected argument"
+	case ErrUnknownFlag:
+		return "unknown flag"
+	case ErrMarshal:
+		return "invalid value"
+	case ErrHelp:
+		return "help"
+	case ErrNoArgumentForBool:
+		return "no argument for bool"
+	case ErrInvalidChoice:
+		return "invalid choice"
+	case ErrValidation:
+		return "validation"
+	case ErrOutOfRange:
+		return "out of range"
+	case ErrRequired:
+		return "required"
+	case ErrOccurrences:
+		return "occurrences"
+	case ErrDeprecated:
+		return "deprecated"
+	case ErrOverridden:
+		return "overridden"
+	case ErrConfirmation:
+		return "confirmation required"
+	case ErrRestricted:
+		return "restricted"
+	}
+
+	return "unknown"
+}
+
 // Error represents a parser error. The error returned from Parse is of this
-// type. The error contains both a Type and Message.
+// type. The error contains both a Type and Message, as well as the Option
+// which caused the error (if any), such that callers can handle specific
+// errors programmatically.
 type Error struct {
 	// The type of error
 	Type ErrorType
 
 	// The error message
 	Message string
+
+	// The option which caused the error, or nil if the error is not
+	// related to a single option (e.g. an unknown flag)
+	Option *Option
+
+	// The underlying error (e.g. a *strconv.NumError), if any
+	err error
 }
 
 // Get the errors error message.
@@ -38,9 +116,86 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the underlying error which caused the error, if any (e.g.
+// the *strconv.NumError of an invalid integer argument or the error
+// returned by a validator).
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the error is of the given ErrorType, such that errors
+// can be checked using errors.Is(err, flags.ErrRequired).
+func (e *Error) Is(target error) bool {
+	tp, ok := target.(ErrorType)
+	return ok && tp == e.Type
+}
+
+// Error returns the name of the error type. It allows an ErrorType to be
+// used as the target of errors.Is.
+func (e ErrorType) Error() string {
+	return e.String()
+}
+
+// Errors is a list of errors, returned when the CollectErrors option is set
+// and more than one error occurred.
+type Errors []error
+
+// Get the error messages of all the errors, one per line.
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the errors, such that errors.Is and errors.As match any of
+// them.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// append adds the error to the list, flattening lists of errors.
+func (e Errors) append(err error) Errors {
+	if errs, ok := err.(Errors); ok {
+		return append(e, errs...)
+	}
+
+	return append(e, err)
+}
+
+// err returns nil for an empty list, the error itself for a list of a
+// single error and the list otherwise.
+func (e Errors) err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+
+	return e
+}
+
 func newError(tp ErrorType, message string) *Error {
 	return &Error{
 		Type:    tp,
 		Message: message,
 	}
 }
+
+func newOptionError(tp ErrorType, option *Option, message string) *Error {
+	return &Error{
+		Type:    tp,
+		Message: message,
+		Option:  option,
+	}
+}
+
+// wrap sets the underlying error of the error.
+func (e *Error) wrap(err error) *Error {
+	e.err = err
+	return e
+}
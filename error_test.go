@@ -0,0 +1,361 @@
+package flags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestErrorOption(t *testing.T) {
+	var opts struct {
+		Name    string `long:"name"`
+		Level   int    `short:"l" long:"level"`
+		Verbose bool   `short:"v"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	tests := []struct {
+		args   []string
+		tp     ErrorType
+		option string
+	}{
+		{[]string{"--name"}, ErrExpectedArgument, "--name"},
+		{[]string{"--level", "a"}, ErrMarshal, "-l, --level"},
+		{[]string{"-v=1"}, ErrNoArgumentForBool, "-v"},
+		{[]string{"--unknown"}, ErrUnknownFlag, ""},
+	}
+
+	for _, test := range tests {
+		_, err := p.ParseArgs(test.args)
+		e, ok := err.(*Error)
+
+		if !ok || e.Type != test.tp {
+			t.Errorf("Expected error of type %s for %v but got %v", test.tp, test.args, err)
+			continue
+		}
+
+		if test.option == "" {
+			if e.Option != nil {
+				t.Errorf("Expected no option for %v but got %s", test.args, e.Option)
+			}
+		} else if e.Option == nil || e.Option.String() != test.option {
+			t.Errorf("Expected option %s for %v but got %v", test.option, test.args, e.Option)
+		}
+	}
+}
+
+func TestErrorWrapping(t *testing.T) {
+	var opts struct {
+		Level int `long:"level"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	_, err := p.ParseArgs([]string{"--level", "a"})
+
+	var numErr *strconv.NumError
+
+	if !errors.As(err, &numErr) || numErr.Num != "a" {
+		t.Errorf("Expected wrapped *strconv.NumError but got %v", err)
+	}
+
+	if !errors.Is(err, ErrMarshal) || errors.Is(err, ErrRequired) {
+		t.Errorf("Expected error to be of type %s", ErrMarshal)
+	}
+
+	p = NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	err = p.ParseYAML(strings.NewReader("level: b\n"))
+
+	var flagsErr *Error
+
+	if !errors.As(err, &flagsErr) || flagsErr.Option == nil || !errors.As(err, &numErr) {
+		t.Errorf("Expected configuration error to wrap the option error but got %v", err)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	var opts struct {
+		Verbose bool `long:"verbose"`
+		Version bool `long:"version"`
+		Output  bool `long:"output"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"verbos", "unknown flag `verbos' (did you mean `--verbose'?)"},
+		{"versoin", "unknown flag `versoin' (did you mean `--version'?)"},
+		{"outputs", "unknown flag `outputs' (did you mean `--output'?)"},
+		{"input", "unknown flag `input'"},
+	}
+
+	for _, test := range tests {
+		_, err := p.ParseArgs([]string{"--" + test.name})
+
+		if err == nil || err.Error() != test.message {
+			t.Errorf("Expected error %q but got %v", test.message, err)
+		}
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	var opts struct {
+		Level int    `long:"level"`
+		Name  string `long:"name" min-occurrences:"1"`
+		Other string `long:"other" required-if:"level"`
+	}
+
+	p := NewNamedParser("test", CollectErrors, NewGroup("Application Options", &opts))
+
+	_, err := p.ParseArgs([]string{"--level", "a", "--unknown", "--level", "2"})
+
+	errs, ok := err.(Errors)
+
+	if !ok {
+		t.Fatalf("Expected Errors but got %v", err)
+	}
+
+	expected := []ErrorType{ErrMarshal, ErrUnknownFlag, ErrOccurrences, ErrRequired}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors but got:\n%s", len(expected), errs)
+	}
+
+	for i, tp := range expected {
+		if !errors.Is(errs[i], tp) {
+			t.Errorf("Expected error %d to be of type %s but got %v", i, tp, errs[i])
+		}
+	}
+
+	if opts.Level != 2 {
+		t.Errorf("Expected parsing to continue after errors")
+	}
+}
+
+func TestErrorFormatter(t *testing.T) {
+	var opts struct {
+		Level int `long:"level"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if s := p.formatError(errors.New("failed")); s != "Flags error: failed" {
+		t.Errorf("Unexpected default error format %q", s)
+	}
+
+	p.ErrorFormatter = func(err *Error) string {
+		return fmt.Sprintf("error (%s): %s", err.Type, err.Option.LongName)
+	}
+
+	_, err := p.ParseArgs([]string{"--level", "a"})
+
+	if s := p.formatError(err); s != "error (invalid value): level" {
+		t.Errorf("Unexpected formatted error %q", s)
+	}
+}
+
+func TestErrorOutput(t *testing.T) {
+	var opts struct {
+		Level int `long:"level" description:"Level"`
+	}
+
+	var b bytes.Buffer
+
+	p := NewNamedParser("test", PrintErrors|HelpOnError|HelpFlag|HelpNoError, NewGroup("Application Options", &opts))
+	p.ErrorWriter = &b
+
+	if _, err := p.ParseArgs([]string{"--level", "a"}); err == nil {
+		t.Fatalf("Expected error")
+	}
+
+	if s := b.String(); !strings.HasPrefix(s, "Flags error: invalid argument for flag `--level' (expected int)\n\nUsage:\n") {
+		t.Errorf("Expected error followed by help but got:\n%s", s)
+	}
+
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	b.Reset()
+	args, err := p.ParseArgs([]string{"--help"})
+
+	if err != nil || args != nil || !p.HelpRequested() || b.Len() != 0 {
+		t.Errorf("Expected help to be requested without an error but got %v", err)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var opts struct {
+		Level  int      `short:"l" long:"level"`
+		Values []string `long:"value"`
+	}
+
+	var messages []string
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	p.Trace = func(message string) {
+		messages = append(messages, message)
+	}
+
+	if _, err := p.ParseArgs([]string{"-l", "1", "--value=a", "rest"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := p.ParseYAML(strings.NewReader("level: 2\n")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"argument `-l'",
+		"set -l, --level to 1 (command line (-l))",
+		"argument `--value=a'",
+		"set --value to [a] (command line (--value))",
+		"argument `rest'",
+		"ignoring -l, --level from configuration (level), already set by command line (-l)",
+	}
+
+	if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected trace:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(messages, "\n"))
+	}
+}
+
+func TestTraceEnvironment(t *testing.T) {
+	var opts struct {
+		Level int `long:"level"`
+	}
+
+	var b bytes.Buffer
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	p.Stderr = &b
+	p.LookupEnv = func(name string) (string, bool) {
+		if name == "GO_FLAGS_DEBUG" {
+			return "1", true
+		}
+
+		return "", false
+	}
+
+	if _, err := p.ParseArgs([]string{"--level", "1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !strings.Contains(b.String(), "go-flags: set --level to 1") {
+		t.Errorf("Expected the trace to be printed but got:\n%s", b.String())
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	var opts struct {
+		Old   string `long:"old" deprecated:"use --new instead"`
+		Level int    `long:"level"`
+	}
+
+	var warnings []string
+
+	p := NewNamedParser("test", IgnoreUnknown, NewGroup("Application Options", &opts))
+	p.Warning = func(warning *Error) {
+		warnings = append(warnings, warning.Type.String()+": "+warning.Message)
+	}
+
+	args, err := p.ParseArgs([]string{"--old", "a", "--unknown", "--level", "1", "--level", "2"})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(args) != 1 || args[0] != "--unknown" || opts.Level != 2 {
+		t.Errorf("Unexpected parse result %v %+v", args, opts)
+	}
+
+	expected := []string{
+		"deprecated: flag `--old' is deprecated: use --new instead",
+		"unknown flag: unknown flag `unknown'",
+		"overridden: flag `--level' was specified more than once, using the last value",
+	}
+
+	if strings.Join(warnings, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected warnings:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(warnings, "\n"))
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, ExitSuccess},
+		{newError(ErrHelp, "help"), ExitSuccess},
+		{newError(ErrUnknownFlag, "unknown"), ExitUsage},
+		{Errors{newError(ErrRequired, "a"), errors.New("b")}, ExitUsage},
+		{&ConfigError{Message: "c", Err: newError(ErrMarshal, "c")}, ExitUsage},
+		{errors.New("internal"), ExitFailure},
+		{newError(ErrUnknown, "internal"), ExitFailure},
+	}
+
+	for _, test := range tests {
+		if code := ExitCode(test.err); code != test.code {
+			t.Errorf("Expected exit code %d for %v but got %d", test.code, test.err, code)
+		}
+	}
+
+	var opts struct {
+		Home string `long:"home"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	p.FindOptionByLongName("home").DefaultFunc = func() (string, error) {
+		return "", errors.New("no home directory")
+	}
+
+	if _, err := p.ParseArgs(nil); ExitCode(err) != ExitFailure {
+		t.Errorf("Expected exit code %d for %v", ExitFailure, err)
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	var nilptr *struct{}
+
+	var unexported struct {
+		level int `long:"level"`
+	}
+
+	var unsupported struct {
+		Level *int `long:"level"`
+	}
+
+	var unsupportedFunc struct {
+		Call func(a, b string) `long:"call"`
+	}
+
+	tests := []struct {
+		data    interface{}
+		message string
+	}{
+		{nilptr, ErrNotPointerToStruct.Error()},
+		{unsupported, ErrNotPointerToStruct.Error()},
+		{&unexported, "field `level' is tagged as an option but is not exported"},
+		{&unsupported, "field `Level' has unsupported type *int"},
+		{&unsupportedFunc, "field `Call' has unsupported function type func(string, string) (expected a function with at most one argument of a supported type)"},
+	}
+
+	for _, test := range tests {
+		p := NewNamedParser("test", None, NewGroup("Application Options", test.data))
+
+		if _, err := p.ParseArgs(nil); err == nil || err.Error() != test.message {
+			t.Errorf("Expected error %q but got %v", test.message, err)
+		}
+	}
+
+	_ = unexported.level
+}
@@ -0,0 +1,74 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"errors"
+	"os"
+)
+
+// Exit codes used by ExitCode
+const (
+	// The program completed successfully (e.g. after showing the help)
+	ExitSuccess = 0
+
+	// An internal or unexpected error occurred
+	ExitFailure = 1
+
+	// The command line was invalid
+	ExitUsage = 2
+)
+
+// ExitCodes maps error types to the exit codes returned by ExitCode. Error
+// types which are not in the map map to ExitUsage, and errors which are not
+// parser errors map to ExitFailure, like ErrUnknown, which is the type of
+// internal errors (e.g. of a DefaultFunc). The map can be modified to change
+// the exit code policy of the program.
+var ExitCodes = map[ErrorType]int{
+	ErrHelp:    ExitSuccess,
+	ErrUnknown: ExitFailure,
+}
+
+// ExitCode returns the exit code of the program for an error returned by
+// the parser, according to ExitCodes. A nil error maps to ExitSuccess. For
+// collected errors (see CollectErrors), the exit code of the first error is
+// used.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+
+	var errs Errors
+
+	if errors.As(err, &errs) && len(errs) != 0 {
+		err = errs[0]
+	}
+
+	var e *Error
+
+	if !errors.As(err, &e) {
+		return ExitFailure
+	}
+
+	if code, ok := ExitCodes[e.Type]; ok {
+		return code
+	}
+
+	return ExitUsage
+}
+
+// HandleError exits the program with the exit code of the error (see
+// ExitCode) if the error is not nil. It is a convenience to be used
+// directly after parsing:
+//
+//	args, err := parser.Parse()
+//	flags.HandleError(err)
+//
+// Note that the error itself is not printed, see the PrintErrors option.
+func HandleError(err error) {
+	if err != nil {
+		os.Exit(ExitCode(err))
+	}
+}
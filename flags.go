@@ -15,13 +15,92 @@
 //     Multiple option groups each containing a set of options
 //     Generate and print well-formatted help message
 //     Passing remaining command line arguments after -- (optional)
+//     Passing all arguments after the first non-option (optional)
 //     Ignoring unknown command line options (optional)
 //     Supports -I/usr/include -I=/usr/include -I /usr/include option argument specification
 //     Supports multiple short options -aux
 //     Supports all primitive go types (string, int{8..64}, uint{8..64}, float)
 //     Supports same option multiple times (can store in slice or last option counts)
+//     Counters incremented and decremented by paired options (-v and -q)
 //     Supports maps
+//     Add and remove values of slices and maps (--tag+=value, --tag-=value)
 //     Supports function callbacks
+//     Custom converters of option types (RegisterConverter)
+//     Generate shell completion scripts (bash, zsh, fish, PowerShell)
+//     Read and write option values from and to ini files
+//     Read option values from YAML, TOML and JSON files
+//     Combine values from the command line, environment and configuration files
+//     Read the environment when parsing the command line (ParseEnvironment)
+//     Load a configuration file named on the command line (--config)
+//     Read environment variables from .env files
+//     Report where the value of each option came from
+//     Print the resolved configuration as text, JSON or ini
+//     Snapshot the parsed values as a map (Group.Values)
+//     Validate option values using callbacks
+//     Restrict numeric options to a range of values
+//     Validate arguments using regular expressions
+//     Options requiring other options
+//     Options required depending on the value of other options
+//     Sets of options of which at least one must be specified
+//     Required options, optionally prompted for on a terminal (PromptRequired)
+//     Required options and sets of options shown in the usage line
+//     Prompt for missing values and passwords, with echo disabled for secrets
+//     Mask secret values in help, errors and configuration dumps
+//     Confirm destructive actions using --yes style options (Confirmation)
+//     Standard -v/--verbose and -q/--quiet options (Verbosity)
+//     Tri-state options depending on the output being a terminal (--color=auto)
+//     Read option values from files using @filename (ValuesFromFiles)
+//     Read values from files named by <VARIABLE>_FILE environment variables
+//     Expand environment variables and ~ in option values (expand)
+//     Restrict options to the command line, environment or config files
+//     Injectable standard streams and environment (Parser.Stdin, LookupEnv)
+//     Validate groups of options after parsing (Validate method)
+//     Integrate struct validation packages
+//     Limit how often an option can be specified
+//     Structured errors referring to the offending option
+//     Errors compatible with errors.Is and errors.As
+//     Suggestions for mistyped long options
+//     Report all errors at once (optional)
+//     Custom formatting of error messages
+//     Configurable error output and help on error
+//     Trace parse events for debugging (GO_FLAGS_DEBUG)
+//     Warnings for deprecated and ignored options
+//     Consistent exit codes for errors (HandleError)
+//     Add and remove options and groups at runtime
+//     Nested groups with namespaces and embedded structs
+//     Add option groups of several packages to a single parser
+//     Define groups of options using maps (NewMapGroup)
+//     Short and long name aliases of options
+//     Find out which options were explicitly set (IsSet)
+//     Default values given by tags (default)
+//     Defaults computed by functions when an option is not set
+//     Show masked or computed defaults in the help message (default-mask)
+//     Long descriptions of options provided by methods (LongDescriber)
+//     Descriptions from external catalogs, e.g. translations (SetDescriptions)
+//     Descriptions referring to defaults and environment variables ({{.Default}}, {{.Env}})
+//     Wrap the help message at a fixed width (HelpWidth)
+//     Configurable layout of the help message (HelpLayout)
+//     Clone parsers to parse concurrently
+//     Freeze parsers to share them as immutable templates
+//     Dry runs reporting the values options would be set to (DryRun)
+//     Export the options as a machine readable spec or JSON Schema (Parser.Spec)
+//     Report breaking changes of the command line interface (CompareSpecs)
+//     Custom names of field tags (NewGroupWithTags)
+//     Compact single tag syntax
+//     Import flags defined using the standard flag package
+//     Export options as flags of the flag package or pflag
+//     Use values of the flag package as options (Getter)
+//     Convert option values back into command line arguments
+//     Generate code creating option groups and converting arguments without reflection (flagsgen)
+//     Check field tags when vetting code (flagsvet, also as go vet -vettool)
+//     Helpers for testing command line interfaces and golden help files (flagstest)
+//     Ready-made logging options using log/slog (flagslog)
+//     Ready-made HTTP client options (flagshttp)
+//     Ready-made TLS options (flagstls)
+//     Typed parsing using generics (ParseArgs[T])
+//     Process very large argument lists in a single pass (ParseArgsFunc)
+//     Iterate over the tokens of the command line to drive parsing incrementally (Tokens)
+//     Parse without side effects, e.g. as a fuzz target (ParseStrings)
 //
 // The flags package uses structs, reflection and struct field tags
 // to allow users to specify command line options. This results in very simple
@@ -38,13 +117,109 @@
 //
 // Available field tags:
 //     short:       the short name of the option (single character)
+//     short-alias: an additional short name of the option, can be
+//                  specified multiple times (optional)
 //     long:        the long name of the option
-//     description: the description of the option (optional)
+//     long-alias:  an additional long name of the option, can be specified
+//                  multiple times (optional)
+//     description: the description of the option, which can refer to
+//                  {{.Default}}, {{.Env}}, {{.Name}} and {{.Choices}}
+//                  (optional)
 //     optional:    whether an argument of the option is optional (optional)
-//     default:     the default argument value if the option occurs without
-//                  an argument (optional)
+//     default:     the default value of the option, or the argument value if
+//                  the option occurs without an argument for options with
+//                  an optional argument (optional)
+//     default-mask: shown as the default value in the help message instead
+//                  of the value of the option, or "-" to not show the
+//                  default value (optional)
+//     default-func: the name of a method of the struct returning the value of
+//                  the option when it is not set by any source, called after
+//                  parsing (see Option.DefaultFunc) (optional)
 //     base:        a base used to convert strings to integer values (optional)
+//     value-name:  the name of the argument value, used as a placeholder in
+//                  shell completions (optional)
+//     complete:    restricts the completion of the argument to directories
+//                  ("dirs") or to files matching a glob pattern (optional)
+//     choice:      a valid argument of the option, can be specified multiple
+//                  times (optional)
+//     no-completion: if non-empty, the option is not included in shell
+//                  completions (optional)
+//     env:         the name of the environment variable from which the
+//                  value of the option is read by EnvironmentSource (optional)
+//     env-delim:   a delimiter on which the value of the environment variable
+//                  is split for options which can be specified multiple
+//                  times (optional)
+//     min:         the minimum value of a numeric or duration option (optional)
+//     max:         the maximum value of a numeric or duration option (optional)
+//     pattern:     a regular expression which the argument of the option
+//                  must match (optional)
+//     sources:     a comma separated list of the kinds of sources which may
+//                  set the option: cli, env and config. The option cannot be
+//                  specified on the command line without cli, and values
+//                  of other sources are ignored (optional)
+//     expand:      a comma separated list of expansions of the argument of
+//                  the option from any source: env expands environment
+//                  variables ($VAR or ${VAR}, $$ for a literal $) and home
+//                  expands a leading ~ to the home directory (optional)
+//     requires:    the long name of an option which must be specified when
+//                  this option is specified, can be specified multiple
+//                  times (optional)
+//     required-if: a condition under which the option must be specified,
+//                  either the long name of another option which is
+//                  specified, or name=value when the other option has the
+//                  given value. Can be specified multiple times (optional)
+//     at-least-one-of: the name of a set of options of which at least one
+//                  must be specified, can be specified multiple times
+//                  (optional)
+//     max-occurrences: the maximum number of times the option can be
+//                  specified (optional)
+//     min-occurrences: the minimum number of times the option must be
+//                  specified (optional)
+//     counter:     if non-empty, the option of a signed integer field takes
+//                  no argument and increments the field each time it is
+//                  specified on the command line. Other sources set the
+//                  value of the field (optional)
+//     decrement-short: the short name of an option decrementing the field of
+//                  a counter option, e.g. -q for -v, implies counter
+//                  (optional)
+//     decrement-long: the long name of an option decrementing the field of a
+//                  counter option, implies counter (optional)
+//     decrement-description: the description of the decrement option
+//                  (optional)
+//     deprecated:  a message explaining that the option is deprecated,
+//                  reported as a warning when the option is used (optional)
+//     config-file: if non-empty, the argument of the option is the name of a
+//                  configuration file which is loaded when the option is
+//                  encountered on the command line (optional)
+//     required:    if non-empty, the option must be set by one of the sources
+//                  (optional)
+//     prompt:      if non-empty, the value of the option is asked for on the
+//                  terminal when it is not set by any source. If "secure",
+//                  the option is secret and entered without echo (optional)
+//     secret:      if non-empty, the value of the option is masked as *** in
+//                  help, errors, traces and configuration dumps (optional)
 //
 // Either short: or long: must be specified to make the field eligible as an
 // option.
+//
+// Fields of a struct type can instead be tagged as a group of options:
+//     group:         the name of a sub-group containing the options of the
+//                    struct
+//     namespace:     a prefix of the long names of the options of the
+//                    sub-group, separated by a dot (optional)
+//     env-namespace: a prefix of the environment variable names of the
+//                    options of the sub-group, separated by an underscore
+//                    (optional)
+//
+// The options of anonymous embedded structs are added to the group of the
+// embedding struct.
+//
+// Alternatively, all the tags of a field can be given by a single compact
+// flags tag, in which -x and --name specify the short and long names,
+// key=value items specify other tags (values may be enclosed in single
+// quotes), and other items specify tags with the value "true". The
+// abbreviations desc, ns and env-ns can be used for description, namespace
+// and env-namespace. For example:
+//
+//     Port int `flags:"-p, --port, desc='Port to listen on', default=8080"`
 package flags
@@ -0,0 +1,244 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// AddFlagSet adds an option to the group for each flag defined in a
+// flag.FlagSet of the standard library, such that existing flag definitions
+// keep working when migrating to this package. The options set the flags
+// using set.Set, so that the values are stored by the flag.Value of each
+// flag and set.Visit reports the flags which were specified. Flags with a
+// single character name become options with a short name (-v), all other
+// flags options with a long name (--verbose). Boolean flags do not take an
+// argument. Copies of the group (see Group.Clone) set the same flags.
+func (g *Group) AddFlagSet(set *flag.FlagSet) error {
+	var err error
+
+	set.VisitAll(func(f *flag.Flag) {
+		if err == nil {
+			err = g.addFlag(set, f)
+		}
+	})
+
+	return err
+}
+
+// boolFlag is implemented by flag values which do not take an argument, as
+// in the flag package.
+type boolFlag interface {
+	IsBoolFlag() bool
+}
+
+func (g *Group) addFlag(set *flag.FlagSet, f *flag.Flag) error {
+	var value interface{}
+
+	isBool := false
+
+	if b, ok := f.Value.(boolFlag); ok && b.IsBoolFlag() {
+		isBool = true
+	}
+
+	if isBool {
+		value = func() error {
+			return set.Set(f.Name, "true")
+		}
+	} else {
+		value = func(arg string) error {
+			return set.Set(f.Name, arg)
+		}
+	}
+
+	description := f.Usage
+
+	if f.DefValue != "" && !isBool {
+		description = fmt.Sprintf("%s (%s)", f.Usage, f.DefValue)
+	}
+
+	longName := f.Name
+	shortName := rune(0)
+
+	if utf8.RuneCountInString(f.Name) == 1 {
+		shortName, _ = utf8.DecodeRuneInString(f.Name)
+		longName = ""
+	}
+
+	_, err := g.AddOption(longName, shortName, description, value)
+	return err
+}
+
+// Getter wraps a flag.Getter of the standard flag package, such that values
+// implemented for the flag package can be used as options. Fields of type
+// Getter must be initialized with the wrapped value before the group is
+// created. The argument of the option is passed to the Set method of the
+// value and its String method renders the value in the builtin help. Values
+// implementing IsBoolFlag() bool, returning true, do not take an argument.
+// For example:
+//
+//	type Options struct {
+//	    Level flags.Getter `long:"level" description:"Log level"`
+//	}
+//
+//	opts := Options{Level: flags.Getter{&level}}
+type Getter struct {
+	flag.Getter
+}
+
+var getterType = reflect.TypeOf(Getter{})
+
+func (g Getter) isBoolFlag() bool {
+	b, ok := g.Getter.(boolFlag)
+	return ok && b.IsBoolFlag()
+}
+
+// setGetter sets the value wrapped by a Getter.
+func setGetter(val reflect.Value, value string) error {
+	g := val.Interface().(Getter)
+
+	if g.Getter == nil {
+		return errors.New("the flag.Getter of the option is not initialized")
+	}
+
+	if value == "" && g.isBoolFlag() {
+		value = "true"
+	}
+
+	return g.Set(value)
+}
+
+// getterString renders the value wrapped by a Getter.
+func getterString(val reflect.Value) string {
+	if g := val.Interface().(Getter); g.Getter != nil {
+		return g.String()
+	}
+
+	return ""
+}
+
+// FlagValue is an option exposed as the value of a flag of the standard flag
+// package (see Option.FlagValue and Group.AddToFlagSet), or of packages
+// modelled after it, such as pflag, which additionally uses the Type method.
+type FlagValue interface {
+	flag.Getter
+
+	// Type returns the name of the type of the value (e.g. "int")
+	Type() string
+}
+
+type optionFlagValue struct {
+	option *Option
+	name   string
+}
+
+// FlagValue returns the option as the value of a flag, such that options
+// defined using this package can be added to a flag set of another flag
+// package. Setting the flag sets the option as if it was specified on the
+// command line. For example, to add an option to a pflag (or cobra) flag
+// set:
+//
+//	value := option.FlagValue()
+//	fs.VarP(value, option.LongName, string(option.ShortName), option.Description)
+//
+//	if value.(interface{ IsBoolFlag() bool }).IsBoolFlag() {
+//	    fs.Lookup(option.LongName).NoOptDefVal = "true"
+//	}
+func (option *Option) FlagValue() FlagValue {
+	return &optionFlagValue{option: option, name: option.synopsis()}
+}
+
+// AddToFlagSet adds a flag to a flag.FlagSet of the standard library for
+// each option of the group, under both its long and short names. This also
+// allows adding the options to a pflag flag set using its AddGoFlagSet
+// function.
+func (g *Group) AddToFlagSet(set *flag.FlagSet) {
+	for _, option := range g.Options {
+		if option.LongName != "" {
+			set.Var(&optionFlagValue{option, "--" + option.LongName}, option.LongName, option.description())
+		}
+
+		if option.ShortName != 0 {
+			set.Var(&optionFlagValue{option, "-" + string(option.ShortName)}, string(option.ShortName), option.description())
+		}
+	}
+}
+
+func (v *optionFlagValue) String() string {
+	if v == nil || v.option == nil || v.option.isFunc() {
+		return ""
+	}
+
+	if v.option.isBool() && v.option.value.Kind() == reflect.Bool {
+		return strconv.FormatBool(v.option.value.Bool())
+	}
+
+	return convertToString(v.option.value, v.option.options)
+}
+
+func (v *optionFlagValue) Set(value string) error {
+	option := v.option
+
+	if !option.IsSet() {
+		option.clearDefault()
+	}
+
+	option.layer = commandLineLayer
+	option.origin = Origin{Type: OriginCommandLine, Name: v.name}
+
+	if option.canArgument() {
+		return option.marshalError(option.Set(&value))
+	}
+
+	b, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return err
+	}
+
+	if !b {
+		if option.value.Kind() == reflect.Bool {
+			option.value.SetBool(false)
+		}
+
+		return nil
+	}
+
+	return option.marshalError(option.Set(nil))
+}
+
+func (v *optionFlagValue) Get() interface{} {
+	return v.option.value.Interface()
+}
+
+func (v *optionFlagValue) Type() string {
+	tp := v.option.value.Type()
+
+	switch {
+	case tp == durationType:
+		return "duration"
+	case tp.Kind() == reflect.Slice:
+		return tp.Elem().Kind().String() + "Slice"
+	case tp.Kind() == reflect.Func:
+		if tp.NumIn() == 0 {
+			return "bool"
+		}
+
+		return tp.In(0).Kind().String()
+	}
+
+	return tp.Kind().String()
+}
+
+// IsBoolFlag returns whether the flag does not take an argument, as
+// recognized by the flag package.
+func (v *optionFlagValue) IsBoolFlag() bool {
+	return !v.option.canArgument()
+}
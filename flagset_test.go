@@ -0,0 +1,164 @@
+package flags
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAddFlagSet(t *testing.T) {
+	set := flag.NewFlagSet("legacy", flag.ContinueOnError)
+
+	port := set.Int("port", 80, "Port")
+	verbose := set.Bool("v", false, "Verbose")
+	name := set.String("name", "", "Name")
+
+	grp := NewGroup("Legacy Options", nil)
+
+	if err := grp.AddFlagSet(set); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	p := NewNamedParser("test", None, grp)
+
+	if _, err := p.ParseArgs([]string{"--port", "8080", "-v"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if *port != 8080 || !*verbose || *name != "" {
+		t.Errorf("Unexpected values: %d, %v, %q", *port, *verbose, *name)
+	}
+
+	var specified []string
+
+	set.Visit(func(f *flag.Flag) {
+		specified = append(specified, f.Name)
+	})
+
+	if strings.Join(specified, ",") != "port,v" {
+		t.Errorf("Unexpected specified flags: %v", specified)
+	}
+
+	message := "invalid argument for flag `--port': parse error"
+
+	if _, err := p.ParseArgs([]string{"--port", "x"}); err == nil || err.Error() != message {
+		t.Errorf("Expected error %q but got %v", message, err)
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if !strings.Contains(b.String(), "--port    Port (80)") {
+		t.Errorf("Expected flag in help message:\n%s", b.String())
+	}
+}
+
+func TestAddToFlagSet(t *testing.T) {
+	var opts struct {
+		Verbose bool     `short:"v" long:"verbose" description:"Verbose"`
+		Port    int      `long:"port" default:"80" description:"Port"`
+		Hosts   []string `long:"host" description:"Host"`
+		Mode    string   `long:"mode" choice:"a" choice:"b"`
+	}
+
+	grp := NewGroup("Application Options", &opts)
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.SetOutput(io.Discard)
+
+	grp.AddToFlagSet(set)
+
+	if f := set.Lookup("port"); f == nil || f.DefValue != "80" || f.Usage != "Port" {
+		t.Fatalf("Unexpected flag: %+v", f)
+	}
+
+	if err := set.Parse([]string{"-v", "-port", "8080", "--host", "a", "-host", "b"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !opts.Verbose || opts.Port != 8080 || strings.Join(opts.Hosts, ",") != "a,b" {
+		t.Errorf("Unexpected values: %+v", opts)
+	}
+
+	if option := grp.LongNames["port"]; !option.IsSet() || option.Origin().Type != OriginCommandLine {
+		t.Errorf("Expected option to be set from the command line")
+	}
+
+	if err := set.Parse([]string{"-mode", "c"}); err == nil {
+		t.Errorf("Expected error for invalid choice")
+	}
+
+	value := grp.LongNames["host"].FlagValue()
+
+	if value.Type() != "stringSlice" || value.String() != "[a, b]" {
+		t.Errorf("Unexpected flag value: %s, %s", value.Type(), value.String())
+	}
+}
+
+type testLevel int
+
+func (l *testLevel) String() string {
+	return [...]string{"info", "debug"}[*l]
+}
+
+func (l *testLevel) Set(value string) error {
+	switch value {
+	case "info":
+		*l = 0
+	case "debug":
+		*l = 1
+	default:
+		return errors.New("unknown level")
+	}
+
+	return nil
+}
+
+func (l *testLevel) Get() interface{} {
+	return int(*l)
+}
+
+func TestGetter(t *testing.T) {
+	var level testLevel
+	var trace bool
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.BoolVar(&trace, "trace", false, "Trace")
+
+	opts := struct {
+		Level Getter `long:"level" description:"Level"`
+		Trace Getter `long:"trace"`
+	}{
+		Level: Getter{&level},
+		Trace: Getter{set.Lookup("trace").Value.(flag.Getter)},
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if err := p.Groups[0].Error; err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if !strings.Contains(b.String(), "Level (info)") {
+		t.Errorf("Expected value in help message:\n%s", b.String())
+	}
+
+	if _, err := p.ParseArgs([]string{"--level", "debug", "--trace"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if level != 1 || !trace {
+		t.Errorf("Unexpected values: %v, %v", level, trace)
+	}
+
+	message := "invalid argument for flag `--level': unknown level"
+
+	if _, err := p.ParseArgs([]string{"--level", "x"}); err == nil || err.Error() != message {
+		t.Errorf("Expected error %q but got %v", message, err)
+	}
+}
@@ -0,0 +1,598 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Flagsgen generates code which creates the option group of a struct type
+// annotated with the field tags of the flags package, such that the struct
+// does not have to be scanned using reflection when the program starts.
+// It is meant to be used with go generate:
+//
+//	//go:generate flagsgen -type Options
+//
+// For each type, a function New<Type>Group(name string, data *<Type>)
+// *flags.Group is generated in the file <type>_flags.go, which creates the
+// same group as flags.NewGroup(name, data). Default values are converted
+// into typed assignments when the code is generated, so that invalid
+// defaults are reported by flagsgen instead of at runtime. Arguments of
+// options of basic types (strings, booleans, numbers and time.Duration,
+// and slices of them) are converted by generated typed code, which is set
+// as the Setter of the options, instead of the reflection based converters
+// of the flags package.
+//
+// The flags package still uses reflection to hold the values of the
+// options, e.g. to show defaults in the help message or to write
+// configuration files, and to convert the arguments of options of other
+// types, such as maps.
+//
+// Only the tags which correspond to properties of flags.Option are
+// supported. Flagsgen reports an error for fields which use other tags
+// (e.g. min or requires), sub-groups or embedded structs, for which
+// flags.NewGroup must be used instead.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// unsupportedTags are the field tags of the flags package which cannot be
+// expressed using properties of flags.Option. Tags of other packages are
+// ignored, like flags.NewGroup does.
+var unsupportedTags = map[string]bool{
+	"short-alias":           true,
+	"long-alias":            true,
+	"default-func":          true,
+	"base":                  true,
+	"complete":              true,
+	"min":                   true,
+	"max":                   true,
+	"pattern":               true,
+	"expand":                true,
+	"sources":               true,
+	"requires":              true,
+	"required-if":           true,
+	"at-least-one-of":       true,
+	"max-occurrences":       true,
+	"min-occurrences":       true,
+	"deprecated":            true,
+	"counter":               true,
+	"decrement-short":       true,
+	"decrement-long":        true,
+	"decrement-description": true,
+	"namespace":             true,
+	"env-namespace":         true,
+	"flags":                 true,
+}
+
+func main() {
+	types := flag.String("type", "", "comma-separated list of type names")
+	output := flag.String("output", "", "output file name (default <type>_flags.go)")
+	flag.Parse()
+
+	if *types == "" {
+		fmt.Fprintln(os.Stderr, "flagsgen: the -type flag is required")
+		os.Exit(2)
+	}
+
+	dir := "."
+
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	names := strings.Split(*types, ",")
+	filename := *output
+
+	if filename == "" {
+		filename = filepath.Join(dir, strings.ToLower(names[0])+"_flags.go")
+	}
+
+	src, err := generateDir(dir, names)
+
+	if err == nil {
+		err = os.WriteFile(filename, src, 0644)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "flagsgen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// generateDir generates the code for the given types of the package in dir.
+func generateDir(dir string, names []string) ([]byte, error) {
+	fset := token.NewFileSet()
+
+	notGenerated := func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && !strings.HasSuffix(info.Name(), "_flags.go")
+	}
+
+	pkgs, err := parser.ParseDir(fset, dir, notGenerated, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected a single package in %s", dir)
+	}
+
+	var files []*ast.File
+
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			files = append(files, file)
+		}
+	}
+
+	return generate(files, names)
+}
+
+// generate generates the code for the given types, declared in files.
+func generate(files []*ast.File, names []string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	pkgname := ""
+	imports := map[string]bool{}
+	var funcs bytes.Buffer
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		st, file := findStruct(files, name)
+
+		if st == nil {
+			return nil, fmt.Errorf("struct type `%s' not found", name)
+		}
+
+		pkgname = file.Name.Name
+
+		if err := generateGroup(&funcs, name, st, imports); err != nil {
+			return nil, fmt.Errorf("type `%s': %s", name, err)
+		}
+	}
+
+	fmt.Fprintf(&buf, "// Code generated by flagsgen -type %s; DO NOT EDIT.\n\n", strings.Join(names, ","))
+	fmt.Fprintf(&buf, "package %s\n\n", pkgname)
+
+	paths := []string{"github.com/jessevdk/go-flags"}
+
+	for path := range imports {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	fmt.Fprintf(&buf, "import (\n")
+
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "%s\n", strconv.Quote(path))
+	}
+
+	fmt.Fprintf(&buf, ")\n")
+	buf.Write(funcs.Bytes())
+
+	return format.Source(buf.Bytes())
+}
+
+// findStruct returns the declaration of the struct type with the given name
+// and the file declaring it.
+func findStruct(files []*ast.File, name string) (*ast.StructType, *ast.File) {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+
+				if st, ok := ts.Type.(*ast.StructType); ok && ts.Name.Name == name {
+					return st, file
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// generateGroup writes the function creating the group of the struct type.
+func generateGroup(buf *bytes.Buffer, name string, st *ast.StructType, imports map[string]bool) error {
+	fmt.Fprintf(buf, "\n// New%sGroup creates the option group of the fields of data, like\n", name)
+	fmt.Fprintf(buf, "// flags.NewGroup(name, data), without scanning the struct at runtime.\n")
+	fmt.Fprintf(buf, "func New%sGroup(name string, data *%s) *flags.Group {\n", name, name)
+	fmt.Fprintf(buf, "grp := flags.NewGroup(name, nil)\n")
+
+	declared := false
+
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+
+		if field.Tag != nil {
+			s, err := strconv.Unquote(field.Tag.Value)
+
+			if err != nil {
+				return err
+			}
+
+			tag = reflect.StructTag(s)
+		}
+
+		if len(field.Names) == 0 {
+			if tag.Get("no-flag") == "" {
+				return errors.New("embedded structs are not supported")
+			}
+
+			continue
+		}
+
+		if tag.Get("flags") != "" {
+			return fmt.Errorf("field `%s': compact flags tags are not supported", field.Names[0].Name)
+		}
+
+		if tag.Get("no-flag") != "" || (tag.Get("long") == "" && tag.Get("short") == "") {
+			if tag.Get("group") != "" {
+				return fmt.Errorf("field `%s': groups are not supported", field.Names[0].Name)
+			}
+
+			continue
+		}
+
+		for _, ident := range field.Names {
+			if !ident.IsExported() {
+				return fmt.Errorf("field `%s' is tagged as an option but is not exported", ident.Name)
+			}
+
+			if !declared {
+				fmt.Fprintf(buf, "\nvar option *flags.Option\nvar err error\n")
+				fmt.Fprintf(buf, "describer, _ := interface{}(data).(flags.LongDescriber)\n")
+				declared = true
+			}
+
+			if err := generateOption(buf, ident.Name, field.Type, tag, imports); err != nil {
+				return fmt.Errorf("field `%s': %s", ident.Name, err)
+			}
+		}
+	}
+
+	if declared {
+		fmt.Fprintf(buf, "\n_ = option\n")
+	}
+
+	fmt.Fprintf(buf, "\nreturn grp\n}\n")
+	return nil
+}
+
+// generateOption writes the code adding the option of a struct field.
+func generateOption(buf *bytes.Buffer, field string, tp ast.Expr, tag reflect.StructTag, imports map[string]bool) error {
+	var keys []string
+
+	for _, key := range tagKeys(tag) {
+		if unsupportedTags[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) > 0 {
+		return fmt.Errorf("unsupported tag `%s'", strings.Join(keys, "', `"))
+	}
+
+	short := "0"
+
+	if s := tag.Get("short"); s != "" {
+		if utf8.RuneCountInString(s) != 1 {
+			return errors.New("short names can only be 1 character")
+		}
+
+		r, _ := utf8.DecodeRuneInString(s)
+		short = strconv.QuoteRune(r)
+	}
+
+	def := tag.Get("default")
+	optional := tag.Get("optional") != ""
+
+	fmt.Fprintln(buf)
+
+	// Default values initialize the field (see flags.Option.Default)
+	if def != "" && !optional {
+		value, err := literal(tp, def, imports)
+
+		if err != nil {
+			return fmt.Errorf("invalid default value: %s", err)
+		}
+
+		fmt.Fprintf(buf, "data.%s = %s\n\n", field, value)
+	}
+
+	fmt.Fprintf(buf, "if option, err = grp.AddOption(%s, %s, %s, &data.%s); err != nil {\n",
+		strconv.Quote(tag.Get("long")), short, strconv.Quote(tag.Get("description")), field)
+	fmt.Fprintf(buf, "grp.Error = err\nreturn grp\n}\n")
+
+	if def != "" {
+		fmt.Fprintf(buf, "option.Default = %s\n", strconv.Quote(def))
+	}
+
+	// Arguments of basic types are converted by typed code instead of the
+	// converters of the flags package
+	if body, ok := setter(tp, "data."+field, imports); ok {
+		fmt.Fprintf(buf, "option.Setter = func(value string) error {\n%s}\n", body)
+	}
+
+	if optional {
+		fmt.Fprintf(buf, "option.OptionalArgument = true\n")
+	}
+
+	properties := []struct {
+		name, tag string
+	}{
+		{"DefaultMask", "default-mask"},
+		{"ValueName", "value-name"},
+		{"EnvName", "env"},
+		{"EnvDelim", "env-delim"},
+	}
+
+	for _, property := range properties {
+		if v := tag.Get(property.tag); v != "" {
+			fmt.Fprintf(buf, "option.%s = %s\n", property.name, strconv.Quote(v))
+		}
+	}
+
+	// Long descriptions are provided by the struct, like for flags.NewGroup
+	fmt.Fprintf(buf, "if describer != nil {\noption.LongDescription = describer.LongDescription(%s)\n}\n", strconv.Quote(field))
+
+	if tag.Get("no-completion") != "" {
+		fmt.Fprintf(buf, "option.NoCompletion = true\n")
+	}
+
+	if tag.Get("config-file") != "" {
+		fmt.Fprintf(buf, "option.ConfigFile = true\n")
+	}
+
+	if tag.Get("required") != "" {
+		fmt.Fprintf(buf, "option.Required = true\n")
+	}
+
+	if prompt := tag.Get("prompt"); prompt != "" {
+		fmt.Fprintf(buf, "option.Prompt = true\n")
+	}
+
+	if tag.Get("prompt") == "secure" || tag.Get("secret") != "" {
+		fmt.Fprintf(buf, "option.Secret = true\n")
+	}
+
+	if choices := tagValues(tag, "choice"); len(choices) > 0 {
+		quoted := make([]string, len(choices))
+
+		for i, choice := range choices {
+			quoted[i] = strconv.Quote(choice)
+		}
+
+		fmt.Fprintf(buf, "option.Choices = []string{%s}\n", strings.Join(quoted, ", "))
+	}
+
+	return nil
+}
+
+// literal converts a default value into a Go expression of the given type.
+// Slices are initialized to a single element, like the default tag.
+func literal(tp ast.Expr, value string, imports map[string]bool) (string, error) {
+	switch t := tp.(type) {
+	case *ast.Ident:
+		return basicLiteral(t.Name, value)
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "time" && t.Sel.Name == "Duration" {
+			d, err := time.ParseDuration(value)
+
+			if err != nil {
+				return "", err
+			}
+
+			imports["time"] = true
+			return fmt.Sprintf("time.Duration(%d)", int64(d)), nil
+		}
+	case *ast.ArrayType:
+		if t.Len == nil {
+			elem, err := literal(t.Elt, value, imports)
+
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf("%s{%s}", typeString(t), elem), nil
+		}
+	}
+
+	return "", fmt.Errorf("default values of type %s are not supported", typeString(tp))
+}
+
+// setter returns the body of the Setter function of an option of the given
+// type, which sets the variable to, or appends to it for slices, the value
+// converted from the argument, and false for types which are converted by
+// the flags package.
+func setter(tp ast.Expr, variable string, imports map[string]bool) (string, bool) {
+	assign := func(v string) string {
+		return fmt.Sprintf("%s = %s\nreturn nil\n", variable, v)
+	}
+
+	if t, ok := tp.(*ast.ArrayType); ok && t.Len == nil {
+		elem := typeString(t.Elt)
+
+		assign = func(v string) string {
+			return fmt.Sprintf("%s = append(%s, %s)\nreturn nil\n", variable, variable, v)
+		}
+
+		if _, ok := t.Elt.(*ast.ArrayType); ok {
+			return "", false
+		}
+
+		return argumentParser(t.Elt, elem, assign, imports)
+	}
+
+	return argumentParser(tp, typeString(tp), assign, imports)
+}
+
+// argumentParser returns the code converting the argument named value to a value of
+// the given type, which is then assigned using assign.
+func argumentParser(tp ast.Expr, name string, assign func(v string) string, imports map[string]bool) (string, bool) {
+	parse := func(call string, conversion string) (string, bool) {
+		imports["strconv"] = true
+		return fmt.Sprintf("v, err := %s\nif err != nil {\nreturn err\n}\n%s", call, assign(conversion)), true
+	}
+
+	if typeString(tp) == "time.Duration" {
+		imports["time"] = true
+		return fmt.Sprintf("v, err := time.ParseDuration(value)\nif err != nil {\nreturn err\n}\n%s", assign("v")), true
+	}
+
+	if _, ok := tp.(*ast.Ident); !ok {
+		return "", false
+	}
+
+	switch name {
+	case "string":
+		return assign("value"), true
+	case "bool":
+		// Flags are specified without an argument
+		code, ok := parse("strconv.ParseBool(value)", "v")
+		return "if value == \"\" {\nvalue = \"true\"\n}\n" + code, ok
+	case "int", "int8", "int16", "int32", "int64":
+		bits := map[string]int{"int": 0, "int8": 8, "int16": 16, "int32": 32, "int64": 64}[name]
+		return parse(fmt.Sprintf("strconv.ParseInt(value, 10, %d)", bits), fmt.Sprintf("%s(v)", name))
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		bits := map[string]int{"uint": 0, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64}[name]
+		return parse(fmt.Sprintf("strconv.ParseUint(value, 10, %d)", bits), fmt.Sprintf("%s(v)", name))
+	case "float32", "float64":
+		bits := map[string]int{"float32": 32, "float64": 64}[name]
+		return parse(fmt.Sprintf("strconv.ParseFloat(value, %d)", bits), fmt.Sprintf("%s(v)", name))
+	}
+
+	return "", false
+}
+
+// basicLiteral converts a value into a literal of a predeclared type.
+func basicLiteral(tp string, value string) (string, error) {
+	switch tp {
+	case "string":
+		return strconv.Quote(value), nil
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		return strconv.FormatBool(b), err
+	case "int", "int8", "int16", "int32", "int64":
+		bits := map[string]int{"int": 0, "int8": 8, "int16": 16, "int32": 32, "int64": 64}[tp]
+		i, err := strconv.ParseInt(value, 10, bits)
+		return strconv.FormatInt(i, 10), err
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		bits := map[string]int{"uint": 0, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64}[tp]
+		u, err := strconv.ParseUint(value, 10, bits)
+		return strconv.FormatUint(u, 10), err
+	case "float32", "float64":
+		bits := map[string]int{"float32": 32, "float64": 64}[tp]
+		f, err := strconv.ParseFloat(value, bits)
+		return fmt.Sprintf("%s(%s)", tp, strconv.FormatFloat(f, 'g', -1, bits)), err
+	}
+
+	return "", fmt.Errorf("default values of type %s are not supported", tp)
+}
+
+// typeString formats a type expression.
+func typeString(tp ast.Expr) string {
+	switch t := tp.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return typeString(t.X) + "." + t.Sel.Name
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "[]" + typeString(t.Elt)
+		}
+	case *ast.StarExpr:
+		return "*" + typeString(t.X)
+	case *ast.MapType:
+		return "map[" + typeString(t.Key) + "]" + typeString(t.Value)
+	}
+
+	return fmt.Sprintf("%T", tp)
+}
+
+// tagKeys returns the keys of a struct tag, in order.
+func tagKeys(tag reflect.StructTag) []string {
+	var ret []string
+
+	for _, pair := range tagPairs(tag) {
+		ret = append(ret, pair[0])
+	}
+
+	return ret
+}
+
+// tagValues returns all the values of key in tag.
+func tagValues(tag reflect.StructTag, key string) []string {
+	var ret []string
+
+	for _, pair := range tagPairs(tag) {
+		if pair[0] == key {
+			ret = append(ret, pair[1])
+		}
+	}
+
+	return ret
+}
+
+// tagPairs returns the keys and values of a struct tag, following the
+// conventions of reflect.StructTag.
+func tagPairs(tag reflect.StructTag) [][2]string {
+	var ret [][2]string
+
+	s := strings.TrimSpace(string(tag))
+
+	for s != "" {
+		i := strings.Index(s, ":\"")
+
+		if i <= 0 {
+			break
+		}
+
+		key := s[:i]
+		s = s[i+1:]
+
+		j := 1
+
+		for j < len(s) && s[j] != '"' {
+			if s[j] == '\\' {
+				j++
+			}
+
+			j++
+		}
+
+		if j >= len(s) {
+			break
+		}
+
+		value, err := strconv.Unquote(s[:j+1])
+
+		if err != nil {
+			break
+		}
+
+		ret = append(ret, [2]string{key, value})
+		s = strings.TrimSpace(s[j+1:])
+	}
+
+	return ret
+}
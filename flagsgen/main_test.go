@@ -0,0 +1,96 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func generateSource(t *testing.T, src string, names ...string) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "options.go", src, 0)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	ret, err := generate([]*ast.File{file}, names)
+	return string(ret), err
+}
+
+func TestGenerate(t *testing.T) {
+	src := `package main
+
+import "time"
+
+type Options struct {
+	Verbose []bool        ` + "`short:\"v\" long:\"verbose\" description:\"Show verbose debug information\"`" + `
+	Port    int           ` + "`short:\"p\" long:\"port\" default:\"8080\" env:\"PORT\" json:\"port\"`" + `
+	Timeout time.Duration ` + "`long:\"timeout\" default:\"1m\"`" + `
+	Level   string        ` + "`long:\"level\" optional:\"yes\" default:\"info\" choice:\"info\" choice:\"debug\"`" + `
+	Ignored string
+}
+`
+
+	ret, err := generateSource(t, src, "Options")
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"// Code generated by flagsgen -type Options; DO NOT EDIT.",
+		"\"time\"",
+		"func NewOptionsGroup(name string, data *Options) *flags.Group {",
+		"grp.AddOption(\"verbose\", 'v', \"Show verbose debug information\", &data.Verbose)",
+		"data.Port = 8080\n\n",
+		"option.EnvName = \"PORT\"",
+		"option.LongDescription = describer.LongDescription(\"Port\")",
+		"data.Timeout = time.Duration(60000000000)",
+		"option.OptionalArgument = true",
+		"option.Choices = []string{\"info\", \"debug\"}",
+		"\"strconv\"",
+		"option.Setter = func(value string) error {",
+		"v, err := strconv.ParseBool(value)",
+		"data.Verbose = append(data.Verbose, v)",
+		"v, err := strconv.ParseInt(value, 10, 0)",
+		"data.Port = int(v)",
+		"v, err := time.ParseDuration(value)",
+		"data.Level = value",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(ret, e) {
+			t.Errorf("Expected generated code to contain %q:\n%s", e, ret)
+		}
+	}
+
+	if strings.Contains(ret, "data.Level = \"info\"") || strings.Contains(ret, "Ignored") {
+		t.Errorf("Unexpected initialization in generated code:\n%s", ret)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		field    string
+		expected string
+	}{
+		{"Port int `long:\"port\" default:\"http\"`", "type `Options': field `Port': invalid default value"},
+		{"Port int `long:\"port\" min:\"1\"`", "type `Options': field `Port': unsupported tag `min'"},
+		{"Sub struct{} `group:\"Sub\"`", "type `Options': field `Sub': groups are not supported"},
+		{"port int `long:\"port\"`", "field `port' is tagged as an option but is not exported"},
+	}
+
+	for _, test := range tests {
+		_, err := generateSource(t, "package main\n\ntype Options struct {\n"+test.field+"\n}\n", "Options")
+
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Expected error containing %q but got %v", test.expected, err)
+		}
+	}
+
+	if _, err := generateSource(t, "package main\n", "Options"); err == nil || err.Error() != "struct type `Options' not found" {
+		t.Errorf("Expected error for a missing type but got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package flagshttp provides a ready-made group of options configuring HTTP
+// clients: the timeout, proxy, number of retries, user agent and whether
+// the certificates of servers are verified. The options are added to a
+// parser as a group of their own, and the client is created after parsing.
+// For example:
+//
+//	var httpOpts flagshttp.Options
+//
+//	parser.AddGroup("HTTP Options", "", &httpOpts)
+//
+//	if _, err := parser.Parse(); err != nil {
+//		os.Exit(1)
+//	}
+//
+//	client, err := httpOpts.Client()
+package flagshttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Options are the HTTP client options. Without the proxy option, the proxy
+// is given by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables (see http.ProxyFromEnvironment).
+type Options struct {
+	Timeout   time.Duration `long:"timeout" description:"Time limit of requests, including retries (0 for no limit)" default:"30s" min:"0s"`
+	Proxy     string        `long:"proxy" description:"URL of the proxy server" value-name:"URL"`
+	Retries   uint          `long:"retries" description:"Number of times failed requests are retried" default:"0"`
+	UserAgent string        `long:"user-agent" description:"User agent sent with requests" value-name:"NAME"`
+	Insecure  bool          `long:"insecure" description:"Do not verify the certificates of servers"`
+}
+
+// The time waited before the first retry, which doubles with each retry
+var retryWait = 100 * time.Millisecond
+
+// Validate checks the proxy URL once the options have been parsed.
+func (o *Options) Validate() error {
+	_, err := o.proxyURL()
+	return err
+}
+
+func (o *Options) proxyURL() (*url.URL, error) {
+	if o.Proxy == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(o.Proxy)
+
+	if err == nil && u.Host == "" {
+		err = errors.New("missing host")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL `%s': %s", o.Proxy, err)
+	}
+
+	return u, nil
+}
+
+// Client returns an HTTP client configured by the options. Requests which
+// fail due to network errors, or with a status of 429 or 5xx, are retried
+// when they are idempotent and their bodies can be sent again, waiting
+// longer before each retry.
+func (o *Options) Client() (*http.Client, error) {
+	proxy, err := o.proxyURL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if o.Insecure {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	var rt http.RoundTripper = transport
+
+	if o.UserAgent != "" || o.Retries != 0 {
+		rt = &roundTripper{
+			base:      transport,
+			userAgent: o.UserAgent,
+			retries:   o.Retries,
+		}
+	}
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   o.Timeout,
+	}, nil
+}
+
+// roundTripper sets the user agent of requests and retries failed requests.
+type roundTripper struct {
+	base      http.RoundTripper
+	userAgent string
+	retries   uint
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests are not modified by round trippers, but cloned instead
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	wait := retryWait
+
+	for attempt := uint(0); ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+
+		if attempt == t.retries || !retryable(req, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		wait *= 2
+	}
+}
+
+// retryable returns whether the request can be retried after the given
+// response or error.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,120 @@
+package flagshttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+func parse(t *testing.T, args ...string) *Options {
+	var opts Options
+
+	p := flags.NewNamedParser("test", flags.None)
+	p.AddGroup("HTTP Options", "", &opts)
+
+	if _, err := p.ParseArgs(args); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return &opts
+}
+
+func TestClient(t *testing.T) {
+	defer func(wait time.Duration) {
+		retryWait = wait
+	}(retryWait)
+
+	retryWait = time.Millisecond
+
+	var requests int
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.UserAgent() != "test/1.0" {
+			t.Errorf("Expected the user agent but got %q", r.UserAgent())
+		}
+
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	defer server.Close()
+
+	client, err := parse(t, "--retries", "2", "--user-agent", "test/1.0", "--insecure", "--timeout", "5s").Client()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if client.Timeout != 5*time.Second {
+		t.Errorf("Expected a timeout of 5s but got %s", client.Timeout)
+	}
+
+	resp, err := client.Get(server.URL)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || requests != 3 {
+		t.Errorf("Expected success after 3 requests but got %d after %d", resp.StatusCode, requests)
+	}
+
+	// Requests which are not idempotent are not retried
+	requests = 0
+
+	if resp, err := client.Post(server.URL, "text/plain", strings.NewReader("data")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	} else if resp.Body.Close(); resp.StatusCode != http.StatusServiceUnavailable || requests != 1 {
+		t.Errorf("Expected a single failed request but got %d after %d", resp.StatusCode, requests)
+	}
+
+	// The certificate of the test server is not trusted
+	client, err = parse(t).Client()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if client.Timeout != 30*time.Second {
+		t.Errorf("Expected the default timeout but got %s", client.Timeout)
+	}
+
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("Expected a certificate error")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var opts Options
+
+	p := flags.NewNamedParser("test", flags.None)
+	p.AddGroup("HTTP Options", "", &opts)
+
+	_, err := p.ParseArgs([]string{"--proxy", "localhost"})
+
+	if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrValidation || !strings.Contains(e.Message, "invalid proxy URL `localhost'") {
+		t.Errorf("Expected a validation error but got %v", err)
+	}
+
+	client, err := parse(t, "--proxy", "http://proxy.example.com:3128").Client()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	req, _ := http.NewRequest("GET", "https://example.com", nil)
+	proxy, err := client.Transport.(*http.Transport).Proxy(req)
+
+	if err != nil || proxy.String() != "http://proxy.example.com:3128" {
+		t.Errorf("Expected the proxy but got %v, %v", proxy, err)
+	}
+}
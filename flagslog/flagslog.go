@@ -0,0 +1,85 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package flagslog provides a ready-made group of options configuring
+// logging using log/slog: the minimum level, the format (text or JSON) and
+// the file of the log. The options are added to a parser as a group of
+// their own, and the logger is created after parsing. For example:
+//
+//	var logOpts flagslog.Options
+//
+//	parser.AddGroup("Logging Options", "", &logOpts)
+//
+//	if _, err := parser.Parse(); err != nil {
+//		os.Exit(1)
+//	}
+//
+//	logger, closeLog, err := logOpts.NewLogger()
+//
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	defer closeLog()
+//	slog.SetDefault(logger)
+package flagslog
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Options are the logging options. The level and format can also be set by
+// the LOG_LEVEL and LOG_FORMAT environment variables when the environment
+// is used as a source of option values.
+type Options struct {
+	Level  string `long:"log-level" description:"Minimum level of logged messages" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"info" env:"LOG_LEVEL"`
+	Format string `long:"log-format" description:"Format of logged messages" choice:"text" choice:"json" default:"text" env:"LOG_FORMAT"`
+	File   string `long:"log-file" description:"Append the log to FILE instead of writing it to the standard error" value-name:"FILE"`
+}
+
+// LevelValue returns the minimum level of logged messages. Levels which are
+// not recognized by slog, which the choices of the level option rule out,
+// are treated as info.
+func (o *Options) LevelValue() slog.Level {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+		return slog.LevelInfo
+	}
+
+	return level
+}
+
+// Handler returns a handler writing messages of at least the minimum level
+// to the writer in the configured format.
+func (o *Options) Handler(writer io.Writer) slog.Handler {
+	options := &slog.HandlerOptions{
+		Level: o.LevelValue(),
+	}
+
+	if o.Format == "json" {
+		return slog.NewJSONHandler(writer, options)
+	}
+
+	return slog.NewTextHandler(writer, options)
+}
+
+// NewLogger returns a logger configured by the options, writing to the log
+// file or to the standard error. The returned function closes the log file,
+// and should be called when the logger is no longer used.
+func (o *Options) NewLogger() (*slog.Logger, func() error, error) {
+	if o.File == "" {
+		return slog.New(o.Handler(os.Stderr)), func() error { return nil }, nil
+	}
+
+	fp, err := os.OpenFile(o.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return slog.New(o.Handler(fp)), fp.Close, nil
+}
@@ -0,0 +1,93 @@
+package flagslog
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+)
+
+func newParser(opts *Options) *flags.Parser {
+	p := flags.NewNamedParser("test", flags.None)
+	p.AddGroup("Logging Options", "", opts)
+
+	return p
+}
+
+func TestOptions(t *testing.T) {
+	var opts Options
+
+	p := newParser(&opts)
+
+	p.LookupEnv = func(name string) (string, bool) {
+		if name == "LOG_FORMAT" {
+			return "json", true
+		}
+
+		return "", false
+	}
+
+	if _, err := p.ParseSources(flags.CommandLineSource([]string{"--log-level", "warn"}), flags.EnvironmentSource()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if level := opts.LevelValue(); level != slog.LevelWarn {
+		t.Errorf("Expected level warn but got %s", level)
+	}
+
+	var b bytes.Buffer
+
+	logger := slog.New(opts.Handler(&b))
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if s := b.String(); strings.Contains(s, "hidden") || !strings.HasPrefix(s, `{"time":`) || !strings.Contains(s, `"msg":"shown"`) {
+		t.Errorf("Unexpected log:\n%s", s)
+	}
+
+	if _, err := newParser(&opts).ParseArgs([]string{"--log-level", "trace"}); err == nil {
+		t.Errorf("Expected an error for an unknown level")
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var opts Options
+
+	filename := filepath.Join(t.TempDir(), "test.log")
+
+	if _, err := newParser(&opts).ParseArgs([]string{"--log-file", filename, "--log-level", "debug"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	logger, closeLog, err := opts.NewLogger()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	logger.Debug("message", "key", "value")
+
+	if err := closeLog(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if s := string(data); !strings.Contains(s, "level=DEBUG msg=message key=value") {
+		t.Errorf("Unexpected log:\n%s", s)
+	}
+
+	opts.File = filepath.Join(filename, "missing", "test.log")
+
+	if _, _, err := opts.NewLogger(); err == nil {
+		t.Errorf("Expected an error for an invalid log file")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package flagstest provides helpers for testing command line interfaces
+// built using the flags package. The helpers parse arguments against an
+// options struct, check the resulting values and errors, and capture the
+// help message. Failures are reported using the testing.TB of the test.
+//
+// For example:
+//
+//	func TestOptions(t *testing.T) {
+//		var opts Options
+//
+//		flagstest.Parse(t, &opts, "-v", "--port", "80")
+//		flagstest.AssertFields(t, &opts, map[string]interface{}{
+//			"Verbose": true,
+//			"Port":    80,
+//		})
+//
+//		flagstest.ParseError(t, &opts, flags.ErrUnknownFlag, "--prot", "80")
+//	}
+package flagstest
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// NewParser creates a parser for the given options struct. The parser is
+// named "test" and has no parser options, such that it neither prints
+// errors nor depends on the name of the test binary.
+func NewParser(data interface{}) *flags.Parser {
+	return flags.NewNamedParser("test", flags.None, flags.NewGroup("Application Options", data))
+}
+
+// Parse parses the arguments against the options struct and returns the
+// remaining arguments. The test fails when parsing fails.
+func Parse(t testing.TB, data interface{}, args ...string) []string {
+	t.Helper()
+
+	return ParseWith(t, NewParser(data), args...)
+}
+
+// ParseWith parses the arguments using the given parser and returns the
+// remaining arguments. The test fails when parsing fails.
+func ParseWith(t testing.TB, p *flags.Parser, args ...string) []string {
+	t.Helper()
+
+	rest, err := p.ParseArgs(args)
+
+	if err != nil {
+		t.Fatalf("Unexpected error parsing %q: %s", args, err)
+		return nil
+	}
+
+	return rest
+}
+
+// ParseError parses the arguments against the options struct, expecting an
+// error of the given type, which is returned. The test fails when parsing
+// succeeds or fails with another type of error.
+func ParseError(t testing.TB, data interface{}, tp flags.ErrorType, args ...string) *flags.Error {
+	t.Helper()
+
+	_, err := NewParser(data).ParseArgs(args)
+	return AssertError(t, err, tp)
+}
+
+// AssertError checks that the error is, or wraps, a parser error of the
+// given type, which is returned. The test fails otherwise. For collected
+// errors (see flags.CollectErrors), the first error of the type is returned.
+func AssertError(t testing.TB, err error, tp flags.ErrorType) *flags.Error {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("Expected an error of type %s but got none", tp)
+		return nil
+	}
+
+	var errs flags.Errors
+
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			var ferr *flags.Error
+
+			if errors.As(e, &ferr) && ferr.Type == tp {
+				return ferr
+			}
+		}
+	}
+
+	var ferr *flags.Error
+
+	if !errors.As(err, &ferr) || ferr.Type != tp {
+		t.Fatalf("Expected an error of type %s but got %v", tp, err)
+		return nil
+	}
+
+	return ferr
+}
+
+// AssertFields checks the values of the fields of the options struct, given
+// by field name. Nested fields can be named using dots (e.g. Server.Port).
+// Values are compared using reflect.DeepEqual, after converting numbers and
+// values of the same kind to the type of the field, such that 80 matches a
+// field of type uint16.
+func AssertFields(t testing.TB, data interface{}, fields map[string]interface{}) {
+	t.Helper()
+
+	val := reflect.Indirect(reflect.ValueOf(data))
+
+	for name, expected := range fields {
+		field, ok := fieldByPath(val, name)
+
+		if !ok {
+			t.Errorf("Unknown field %s", name)
+			continue
+		}
+
+		if !field.CanInterface() {
+			t.Errorf("Field %s is not exported", name)
+			continue
+		}
+
+		actual := field.Interface()
+
+		if ev := reflect.ValueOf(expected); expected != nil && ev.Type() != field.Type() && convertible(ev.Type(), field.Type()) {
+			expected = ev.Convert(field.Type()).Interface()
+		}
+
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Expected %s to be %#v but got %#v", name, expected, actual)
+		}
+	}
+}
+
+// convertible returns whether an expected value of the given type is
+// converted to the type of the field before comparing it: values of the
+// same kind (e.g. a string for a named string type) and numbers.
+func convertible(from, to reflect.Type) bool {
+	if from.Kind() == to.Kind() {
+		return from.ConvertibleTo(to)
+	}
+
+	return isNumber(from.Kind()) && isNumber(to.Kind())
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// fieldByPath returns the field of the struct with the given dot separated
+// path, following pointers to nested structs.
+func fieldByPath(val reflect.Value, path string) (reflect.Value, bool) {
+	for _, name := range strings.Split(path, ".") {
+		for val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return reflect.Value{}, false
+			}
+
+			val = val.Elem()
+		}
+
+		if val.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+
+		if val = val.FieldByName(name); !val.IsValid() {
+			return reflect.Value{}, false
+		}
+	}
+
+	return val, true
+}
+
+// HelpWidth is the width at which Help wraps the help message of parsers
+// without a HelpWidth.
+const HelpWidth = 80
+
+// Help returns the help message of the parser. Unless the parser has a
+// HelpWidth, the message is wrapped at HelpWidth columns instead of the width
+// of the terminal, such that it does not depend on where the test runs.
+func Help(p *flags.Parser) string {
+	var b bytes.Buffer
+
+	if p.HelpWidth == 0 {
+		p.HelpWidth = HelpWidth
+
+		defer func() {
+			p.HelpWidth = 0
+		}()
+	}
+
+	p.WriteHelp(&b)
+	return b.String()
+}
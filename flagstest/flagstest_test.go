@@ -0,0 +1,117 @@
+package flagstest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// recorder records the failures reported by the helpers instead of failing
+// the test
+type recorder struct {
+	testing.TB
+
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+type options struct {
+	Verbose bool   `short:"v" long:"verbose" description:"Verbose output"`
+	Port    uint16 `short:"p" long:"port" description:"Port" default:"8080"`
+	Server  struct {
+		Host string `long:"host"`
+	} `group:"Server Options"`
+}
+
+func TestParse(t *testing.T) {
+	var opts options
+
+	rest := Parse(t, &opts, "-v", "--port", "80", "--host", "example.com", "file")
+
+	if len(rest) != 1 || rest[0] != "file" {
+		t.Errorf("Expected the remaining arguments but got %v", rest)
+	}
+
+	AssertFields(t, &opts, map[string]interface{}{
+		"Verbose":     true,
+		"Port":        80,
+		"Server.Host": "example.com",
+	})
+
+	r := &recorder{TB: t}
+
+	Parse(r, &opts, "--unknown")
+	AssertFields(r, &opts, map[string]interface{}{
+		"Verbose": false,
+		"Missing": 1,
+	})
+
+	expected := []string{
+		"Expected Verbose to be false but got true",
+		"Unexpected error parsing [\"--unknown\"]: unknown flag `unknown'",
+		"Unknown field Missing",
+	}
+
+	// The fields are checked in the random order of the map
+	sort.Strings(r.failures)
+
+	if strings.Join(r.failures, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected failures %q but got %q", expected, r.failures)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	var opts options
+
+	if err := ParseError(t, &opts, flags.ErrUnknownFlag, "--prot", "80"); err == nil || err.Type != flags.ErrUnknownFlag {
+		t.Errorf("Expected the error to be returned but got %v", err)
+	}
+
+	r := &recorder{TB: t}
+
+	ParseError(r, &opts, flags.ErrUnknownFlag, "--port", "80")
+	ParseError(r, &opts, flags.ErrUnknownFlag, "--port", "x")
+
+	expected := []string{
+		"Expected an error of type unknown flag but got none",
+		"Expected an error of type unknown flag but got invalid argument for flag `-p, --port' (expected uint16)",
+	}
+
+	if strings.Join(r.failures, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected failures %q but got %q", expected, r.failures)
+	}
+
+	// Collected errors are searched for the error type
+	p := NewParser(&opts)
+	p.Options |= flags.CollectErrors
+
+	_, err := p.ParseArgs([]string{"--port", "x", "--prot"})
+
+	if e := AssertError(t, err, flags.ErrUnknownFlag); e == nil {
+		t.Errorf("Expected the collected error to be found")
+	}
+}
+
+func TestHelp(t *testing.T) {
+	var opts options
+
+	help := Help(NewParser(&opts))
+
+	for _, s := range []string{"Usage:\n  test [OPTIONS]", "Verbose output", "Port (8080)", "Server Options:"} {
+		if !strings.Contains(help, s) {
+			t.Errorf("Expected %q in help message:\n%s", s, help)
+		}
+	}
+}
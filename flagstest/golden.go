@@ -0,0 +1,191 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flagstest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// UpdateGoldenEnv is the environment variable which, when set to a non-empty
+// value, makes AssertGolden write the actual output to the golden files
+// instead of comparing it. For example:
+//
+//	GO_FLAGS_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "GO_FLAGS_UPDATE_GOLDEN"
+
+// The number of unchanged lines shown around the changes in a diff
+const diffContext = 3
+
+// AssertHelp compares the help message of the parser, as returned by Help,
+// against the golden file with the given name.
+func AssertHelp(t testing.TB, p *flags.Parser, filename string) {
+	t.Helper()
+
+	AssertGolden(t, filename, Help(p))
+}
+
+// AssertGolden compares the actual output against the contents of the golden
+// file with the given name, which is usually located in the testdata
+// directory of the package. The test fails with a line based diff of the
+// expected and actual output when they differ. When the environment variable
+// named by UpdateGoldenEnv is set, the golden file is written instead,
+// creating its directory if needed.
+func AssertGolden(t testing.TB, filename string, actual string) {
+	t.Helper()
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatalf("Failed to create the directory of golden file %s: %s", filename, err)
+			return
+		}
+
+		if err := ioutil.WriteFile(filename, []byte(actual), 0644); err != nil {
+			t.Fatalf("Failed to update golden file %s: %s", filename, err)
+		}
+
+		return
+	}
+
+	expected, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		t.Fatalf("Failed to read golden file %s (set %s=1 to create it): %s", filename, UpdateGoldenEnv, err)
+		return
+	}
+
+	if string(expected) != actual {
+		t.Errorf("Output differs from golden file %s (set %s=1 to update it):\n%s",
+			filename, UpdateGoldenEnv, Diff(string(expected), actual))
+	}
+}
+
+// Diff returns a line based diff of the expected and actual text. Removed
+// lines are prefixed by "-", added lines by "+" and unchanged lines by a
+// space. Runs of unchanged lines away from the changes are elided. Trailing
+// whitespace is made visible, since it is otherwise easily missed.
+func Diff(expected, actual string) string {
+	a := splitLines(expected)
+	b := splitLines(actual)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+
+	var lines []line
+
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	// Only show unchanged lines close to a change
+	show := make([]bool, len(lines))
+
+	for n, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+
+		for k := n - diffContext; k <= n+diffContext; k++ {
+			if k >= 0 && k < len(lines) {
+				show[k] = true
+			}
+		}
+	}
+
+	var ret strings.Builder
+	elided := false
+
+	for n, l := range lines {
+		if !show[n] {
+			if !elided {
+				ret.WriteString("  ...\n")
+				elided = true
+			}
+
+			continue
+		}
+
+		elided = false
+		fmt.Fprintf(&ret, "%c %s\n", l.op, visibleSpace(l.text))
+	}
+
+	return ret.String()
+}
+
+// splitLines splits text into lines. A missing newline at the end of the
+// text is shown as a separate marker, such that it appears in the diff.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i, l := range lines {
+		if strings.HasSuffix(l, "\n") {
+			lines[i] = l[:len(l)-1]
+		} else {
+			lines[i] = l + "\\ (no newline at end)"
+		}
+	}
+
+	return lines
+}
+
+// visibleSpace marks trailing whitespace of a line.
+func visibleSpace(s string) string {
+	trimmed := strings.TrimRight(s, " \t\r")
+
+	if len(trimmed) == len(s) {
+		return s
+	}
+
+	return trimmed + strings.NewReplacer(" ", "·", "\t", "→", "\r", "\\r").Replace(s[len(trimmed):])
+}
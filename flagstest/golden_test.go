@@ -0,0 +1,61 @@
+package flagstest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssertGolden(t *testing.T) {
+	var opts options
+
+	filename := filepath.Join(t.TempDir(), "testdata", "help.golden")
+
+	t.Setenv(UpdateGoldenEnv, "1")
+	AssertHelp(t, NewParser(&opts), filename)
+
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		t.Fatalf("Expected the golden file to be written: %s", err)
+	}
+
+	if string(data) != Help(NewParser(&opts)) {
+		t.Errorf("Expected the help message in the golden file but got:\n%s", data)
+	}
+
+	os.Unsetenv(UpdateGoldenEnv)
+	AssertHelp(t, NewParser(&opts), filename)
+
+	r := &recorder{TB: t}
+	AssertGolden(r, filename, strings.Replace(string(data), "Verbose output", "Verbose", 1))
+
+	if len(r.failures) != 1 || !strings.Contains(r.failures[0], "- ") || !strings.Contains(r.failures[0], "Verbose output") {
+		t.Errorf("Expected a diff of the help message but got %q", r.failures)
+	}
+
+	r = &recorder{TB: t}
+	AssertGolden(r, filepath.Join(t.TempDir(), "missing.golden"), "")
+
+	if len(r.failures) != 1 || !strings.Contains(r.failures[0], UpdateGoldenEnv) {
+		t.Errorf("Expected a missing golden file to be reported but got %q", r.failures)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	expected := "a\nb\nc\nd\ne\nf\ng\nh\ni\n"
+	actual := "a\nb\nc\nd\ne\nF\ng\nh\ni \n"
+
+	diff := Diff(expected, actual)
+	want := "  ...\n  c\n  d\n  e\n- f\n+ F\n  g\n  h\n- i\n+ i·\n"
+
+	if diff != want {
+		t.Errorf("Expected diff:\n%s\nbut got:\n%s", want, diff)
+	}
+
+	if diff := Diff("a\n", "a"); diff != "- a\n+ a\\ (no newline at end)\n" {
+		t.Errorf("Expected a missing newline to be shown but got %q", diff)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package flagstls provides a ready-made group of options configuring TLS:
+// the certificate and key of the client or server, the certificate
+// authorities which are trusted, and whether certificates are verified.
+// The options are added to a parser as a group of their own, and the TLS
+// configuration is created after parsing. For example:
+//
+//	var tlsOpts flagstls.Options
+//
+//	parser.AddGroup("TLS Options", "", &tlsOpts)
+//
+//	if _, err := parser.Parse(); err != nil {
+//		os.Exit(1)
+//	}
+//
+//	config, err := tlsOpts.Config()
+package flagstls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Options are the TLS options. The certificate and key must be specified
+// together, and the files must exist, which is checked when parsing. Since
+// the pairing of the certificate and key refers to their long names, the
+// options cannot be nested in a group with a namespace.
+type Options struct {
+	Cert               string `long:"cert" description:"Certificate file in PEM format" value-name:"FILE" requires:"key"`
+	Key                string `long:"key" description:"Private key file of the certificate in PEM format" value-name:"FILE" requires:"cert"`
+	CA                 string `long:"ca" description:"File of trusted certificate authorities in PEM format, instead of those of the system" value-name:"FILE"`
+	InsecureSkipVerify bool   `long:"insecure-skip-verify" description:"Do not verify the certificates of peers"`
+}
+
+// Validate checks that the certificate, key and certificate authority files
+// exist once the options have been parsed.
+func (o *Options) Validate() error {
+	files := []struct {
+		name string
+		kind string
+	}{
+		{o.Cert, "certificate"},
+		{o.Key, "key"},
+		{o.CA, "certificate authority"},
+	}
+
+	for _, file := range files {
+		if file.name == "" {
+			continue
+		}
+
+		if _, err := os.Stat(file.name); err != nil {
+			return fmt.Errorf("%s file `%s' cannot be read: %s", file.kind, file.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Config returns a TLS configuration using the certificate and key, and
+// trusting the certificate authorities, given by the options. An error is
+// returned when the files cannot be loaded.
+func (o *Options) Config() (*tls.Config, error) {
+	ret := &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.Cert != "" || o.Key != "" {
+		cert, err := tls.LoadX509KeyPair(o.Cert, o.Key)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate `%s': %s", o.Cert, err)
+		}
+
+		ret.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.CA != "" {
+		data, err := ioutil.ReadFile(o.CA)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in certificate authority file `%s'", o.CA)
+		}
+
+		// The pool is used both to verify servers and clients
+		ret.RootCAs = pool
+		ret.ClientCAs = pool
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,128 @@
+package flagstls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// writeCert writes a self-signed certificate and its key to the directory
+func writeCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return certFile, keyFile
+}
+
+func parse(opts *Options, args ...string) error {
+	p := flags.NewNamedParser("test", flags.None)
+	p.AddGroup("TLS Options", "", opts)
+
+	_, err := p.ParseArgs(args)
+	return err
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+
+	var opts Options
+
+	if err := parse(&opts, "--cert", certFile, "--key", keyFile, "--ca", certFile, "--insecure-skip-verify"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	config, err := opts.Config()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(config.Certificates) != 1 || config.RootCAs == nil || config.ClientCAs == nil || !config.InsecureSkipVerify {
+		t.Errorf("Unexpected configuration: %+v", config)
+	}
+
+	opts = Options{CA: keyFile}
+
+	if _, err := opts.Config(); err == nil || !strings.Contains(err.Error(), "no certificates found") {
+		t.Errorf("Expected an error for a file without certificates but got %v", err)
+	}
+
+	opts = Options{Cert: keyFile, Key: certFile}
+
+	if _, err := opts.Config(); err == nil || !strings.Contains(err.Error(), "failed to load certificate") {
+		t.Errorf("Expected an error for a mismatched certificate but got %v", err)
+	}
+
+	opts = Options{}
+
+	if config, err := opts.Config(); err != nil || len(config.Certificates) != 0 || config.RootCAs != nil {
+		t.Errorf("Expected an empty configuration but got %+v, %v", config, err)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+
+	tests := []struct {
+		args    []string
+		tp      flags.ErrorType
+		message string
+	}{
+		{[]string{"--cert", certFile}, flags.ErrRequired, "flag `--cert' requires flag `--key'"},
+		{[]string{"--key", keyFile}, flags.ErrRequired, "flag `--key' requires flag `--cert'"},
+		{[]string{"--ca", filepath.Join(dir, "missing.pem")}, flags.ErrValidation, "certificate authority file `" + filepath.Join(dir, "missing.pem") + "' cannot be read"},
+	}
+
+	for _, test := range tests {
+		var opts Options
+
+		err := parse(&opts, test.args...)
+
+		if e, ok := err.(*flags.Error); !ok || e.Type != test.tp || !strings.HasPrefix(e.Message, test.message) {
+			t.Errorf("Expected error %q for %v but got %v", test.message, test.args, err)
+		}
+	}
+}
@@ -0,0 +1,690 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Flagsvet statically checks the field tags of option structs of the flags
+// package, such that invalid tags are reported when the code is vetted
+// instead of by flags.NewGroup when the program runs. It reports:
+//
+//	short names and short aliases longer than one character
+//	long and short names used by more than one option of a group
+//	default values which cannot be converted to the type of the field
+//	fields of types which cannot hold option values
+//	other tags rejected by flags.NewGroup (e.g. invalid patterns)
+//
+// Flagsvet can be run on package directories (a trailing /... includes
+// all the packages below the directory):
+//
+//	flagsvet ./...
+//
+// or as a tool of go vet:
+//
+//	go vet -vettool=$(which flagsvet) ./...
+//
+// Each option is checked by creating it using flags.NewGroup, such that
+// the same rules apply as at runtime. Fields whose types involve named
+// types, other than time.Duration, are only checked for their names, since
+// converters may be registered for such types when the program runs (see
+// flags.RegisterConverter). Tags are expected to use the default tag names
+// (see flags.NewGroupWithTags).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// A diagnostic is a problem found in a field tag.
+type diagnostic struct {
+	pos     token.Pos
+	message string
+}
+
+func main() {
+	// The handshake of go vet with its tools
+	if len(os.Args) == 2 {
+		switch os.Args[1] {
+		case "-V=full":
+			printVersion()
+			return
+		case "-flags":
+			fmt.Println("[]")
+			return
+		}
+	}
+
+	jsonOutput := flag.Bool("json", false, "print the diagnostics as JSON (used by go vet)")
+	flag.Parse()
+
+	var found bool
+	var err error
+
+	if flag.NArg() == 1 && strings.HasSuffix(flag.Arg(0), ".cfg") {
+		found, err = vetConfig(flag.Arg(0), *jsonOutput)
+	} else {
+		found, err = vetDirs(flag.Args())
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "flagsvet: %s\n", err)
+		os.Exit(1)
+	}
+
+	if found {
+		os.Exit(1)
+	}
+}
+
+// printVersion prints the version of the tool, which go vet uses to cache
+// results. The executable is hashed, such that rebuilt tools invalidate
+// the cache.
+func printVersion() {
+	id := "unknown"
+
+	if exe, err := os.Executable(); err == nil {
+		if data, err := os.ReadFile(exe); err == nil {
+			id = fmt.Sprintf("%x", hash(data))
+		}
+	}
+
+	fmt.Printf("flagsvet version devel buildID=%s\n", id)
+}
+
+// hash computes the 64-bit FNV-1a hash of data.
+func hash(data []byte) uint64 {
+	h := uint64(14695981039346656037)
+
+	for _, b := range data {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+
+	return h
+}
+
+// vetConfig checks a single package, described by the configuration file
+// which go vet passes to its tools. With jsonOutput, the diagnostics are
+// written as JSON to the file named by the configuration, from which go vet
+// reports them.
+func vetConfig(filename string, jsonOutput bool) (bool, error) {
+	data, err := os.ReadFile(filename)
+
+	if err != nil {
+		return false, err
+	}
+
+	var cfg struct {
+		ID          string
+		Compiler    string
+		GoFiles     []string
+		ImportMap   map[string]string
+		PackageFile map[string]string
+		VetxOnly    bool
+		VetxOutput  string
+		Stdout      string
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return false, fmt.Errorf("invalid configuration file %s: %s", filename, err)
+	}
+
+	// Flagsvet does not produce facts, but go vet expects the file
+	if cfg.VetxOutput != "" {
+		if err := os.WriteFile(cfg.VetxOutput, nil, 0644); err != nil {
+			return false, err
+		}
+	}
+
+	if cfg.VetxOnly {
+		return false, nil
+	}
+
+	fset := token.NewFileSet()
+	files, err := parseFiles(fset, cfg.GoFiles)
+
+	if err != nil {
+		return false, err
+	}
+
+	compiler := importer.ForCompiler(fset, cfg.Compiler, func(path string) (io.ReadCloser, error) {
+		file, ok := cfg.PackageFile[path]
+
+		if !ok {
+			return nil, fmt.Errorf("no package file for %s", path)
+		}
+
+		return os.Open(file)
+	})
+
+	imp := importerFunc(func(path string) (*types.Package, error) {
+		if mapped, ok := cfg.ImportMap[path]; ok {
+			path = mapped
+		}
+
+		return compiler.Import(path)
+	})
+
+	diagnostics := check(fset, files, imp)
+
+	if !jsonOutput {
+		return report(fset, diagnostics), nil
+	}
+
+	return false, reportJSON(fset, cfg.ID, cfg.Stdout, diagnostics)
+}
+
+// reportJSON writes the diagnostics of a package in the JSON format of go
+// vet tools to the named file, or to the standard output.
+func reportJSON(fset *token.FileSet, id string, filename string, diagnostics []diagnostic) error {
+	type jsonDiagnostic struct {
+		Posn    string `json:"posn"`
+		Message string `json:"message"`
+	}
+
+	if len(diagnostics) == 0 {
+		return nil
+	}
+
+	list := make([]jsonDiagnostic, len(diagnostics))
+
+	for i, d := range diagnostics {
+		list[i] = jsonDiagnostic{fset.Position(d.pos).String(), d.message}
+	}
+
+	data, err := json.Marshal(map[string]map[string][]jsonDiagnostic{
+		id: {"flagsvet": list},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if filename == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0644)
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// vetDirs checks the packages in the given directories, including their
+// tests. The packages imported by the checked packages are type checked
+// from source.
+func vetDirs(args []string) (bool, error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	var dirs []string
+
+	for _, arg := range args {
+		if !strings.HasSuffix(arg, "/...") {
+			dirs = append(dirs, arg)
+			continue
+		}
+
+		root := strings.TrimSuffix(arg, "/...")
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return err
+			}
+
+			name := info.Name()
+
+			if path != root && (name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+
+			dirs = append(dirs, path)
+			return nil
+		})
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	found := false
+
+	for _, dir := range dirs {
+		fset := token.NewFileSet()
+
+		pkgs, err := parser.ParseDir(fset, dir, nil, parser.ParseComments)
+
+		if err != nil {
+			return found, err
+		}
+
+		imp := importer.ForCompiler(fset, "source", nil)
+
+		// Check the packages in a stable order, including external test
+		// packages
+		var names []string
+
+		for name := range pkgs {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			var files []*ast.File
+
+			for _, file := range pkgs[name].Files {
+				files = append(files, file)
+			}
+
+			if report(fset, check(fset, files, imp)) {
+				found = true
+			}
+		}
+	}
+
+	return found, nil
+}
+
+func parseFiles(fset *token.FileSet, filenames []string) ([]*ast.File, error) {
+	var files []*ast.File
+
+	for _, filename := range filenames {
+		file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// report prints the diagnostics and returns whether there were any.
+func report(fset *token.FileSet, diagnostics []diagnostic) bool {
+	for _, d := range diagnostics {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", fset.Position(d.pos), d.message)
+	}
+
+	return len(diagnostics) != 0
+}
+
+// check returns the problems found in the tags of the option structs
+// declared in the files, which belong to a single package. Type errors are
+// ignored, the types which could not be determined are simply not checked.
+func check(fset *token.FileSet, files []*ast.File, imp types.Importer) []diagnostic {
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+	}
+
+	conf := types.Config{
+		Importer: imp,
+		Error:    func(err error) {},
+	}
+
+	conf.Check(files[0].Name.Name, fset, files, info)
+
+	c := &checker{
+		reported: make(map[diagnostic]bool),
+	}
+
+	for _, file := range files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			if st, ok := node.(*ast.StructType); ok {
+				if tv, ok := info.Types[st]; ok {
+					if s, ok := tv.Type.(*types.Struct); ok {
+						c.checkGroup(s)
+					}
+				}
+			}
+
+			return true
+		})
+	}
+
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		return c.diagnostics[i].pos < c.diagnostics[j].pos
+	})
+
+	return c.diagnostics
+}
+
+type checker struct {
+	diagnostics []diagnostic
+
+	// Diagnostics are reported once, even though the fields of embedded
+	// structs are checked for each embedding struct
+	reported map[diagnostic]bool
+}
+
+func (c *checker) report(pos token.Pos, format string, args ...interface{}) {
+	d := diagnostic{pos, fmt.Sprintf(format, args...)}
+
+	if !c.reported[d] {
+		c.reported[d] = true
+		c.diagnostics = append(c.diagnostics, d)
+	}
+}
+
+// A namedOption is an option created from a field, used to find options
+// with the same names.
+type namedOption struct {
+	field  *types.Var
+	option *flags.Option
+}
+
+// checkGroup checks the options of the group defined by a struct,
+// including the options of embedded structs.
+func (c *checker) checkGroup(st *types.Struct) {
+	var options []namedOption
+
+	c.checkFields(st, &options, make(map[*types.Struct]bool))
+
+	shortNames := make(map[rune]namedOption)
+	longNames := make(map[string]namedOption)
+
+	for _, o := range options {
+		shorts := o.option.ShortAliases
+
+		if o.option.ShortName != 0 {
+			shorts = append([]rune{o.option.ShortName}, shorts...)
+		}
+
+		for _, name := range shorts {
+			if other, ok := shortNames[name]; ok {
+				c.conflict(o, other)
+			} else {
+				shortNames[name] = o
+			}
+		}
+
+		longs := o.option.LongAliases
+
+		if o.option.LongName != "" {
+			longs = append([]string{o.option.LongName}, longs...)
+		}
+
+		for _, name := range longs {
+			if other, ok := longNames[name]; ok {
+				c.conflict(o, other)
+			} else {
+				longNames[name] = o
+			}
+		}
+	}
+}
+
+func (c *checker) conflict(o, other namedOption) {
+	c.report(o.field.Pos(), "option `%s' of field `%s' conflicts with option `%s' of field `%s'",
+		o.option, o.field.Name(), other.option, other.field.Name())
+}
+
+// checkFields checks the fields of a struct, adding the options to
+// options. Embedded structs are checked as part of the struct, except when
+// they are recursive.
+func (c *checker) checkFields(st *types.Struct, options *[]namedOption, seen map[*types.Struct]bool) {
+	if seen[st] {
+		return
+	}
+
+	seen[st] = true
+
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i))
+
+		if tag.Get("no-flag") != "" || tag.Get("group") != "" {
+			continue
+		}
+
+		if field.Embedded() {
+			tp := field.Type()
+
+			if ptr, ok := tp.Underlying().(*types.Pointer); ok {
+				tp = ptr.Elem()
+			}
+
+			if embedded, ok := tp.Underlying().(*types.Struct); ok {
+				c.checkFields(embedded, options, seen)
+			}
+
+			continue
+		}
+
+		if tag.Get("short") == "" && tag.Get("long") == "" && tag.Get("flags") == "" {
+			continue
+		}
+
+		if !field.Exported() {
+			c.report(field.Pos(), "field `%s' is tagged as an option but is not exported", field.Name())
+			continue
+		}
+
+		if option := c.checkOption(field, tag); option != nil {
+			*options = append(*options, namedOption{field, option})
+		}
+	}
+}
+
+// checkOption creates the option of a field using flags.NewGroup, reporting
+// the error if the option cannot be created.
+func (c *checker) checkOption(field *types.Var, tag reflect.StructTag) *flags.Option {
+	// The method named by default-func belongs to the struct of the
+	// group, which is not known for embedded structs
+	tag = withoutTag(tag, "default-func")
+
+	tp, ok := reflectType(field.Type())
+
+	if !ok {
+		// Options without an argument do not have their default values
+		// converted, such that only the names are checked
+		tp = reflect.TypeOf(func() {})
+	}
+
+	st := reflect.StructOf([]reflect.StructField{
+		{Name: field.Name(), Type: tp, Tag: tag},
+	})
+
+	grp := flags.NewGroup("", reflect.New(st).Interface())
+
+	if grp.Error != nil {
+		msg := grp.Error.Error()
+
+		if !strings.Contains(msg, "`"+field.Name()+"'") {
+			msg = fmt.Sprintf("field `%s': %s", field.Name(), msg)
+		}
+
+		c.report(field.Pos(), "%s", msg)
+		return nil
+	}
+
+	if len(grp.Options) != 1 {
+		return nil
+	}
+
+	return grp.Options[0]
+}
+
+// withoutTag removes a key from a struct tag.
+func withoutTag(tag reflect.StructTag, key string) reflect.StructTag {
+	if _, ok := tag.Lookup(key); !ok {
+		return tag
+	}
+
+	var parts []string
+
+	s := string(tag)
+
+	for s != "" {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		i := strings.Index(s, ":\"")
+
+		if i <= 0 {
+			break
+		}
+
+		j := i + 2
+
+		for j < len(s) && s[j] != '"' {
+			if s[j] == '\\' {
+				j++
+			}
+
+			j++
+		}
+
+		if j >= len(s) {
+			break
+		}
+
+		if s[:i] != key {
+			parts = append(parts, s[:j+1])
+		}
+
+		s = s[j+1:]
+	}
+
+	return reflect.StructTag(strings.Join(parts, " "))
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// basicTypes are the reflect types of the basic types.
+var basicTypes = map[types.BasicKind]reflect.Type{
+	types.Bool:       reflect.TypeOf(false),
+	types.Int:        reflect.TypeOf(int(0)),
+	types.Int8:       reflect.TypeOf(int8(0)),
+	types.Int16:      reflect.TypeOf(int16(0)),
+	types.Int32:      reflect.TypeOf(int32(0)),
+	types.Int64:      reflect.TypeOf(int64(0)),
+	types.Uint:       reflect.TypeOf(uint(0)),
+	types.Uint8:      reflect.TypeOf(uint8(0)),
+	types.Uint16:     reflect.TypeOf(uint16(0)),
+	types.Uint32:     reflect.TypeOf(uint32(0)),
+	types.Uint64:     reflect.TypeOf(uint64(0)),
+	types.Uintptr:    reflect.TypeOf(uintptr(0)),
+	types.Float32:    reflect.TypeOf(float32(0)),
+	types.Float64:    reflect.TypeOf(float64(0)),
+	types.Complex64:  reflect.TypeOf(complex64(0)),
+	types.Complex128: reflect.TypeOf(complex128(0)),
+	types.String:     reflect.TypeOf(""),
+}
+
+// reflectType returns the reflect type equivalent to a type, such that
+// options can be created for it using flags.NewGroup. It returns false for
+// types involving named types other than time.Duration and error, for which
+// there may be registered converters, and for types which have no
+// equivalent.
+func reflectType(tp types.Type) (reflect.Type, bool) {
+	switch t := tp.(type) {
+	case *types.Basic:
+		ret, ok := basicTypes[t.Kind()]
+		return ret, ok
+	case *types.Named:
+		obj := t.Obj()
+
+		if obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Duration" {
+			return durationType, true
+		}
+
+		if obj.Pkg() == nil && obj.Name() == "error" {
+			return errorType, true
+		}
+	case *types.Slice:
+		if elem, ok := reflectType(t.Elem()); ok {
+			return reflect.SliceOf(elem), true
+		}
+	case *types.Array:
+		if elem, ok := reflectType(t.Elem()); ok {
+			return reflect.ArrayOf(int(t.Len()), elem), true
+		}
+	case *types.Map:
+		key, kok := reflectType(t.Key())
+		elem, eok := reflectType(t.Elem())
+
+		if kok && eok && key.Comparable() {
+			return reflect.MapOf(key, elem), true
+		}
+	case *types.Pointer:
+		if elem, ok := reflectType(t.Elem()); ok {
+			return reflect.PointerTo(elem), true
+		}
+	case *types.Chan:
+		if elem, ok := reflectType(t.Elem()); ok {
+			dir := reflect.BothDir
+
+			switch t.Dir() {
+			case types.SendOnly:
+				dir = reflect.SendDir
+			case types.RecvOnly:
+				dir = reflect.RecvDir
+			}
+
+			return reflect.ChanOf(dir, elem), true
+		}
+	case *types.Signature:
+		return signatureType(t)
+	case *types.Interface:
+		if t.Empty() {
+			return reflect.TypeOf((*interface{})(nil)).Elem(), true
+		}
+	}
+
+	return nil, false
+}
+
+func signatureType(sig *types.Signature) (reflect.Type, bool) {
+	tuple := func(t *types.Tuple) ([]reflect.Type, bool) {
+		var ret []reflect.Type
+
+		for i := 0; i < t.Len(); i++ {
+			tp, ok := reflectType(t.At(i).Type())
+
+			if !ok {
+				return nil, false
+			}
+
+			ret = append(ret, tp)
+		}
+
+		return ret, true
+	}
+
+	if sig.Recv() != nil || sig.TypeParams() != nil {
+		return nil, false
+	}
+
+	in, iok := tuple(sig.Params())
+	out, ook := tuple(sig.Results())
+
+	if !iok || !ook {
+		return nil, false
+	}
+
+	return reflect.FuncOf(in, out, sig.Variadic()), true
+}
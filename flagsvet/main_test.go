@@ -0,0 +1,105 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func checkSource(t *testing.T, src string) []string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "options.go", src, 0)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var ret []string
+
+	for _, d := range check(fset, []*ast.File{file}, importer.ForCompiler(fset, "source", nil)) {
+		ret = append(ret, fset.Position(d.pos).String()+": "+d.message)
+	}
+
+	return ret
+}
+
+func TestCheck(t *testing.T) {
+	src := `package main
+
+import (
+	"net/url"
+	"time"
+)
+
+type Base struct {
+	Verbose bool ` + "`short:\"v\" long:\"verbose\"`" + `
+}
+
+type Options struct {
+	Base
+
+	Quiet   bool          ` + "`short:\"qu\" long:\"quiet\"`" + `
+	Port    int           ` + "`long:\"port\" default:\"http\"`" + `
+	Timeout time.Duration ` + "`long:\"timeout\" default:\"1m\"`" + `
+	Loud    bool          ` + "`long:\"verbose\"`" + `
+	Events  chan int      ` + "`long:\"events\"`" + `
+	URL     *url.URL      ` + "`long:\"url\" default:\"http://example.com\"`" + `
+	Level   uint8         ` + "`flags:\"-l, --level, default=300\"`" + `
+	Name    string        ` + "`long:\"name\" default-func:\"DefaultName\"`" + `
+	hidden  int           ` + "`long:\"hidden\"`" + `
+	Ignored chan int
+
+	Server struct {
+		Host string ` + "`short:\"h\" long:\"host\"`" + `
+		Port int    ` + "`short-alias:\"h\" long:\"port\"`" + `
+	} ` + "`group:\"Server Options\"`" + `
+}
+`
+
+	expected := []string{
+		"options.go:15:2: field `Quiet': short names can only be 1 character",
+		"options.go:16:2: invalid default value for field `Port': invalid argument for flag `--port' (expected int)",
+		"options.go:18:2: option `--verbose' of field `Loud' conflicts with option `-v, --verbose' of field `Verbose'",
+		"options.go:19:2: field `Events' has unsupported type chan int",
+		"options.go:21:2: invalid default value for field `Level': invalid argument for flag `-l, --level' (expected uint8)",
+		"options.go:23:2: field `hidden' is tagged as an option but is not exported",
+		"options.go:28:3: option `--port' of field `Port' conflicts with option `-h, --host' of field `Host'",
+	}
+
+	if ret := checkSource(t, src); !reflect.DeepEqual(ret, expected) {
+		t.Errorf("Expected diagnostics:\n%q\nbut got:\n%q", expected, ret)
+	}
+}
+
+func TestCheckUnknownTypes(t *testing.T) {
+	// Types which cannot be determined are only checked for their names
+	src := `package main
+
+import "example.com/missing"
+
+type Options struct {
+	Value missing.Value ` + "`short:\"v\" long:\"value\" default:\"x\"`" + `
+	Other missing.Value ` + "`long:\"value\"`" + `
+}
+`
+
+	expected := []string{
+		"options.go:7:2: option `--value' of field `Other' conflicts with option `-v, --value' of field `Value'",
+	}
+
+	if ret := checkSource(t, src); !reflect.DeepEqual(ret, expected) {
+		t.Errorf("Expected diagnostics:\n%q\nbut got:\n%q", expected, ret)
+	}
+}
+
+func TestWithoutTag(t *testing.T) {
+	tag := reflect.StructTag(`long:"name" default-func:"Name" description:"a \"quoted\" name"`)
+	expected := reflect.StructTag(`long:"name" description:"a \"quoted\" name"`)
+
+	if ret := withoutTag(tag, "default-func"); ret != expected {
+		t.Errorf("Expected %s but got %s", expected, ret)
+	}
+}
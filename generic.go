@@ -0,0 +1,30 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+// ParseArgs is a convenience function to parse the given command line
+// arguments with default settings into a new value of the struct type T,
+// which represents the default option group (see Parse). The parsed options
+// and the remaining arguments are returned. For example:
+//
+//	opts, args, err := flags.ParseArgs[Options](os.Args[1:])
+func ParseArgs[T any](args []string) (*T, []string, error) {
+	p, data := NewParserFor[T](Default)
+	ret, err := p.ParseArgs(args)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, ret, nil
+}
+
+// NewParserFor creates a new parser like NewParser, whose default option
+// group is defined by a new value of the struct type T. The parser and the
+// value, in which the options are stored when parsing, are returned.
+func NewParserFor[T any](options Options) (*Parser, *T) {
+	data := new(T)
+	return NewParser(data, options), data
+}
@@ -0,0 +1,41 @@
+package flags
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseArgsGeneric(t *testing.T) {
+	type options struct {
+		Verbose []bool `short:"v"`
+		Port    int    `long:"port" default:"8080"`
+	}
+
+	opts, args, err := ParseArgs[options]([]string{"-vv", "arg"})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(opts.Verbose) != 2 || opts.Port != 8080 {
+		t.Errorf("Unexpected options %+v", opts)
+	}
+
+	if len(args) != 1 || args[0] != "arg" {
+		t.Errorf("Expected remaining arguments [arg] but got %v", args)
+	}
+
+	p, data := NewParserFor[options](None)
+
+	if _, err := p.ParseArgs([]string{"--port", "x"}); err == nil || !strings.Contains(err.Error(), "--port") {
+		t.Errorf("Expected an error for --port but got %v", err)
+	}
+
+	if _, err := p.ParseArgs([]string{"--port", "1"}); err != nil || data.Port != 1 {
+		t.Errorf("Expected port 1 but got %d (%v)", data.Port, err)
+	}
+
+	if _, _, err := ParseArgs[int](nil); err != ErrNotPointerToStruct {
+		t.Errorf("Expected ErrNotPointerToStruct but got %v", err)
+	}
+}
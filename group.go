@@ -8,7 +8,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"unicode/utf8"
+	"unique"
 )
 
 // The provided container is not a pointer to a struct
@@ -26,30 +30,164 @@ type Option struct {
 	// or LongName needs to be non-empty.
 	ShortName rune
 
+	// Additional short names of the option, given by short-alias tags
+	// (e.g. -? in addition to -h). Like long aliases, short aliases are
+	// only shown in the builtin help when the ShowAliases parser option is
+	// set.
+	ShortAliases []rune
+
 	// The long name of the option. If not "", the option flag can be
 	// activated using --<LongName>. Either ShortName or LongName needs
 	// to be non-empty.
 	LongName string
 
+	// Additional long names of the option, given by long-alias tags. The
+	// option can be activated using any of its aliases, which is useful to
+	// rename an option without breaking existing uses of the old name.
+	// Aliases are only shown in the builtin help when the ShowAliases
+	// parser option is set.
+	LongAliases []string
+
 	// The description of the option flag. This description is shown
-	// automatically in the builtin help.
+	// automatically in the builtin help. Descriptions are templates which
+	// can refer to the default value, environment variable, flag and
+	// choices of the option as {{.Default}}, {{.Env}}, {{.Name}} and
+	// {{.Choices}}. The default value and environment variable are not
+	// repeated after descriptions which refer to them. Descriptions which
+	// are not valid templates (e.g. containing JSON examples) are shown as
+	// they are.
 	Description string
 
-	// The default value of the option. The default value is used when
-	// the option flag is marked as having an OptionalArgument. This means
-	// that when the flag is specified, but no option argument is given,
-	// the value of the field this option represents will be set to
-	// Default. This is only valid for non-boolean options.
+	// A longer description of the option, which is shown below the
+	// description in the builtin help. Paragraphs are separated by empty
+	// lines. The long description can be given by a method of the data
+	// struct of the group (see LongDescriber).
+	LongDescription string
+
+	// The default value of the option. For options with an
+	// OptionalArgument, the default value is used when the flag is
+	// specified without an argument. This is only valid for non-boolean
+	// options. Otherwise, the default value given by the default tag is the
+	// value to which the field this option represents is initialized when
+	// the group is created (see also Parser.ApplyDefaults). Values which are
+	// specified for slice and map options replace their default values,
+	// unless they are given as --name+=value to add values, or
+	// --name-=value to remove values (or keys of maps), which also edits
+	// the values of configuration files.
 	Default string
 
+	// If not nil, DefaultFunc is called after parsing when the option was
+	// not set by any source, and the option is set to the returned value.
+	// This allows defaults which are expensive to compute or depend on the
+	// environment (e.g. the home directory of the user) to be evaluated
+	// only when needed. DefaultFunc can also be given by the default-func
+	// tag naming a method of the data struct of the group.
+	DefaultFunc func() (string, error)
+
+	// If not empty, DefaultMask is shown as the default value in the help
+	// message instead of the value of the option, which is useful for
+	// defaults which are long, machine specific or sensitive (e.g.
+	// "$EDITOR or vi"). The default value is not shown at all when
+	// DefaultMask is "-".
+	DefaultMask string
+
+	// The name of the environment variable from which the value of the
+	// option is read when the environment is used as a source of option
+	// values (see Parser.ParseSources).
+	EnvName string
+
+	// If not empty, the value of the environment variable is split on
+	// EnvDelim and the option is set once for each part. This is useful
+	// for options which can be specified more than once (e.g. slices).
+	EnvDelim string
+
+	// The name of the argument value of the option (e.g. FILE). The value
+	// name is used as a placeholder for the argument in generated shell
+	// completions.
+	ValueName string
+
+	// The list of valid arguments of the option. If not empty, the argument
+	// of the option must be one of the choices.
+	Choices []string
+
+	// If not nil, Validator is called with the argument of the option (or
+	// "" for options without an argument) each time before the option is
+	// set. A returned error is reported as an error of the option.
+	Validator func(value string) error
+
+	// If not nil, Setter is called with the argument of the option (or ""
+	// for options without an argument) to set the variable of the option,
+	// instead of converting the argument using reflection. Setter is set
+	// by the code generated by flagsgen. It is not called by clones of the
+	// parser, which have variables of their own (see Parser.Clone).
+	Setter func(value string) error
+
 	// If true, specifies that the argument to an option flag is optional.
 	// When no argument to the flag is specified on the command line, the
 	// value of Default will be set in the field this option represents.
 	// This is only valid for non-boolean options.
 	OptionalArgument bool
 
-	value   reflect.Value
-	options reflect.StructTag
+	// If true, the option is not included in shell completions. The option
+	// is still shown in the builtin help.
+	NoCompletion bool
+
+	// If true, the argument of the option is the name of a configuration
+	// file, which is loaded as soon as the option is encountered on the
+	// command line. The values of the file do not override options set on
+	// the command line.
+	ConfigFile bool
+
+	// If true, the option must be set by one of the sources (see
+	// PromptRequired). Required options are listed in the usage line of
+	// the help message, e.g. as --name=VALUE.
+	Required bool
+
+	// If true, the value of the option is asked for when it is not set by
+	// any source and the standard input is a terminal.
+	Prompt bool
+
+	// If true, the value of the option is secret. It is entered without
+	// echo when prompted for, and it is masked as *** in the help message,
+	// errors, traces and the configuration written by Parser.WriteConfig.
+	Secret bool
+
+	value reflect.Value
+
+	// The pre-parsed tags of the struct field of the option, if any
+	options *fieldTags
+
+	// The name of the method of the data struct of the group given by the
+	// default-func tag, which is looked up again by the copies of the group
+	// (see Group.Clone)
+	defaultMethod string
+
+	// The path of the struct field within the data struct of the group
+	// (see reflect.Value.FieldByIndex), or nil for options which were not
+	// created from a struct field
+	index []int
+
+	// The number of times the option was set
+	occurrences int
+
+	// The amount added to the integer field of a counter option each time
+	// it is specified without a value: 1, or -1 for the paired option
+	// decrementing the field (see the counter tag), and 0 for other options
+	counter int
+
+	// The map of a group created by NewMapGroup, in which the value of the
+	// option is stored under its long name
+	mapValues map[string]interface{}
+
+	// A copy of the value of the field at the time the group was created
+	initial reflect.Value
+
+	// The source which set the value of the option, or nil if the option
+	// still has its initial value
+	layer *layer
+
+	// Where the value of the option came from
+	origin Origin
 }
 
 // An option group. The option group has a name and a set of options.
@@ -57,6 +195,10 @@ type Group struct {
 	// The name of the group.
 	Name string
 
+	// A description of the group, which is shown below its name in the
+	// builtin help (optional).
+	Description string
+
 	// A map of long names to option option descriptions.
 	LongNames map[string]*Option
 
@@ -66,25 +208,274 @@ type Group struct {
 	// A list of all the options in the group.
 	Options []*Option
 
+	// The sub-groups of the group, created from struct fields with a group
+	// tag.
+	Groups []*Group
+
 	// An error which occurred when creating the group.
 	Error error
 
 	data interface{}
+
+	// The prefixes of the long names and environment variable names of
+	// the options of the group, given by the namespace and env-namespace
+	// tags of the group and its parents
+	namespace    string
+	envNamespace string
+
+	// The path of the struct field defining a sub-group within the data
+	// struct of its parent group
+	index []int
+
+	// The map of a group created by NewMapGroup
+	values map[string]interface{}
+
+	// Whether the group belongs to a frozen parser
+	frozen bool
+
+	// The custom names of the field tags of the data struct
+	tags TagNames
 }
 
 // Set the value of an option to the specified value. An error will be returned
 // if the specified value could not be converted to the corresponding option
 // value type.
 func (option *Option) Set(value *string) error {
+	if value != nil && !option.isChoice(*value) {
+		return newOptionError(ErrInvalidChoice, option,
+			fmt.Sprintf("invalid argument `%s' for flag `%s' (expected one of: %s)",
+				option.argument(*value),
+				option,
+				strings.Join(option.Choices, ", ")))
+	}
+
+	if max := option.options.Get("max-occurrences"); max != "" {
+		if n, err := strconv.Atoi(max); err == nil && option.occurrences >= n {
+			return newOptionError(ErrOccurrences, option,
+				fmt.Sprintf("flag `%s' cannot be specified more than %d times", option, n))
+		}
+	}
+
+	if pattern := option.options.regexp(); value != nil && pattern != nil && !pattern.MatchString(*value) {
+		return newOptionError(ErrValidation, option,
+			fmt.Sprintf("invalid argument `%s' for flag `%s' (expected to match %s)",
+				option.argument(*value),
+				option,
+				pattern))
+	}
+
+	if option.Validator != nil {
+		v := ""
+
+		if value != nil {
+			v = *value
+		}
+
+		if err := option.Validator(v); err != nil {
+			return option.validationError(value, err)
+		}
+	}
+
+	var err error
+
 	if option.isFunc() {
-		return option.call(value)
+		err = option.call(value)
+	} else if option.counter != 0 && value == nil {
+		option.value.SetInt(option.value.Int() + int64(option.counter))
+	} else if option.Setter != nil {
+		v := ""
+
+		if value != nil {
+			v = *value
+		}
+
+		err = option.Setter(v)
 	} else if value != nil {
-		return convert(*value, option.value, option.options)
+		err = convert(*value, option.value, option.options)
 	} else {
-		return convert("", option.value, option.options)
+		err = convert("", option.value, option.options)
 	}
 
-	return nil
+	if err == nil {
+		option.occurrences++
+	}
+
+	option.store()
+	return err
+}
+
+// AddOption adds an option to the group without the use of struct tags,
+// which is useful when the set of options is only known at runtime. The
+// value is a pointer to the variable holding the value of the option, or a
+// function with the same semantics as function fields. Either longName or
+// shortName (0 for none) must be specified. All other properties of the
+// returned option (e.g. Default or Choices) can be set on the option
+// directly. Descriptions are interned, such that the many options of
+// generated programs which share a description also share its memory.
+// ErrFrozen is returned if the group belongs to a frozen parser.
+func (g *Group) AddOption(longName string, shortName rune, description string, value interface{}) (*Option, error) {
+	if g.frozen {
+		return nil, ErrFrozen
+	}
+
+	if longName == "" && shortName == 0 {
+		return nil, errors.New("either a long or a short name must be specified")
+	}
+
+	val := reflect.ValueOf(value)
+
+	if val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	} else if val.Kind() == reflect.Func {
+		// Wrap the function in a settable value
+		fn := reflect.New(val.Type()).Elem()
+		fn.Set(val)
+		val = fn
+	} else {
+		return nil, fmt.Errorf("value of option `%s' is not a pointer or function", longName)
+	}
+
+	option := &Option{
+		Description: unique.Make(description).Value(),
+		ShortName:   shortName,
+		LongName:    longName,
+		value:       val,
+		initial:     copyValue(val),
+	}
+
+	if reason := checkType(val.Type()); reason != "" {
+		return nil, fmt.Errorf("option `%s' has %s", option, reason)
+	}
+
+	if err := g.addOption(option); err != nil {
+		return nil, err
+	}
+
+	return option, nil
+}
+
+// TagNames maps the names of field tags read by NewGroup (e.g. "long" or
+// "description") to custom names, such that structs which are already
+// annotated for other libraries can be reused. Tags with a custom name are
+// not read under their own name. For example, TagNames{"long": "name"}
+// reads the long names of options from name tags and ignores long tags.
+type TagNames map[string]string
+
+// NewGroupWithTags creates a new option group like NewGroup, reading the
+// field tags of the data struct and its sub-groups using the given custom
+// names.
+func NewGroupWithTags(name string, data interface{}, tags TagNames) *Group {
+	return newGroup(name, data, "", "", tags)
+}
+
+// NewMapGroup creates a new option group with the given name whose options
+// are defined by a map instead of a struct, which is useful when the set of
+// options is only known at runtime (e.g. when it is loaded from a plugin
+// manifest). Each key of the map is the long name of an option and its value
+// is the initial value of the option, which determines the type of the
+// option (e.g. 0 for an int option or []string{} for a string slice option).
+// The values of the map are replaced by the values of the options when they
+// are set. Other properties of the options (e.g. the description) can be set
+// on the options found in LongNames. Errors are stored in the Error field of
+// the group.
+func NewMapGroup(name string, values map[string]interface{}) *Group {
+	ret := NewGroup(name, nil)
+
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if values[key] == nil {
+			ret.Error = fmt.Errorf("option `%s' has no initial value", key)
+			break
+		}
+
+		val := reflect.New(reflect.TypeOf(values[key]))
+		val.Elem().Set(reflect.ValueOf(values[key]))
+
+		option, err := ret.AddOption(key, 0, "", val.Interface())
+
+		if err != nil {
+			ret.Error = err
+			break
+		}
+
+		option.mapValues = values
+	}
+
+	ret.values = values
+	return ret
+}
+
+// Data returns the data struct of the group, or the map of a group created
+// by NewMapGroup. This is mostly useful to access the values of a cloned
+// group (see Group.Clone).
+func (g *Group) Data() interface{} {
+	if g.values != nil {
+		return g.values
+	}
+
+	return g.data
+}
+
+// RemoveOption removes the option from the group, such that it is no longer
+// recognized when parsing and no longer shown in the help message. An option
+// can be replaced by removing it and adding a new option with the same names
+// using AddOption. RemoveOption returns false if the option is not in the
+// group or the group belongs to a frozen parser.
+func (g *Group) RemoveOption(option *Option) bool {
+	if g.frozen {
+		return false
+	}
+
+	for i, o := range g.Options {
+		if o != option {
+			continue
+		}
+
+		g.Options = append(g.Options[:i], g.Options[i+1:]...)
+
+		for name, o := range g.ShortNames {
+			if o == option {
+				delete(g.ShortNames, name)
+			}
+		}
+
+		for name, o := range g.LongNames {
+			if o == option {
+				delete(g.LongNames, name)
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Origin returns where the value of the option came from (e.g. the command
+// line or a configuration file).
+func (option *Option) Origin() Origin {
+	return option.origin
+}
+
+// Value returns the current value of the option.
+func (option *Option) Value() interface{} {
+	return option.value.Interface()
+}
+
+// IsSet returns whether the option was explicitly set by any of the sources
+// of option values (e.g. the command line, the environment or a
+// configuration file), as opposed to still having its initial value. This
+// allows programs to only apply the options which were specified by the
+// user.
+func (option *Option) IsSet() bool {
+	return option.layer != nil
 }
 
 // Convert an option to a human friendly readable string describing the option.
@@ -113,15 +504,17 @@ func (option *Option) String() string {
 // container. The data container is a pointer to a struct. The fields of the
 // struct represent the command line options (using field tags) and their values
 // will be set when their corresponding options appear in the command line
-// arguments.
+// arguments. The data can be nil to create a group to which options are
+// added using Group.AddOption. Errors in the definition of the options (e.g.
+// a field of an unsupported type) are stored in the Error field of the group
+// and returned by Parser.ParseArgs.
+//
+// Fields of a struct type (or a pointer to a struct) with a group tag create
+// a sub-group named by the tag, whose options are those of the struct. The
+// long names of the options of a sub-group are prefixed by the namespace tag
+// of the field and a dot (e.g. --server.port), and their environment
+// variable names by the env-namespace tag and an underscore. The fields of
+// anonymous embedded structs are added to the group itself.
 func NewGroup(name string, data interface{}) *Group {
-	ret := &Group{
-		Name:       name,
-		LongNames:  make(map[string]*Option),
-		ShortNames: make(map[rune]*Option),
-		data:       data,
-	}
-
-	ret.Error = ret.scan()
-	return ret
+	return newGroup(name, data, "", "", nil)
 }
@@ -1,12 +1,587 @@
 package flags
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"unicode/utf8"
 )
 
+// tagPair is a key and value of a struct tag.
+type tagPair struct {
+	key   string
+	value string
+}
+
+// tagPairs returns all the keys and values of tag, in order, following the
+// conventions of reflect.StructTag.
+func tagPairs(tag reflect.StructTag) []tagPair {
+	var ret []tagPair
+
+	for tag != "" {
+		i := 0
+
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+
+		tag = tag[i:]
+
+		if tag == "" {
+			break
+		}
+
+		i = 0
+
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' {
+			i++
+		}
+
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+
+		name := string(tag[:i])
+		tag = tag[i+1:]
+
+		i = 1
+
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+
+			i++
+		}
+
+		if i >= len(tag) {
+			break
+		}
+
+		qvalue := string(tag[:i+1])
+		tag = tag[i+1:]
+
+		value, err := strconv.Unquote(qvalue)
+
+		if err != nil {
+			break
+		}
+
+		ret = append(ret, tagPair{name, value})
+	}
+
+	return ret
+}
+
+// tagValues returns all the values of key in tag. Contrary to tag.Get, which
+// only returns the first value, this supports keys which are specified more
+// than once (e.g. choice:"a" choice:"b").
+func tagValues(tag reflect.StructTag, key string) []string {
+	var ret []string
+
+	// Avoid parsing tags which cannot contain the key
+	if !strings.Contains(string(tag), key) {
+		return nil
+	}
+
+	for _, pair := range tagPairs(tag) {
+		if pair.key == key {
+			ret = append(ret, pair.value)
+		}
+	}
+
+	return ret
+}
+
+// makeTag creates a struct tag from keys and values.
+func makeTag(pairs []tagPair) reflect.StructTag {
+	parts := make([]string, len(pairs))
+
+	for i, pair := range pairs {
+		parts[i] = pair.key + ":" + strconv.Quote(pair.value)
+	}
+
+	return reflect.StructTag(strings.Join(parts, " "))
+}
+
+// normalize translates the custom tag names of a struct tag into the names
+// of the tags read by the scanner. Tags which the scanner reads under a
+// custom name are dropped, such that tags of other libraries with the same
+// name are not interpreted.
+func (t TagNames) normalize(tag reflect.StructTag) reflect.StructTag {
+	if len(t) == 0 {
+		return tag
+	}
+
+	custom := make(map[string]string, len(t))
+
+	for name, key := range t {
+		custom[key] = name
+	}
+
+	var pairs []tagPair
+
+	for _, pair := range tagPairs(tag) {
+		if name, ok := custom[pair.key]; ok {
+			pairs = append(pairs, tagPair{name, pair.value})
+		} else if _, ok := t[pair.key]; !ok {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return makeTag(pairs)
+}
+
+// compactTagKeys are abbreviations of tag names in compact flags tags.
+var compactTagKeys = map[string]string{
+	"desc":   "description",
+	"env-ns": "env-namespace",
+	"ns":     "namespace",
+}
+
+// fieldTag returns the tag of a field, translating custom tag names (see
+// TagNames) and expanding a compact flags tag of the form
+// flags:"-p, --port, desc='Port to listen on', default=8080" into the
+// equivalent individual tags.
+func (t TagNames) fieldTag(field reflect.StructField) (reflect.StructTag, error) {
+	tag := t.normalize(field.Tag)
+	compact, ok := tag.Lookup("flags")
+
+	if !ok {
+		return tag, nil
+	}
+
+	items, err := splitCompactTag(compact)
+
+	if err != nil {
+		return tag, fmt.Errorf("invalid flags tag of field `%s': %s", field.Name, err)
+	}
+
+	pairs := tagPairs(tag)
+
+	for _, item := range items {
+		var pair tagPair
+
+		switch {
+		case strings.HasPrefix(item, "--"):
+			pair = tagPair{"long", item[2:]}
+		case strings.HasPrefix(item, "-"):
+			pair = tagPair{"short", item[1:]}
+		case strings.Contains(item, "="):
+			pos := strings.Index(item, "=")
+			pair = tagPair{strings.TrimSpace(item[:pos]), strings.TrimSpace(item[pos+1:])}
+
+			if value := pair.value; len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+				pair.value = value[1 : len(value)-1]
+			}
+		default:
+			pair = tagPair{item, "true"}
+		}
+
+		if key, ok := compactTagKeys[pair.key]; ok {
+			pair.key = key
+		}
+
+		if pair.key == "" || pair.value == "" {
+			return tag, fmt.Errorf("invalid flags tag of field `%s': malformed item `%s'", field.Name, item)
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	return makeTag(pairs), nil
+}
+
+// fieldTags are the pre-parsed tags of the struct field of an option. They
+// are shared by all the options created from the same field (see
+// TagNames.structFields), such that options do not each hold a copy. A nil
+// *fieldTags, as used by options not created from a struct field, has no
+// tags.
+type fieldTags struct {
+	// The name and index of the struct field
+	name  string
+	index []int
+
+	pairs []tagPair
+
+	// The compiled pattern tag, if any
+	pattern    *regexp.Regexp
+	patternErr error
+}
+
+func newFieldTags(name string, index []int, tag reflect.StructTag) *fieldTags {
+	ret := &fieldTags{
+		name:  name,
+		index: index,
+		pairs: tagPairs(tag),
+	}
+
+	if p := ret.Get("pattern"); p != "" {
+		ret.pattern, ret.patternErr = regexp.Compile(p)
+	}
+
+	return ret
+}
+
+// regexp returns the compiled pattern tag, if any.
+func (t *fieldTags) regexp() *regexp.Regexp {
+	if t == nil {
+		return nil
+	}
+
+	return t.pattern
+}
+
+// Get returns the first value of the tag with the given key, or "" if there
+// is no such tag, like reflect.StructTag.Get.
+func (t *fieldTags) Get(key string) string {
+	if t == nil {
+		return ""
+	}
+
+	for _, pair := range t.pairs {
+		if pair.key == key {
+			return pair.value
+		}
+	}
+
+	return ""
+}
+
+// values returns all the values of the tags with the given key (see
+// tagValues).
+func (t *fieldTags) values(key string) []string {
+	var ret []string
+
+	if t == nil {
+		return nil
+	}
+
+	for _, pair := range t.pairs {
+		if pair.key == key {
+			ret = append(ret, pair.value)
+		}
+	}
+
+	return ret
+}
+
+// structField is the tag of a field of a struct type, as returned by
+// TagNames.fieldTag, and its pre-parsed tags.
+type structField struct {
+	tag  reflect.StructTag
+	tags *fieldTags
+	err  error
+}
+
+// structKey identifies the tags of the fields of a struct type read using
+// specific tag names.
+type structKey struct {
+	tp   reflect.Type
+	tags string
+}
+
+// structFieldsCache caches the tags of the fields of struct types (see
+// TagNames.structFields), such that creating many groups of the same type
+// does not parse the tags each time.
+var structFieldsCache sync.Map
+
+// key returns a string which uniquely identifies the tag names.
+func (t TagNames) key() string {
+	names := make([]string, 0, len(t))
+
+	for name, key := range t {
+		names = append(names, strconv.Quote(name)+":"+strconv.Quote(key))
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+// structFields returns the tags of all the fields of a struct type, see
+// fieldTag. The result is cached per type and tag names and must not be
+// modified.
+func (t TagNames) structFields(tp reflect.Type) []structField {
+	key := structKey{tp, t.key()}
+
+	if ret, ok := structFieldsCache.Load(key); ok {
+		return ret.([]structField)
+	}
+
+	ret := make([]structField, tp.NumField())
+
+	for i := range ret {
+		field := tp.Field(i)
+		ret[i].tag, ret[i].err = t.fieldTag(field)
+		ret[i].tags = newFieldTags(field.Name, field.Index, ret[i].tag)
+	}
+
+	structFieldsCache.Store(key, ret)
+	return ret
+}
+
+// splitCompactTag splits a compact flags tag on commas which are not
+// enclosed in single quotes.
+func splitCompactTag(tag string) ([]string, error) {
+	var ret []string
+
+	quoted := false
+	start := 0
+
+	for i := 0; i <= len(tag); i++ {
+		if i < len(tag) && tag[i] == '\'' {
+			quoted = !quoted
+		}
+
+		if i == len(tag) || (tag[i] == ',' && !quoted) {
+			if item := strings.TrimSpace(tag[start:i]); item != "" {
+				ret = append(ret, item)
+			}
+
+			start = i + 1
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+
+	return ret, nil
+}
+
+// copyValue returns a copy of val which does not share the elements of
+// slices and maps with val.
+func copyValue(val reflect.Value) reflect.Value {
+	// Zero values are not allocated, as most options are initially zero
+	if val.IsZero() {
+		return reflect.Zero(val.Type())
+	}
+
+	ret := reflect.New(val.Type()).Elem()
+
+	switch val.Kind() {
+	case reflect.Slice:
+		if !val.IsNil() {
+			ret.Set(reflect.MakeSlice(val.Type(), val.Len(), val.Len()))
+			reflect.Copy(ret, val)
+		}
+	case reflect.Map:
+		if !val.IsNil() {
+			ret.Set(reflect.MakeMap(val.Type()))
+
+			for _, key := range val.MapKeys() {
+				ret.SetMapIndex(key, val.MapIndex(key))
+			}
+		}
+	default:
+		ret.Set(val)
+	}
+
+	return ret
+}
+
+// reset restores the value the option had when its group was created.
+func (option *Option) reset() {
+	option.value.Set(copyValue(option.initial))
+	option.occurrences = 0
+	option.store()
+}
+
+// hasDefault returns whether the option is initialized to its Default
+// value, which is not the case for options with an optional argument, for
+// which Default is the value used when the argument is omitted.
+func (option *Option) hasDefault() bool {
+	return option.Default != "" && !option.OptionalArgument && !option.isFunc()
+}
+
+// applyDefault sets the option to its Default value.
+func (option *Option) applyDefault() error {
+	if !option.hasDefault() {
+		return nil
+	}
+
+	option.value.Set(reflect.Zero(option.value.Type()))
+
+	if err := option.marshalError(convert(option.Default, option.value, option.options)); err != nil {
+		return err
+	}
+
+	option.store()
+	return nil
+}
+
+// clearDefault clears the Default value of slice and map options when they
+// are first set, such that specified values replace the default values
+// instead of being added to them.
+func (option *Option) clearDefault() {
+	if kind := option.value.Kind(); option.hasDefault() && (kind == reflect.Slice || kind == reflect.Map) {
+		option.value.Set(reflect.Zero(option.value.Type()))
+	}
+}
+
+// store stores the value of an option of a group created by NewMapGroup in
+// the map of the group.
+func (option *Option) store() {
+	if option.mapValues != nil {
+		option.mapValues[option.LongName] = option.value.Interface()
+	}
+}
+
+// optionByField returns the option of the struct field with the given name,
+// or nil if there is no such option.
+func (g *Group) optionByField(name string) *Option {
+	for _, option := range g.Options {
+		if option.options != nil && option.options.name == name {
+			return option
+		}
+	}
+
+	return nil
+}
+
+// synopsis returns the shortest representation of the option for use in
+// usage lines and messages, which is its long name if it has one.
+func (option *Option) synopsis() string {
+	if option.LongName != "" {
+		return "--" + option.LongName
+	}
+
+	return "-" + string(option.ShortName)
+}
+
+// usage formats the option for the usage line, including a placeholder of
+// its argument, e.g. --name=VALUE.
+func (option *Option) usage() string {
+	ret := option.synopsis()
+
+	if !option.canArgument() {
+		return ret
+	}
+
+	value := option.ValueName
+
+	if value == "" {
+		value = "VALUE"
+	}
+
+	switch {
+	case option.OptionalArgument:
+		return ret + "[=" + value + "]"
+	case option.LongName == "":
+		return ret + " " + value
+	}
+
+	return ret + "=" + value
+}
+
+// isInitial returns whether the option still has the value it had when its
+// group was created.
+func (option *Option) isInitial() bool {
+	if option.isFunc() {
+		return true
+	}
+
+	return reflect.DeepEqual(option.value.Interface(), option.initial.Interface())
+}
+
+// marshalError converts an error which occurred while setting the option
+// into a descriptive *Error.
+func (option *Option) marshalError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if err == errOutOfRange {
+		return newOptionError(ErrOutOfRange, option,
+			fmt.Sprintf("invalid argument for flag `%s' (expected a value %s)",
+				option,
+				option.rangeDescription())).wrap(err)
+	}
+
+	if _, ok := err.(*Error); !ok && (option.isFunc() || option.value.Type() == getterType || option.hasConverter()) {
+		// The error was returned by the function, flag.Getter or registered
+		// converter, and could contain the value of a secret option
+		msg := fmt.Sprintf("invalid argument for flag `%s': %s", option, err)
+
+		if option.Secret {
+			msg = fmt.Sprintf("invalid argument for flag `%s'", option)
+		}
+
+		err = newOptionError(ErrMarshal, option, msg).wrap(err)
+	} else if !ok {
+		err = newOptionError(ErrMarshal, option,
+			fmt.Sprintf("invalid argument for flag `%s' (expected %s)",
+				option,
+				option.value.Type())).wrap(err)
+	}
+
+	return err
+}
+
+// validationError attributes an error returned by a validator to the
+// option.
+func (option *Option) validationError(value *string, err error) error {
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+
+	if value == nil {
+		return newOptionError(ErrValidation, option,
+			fmt.Sprintf("invalid flag `%s': %s", option, err)).wrap(err)
+	}
+
+	return newOptionError(ErrValidation, option,
+		fmt.Sprintf("invalid argument `%s' for flag `%s': %s", option.argument(*value), option, err)).wrap(err)
+}
+
+// argument returns the argument of the option for use in messages, which is
+// masked for secret options.
+func (option *Option) argument(value string) string {
+	if option.Secret {
+		return "***"
+	}
+
+	return value
+}
+
+// rangeDescription describes the range of valid values given by the min
+// and max tags of the option, or returns "" if the option has neither.
+func (option *Option) rangeDescription() string {
+	min := option.options.Get("min")
+	max := option.options.Get("max")
+
+	switch {
+	case min != "" && max != "":
+		return fmt.Sprintf("between %s and %s", min, max)
+	case min != "":
+		return fmt.Sprintf("of at least %s", min)
+	case max != "":
+		return fmt.Sprintf("of at most %s", max)
+	}
+
+	return ""
+}
+
+func (option *Option) isChoice(value string) bool {
+	if len(option.Choices) == 0 {
+		return true
+	}
+
+	for _, choice := range option.Choices {
+		if choice == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (option *Option) canArgument() bool {
-	if option.isBool() {
+	if option.isBool() || option.counter != 0 {
 		return false
 	}
 
@@ -17,9 +592,28 @@ func (option *Option) canArgument() bool {
 	return true
 }
 
+// hasConverter returns whether the option, or the elements of a slice or map
+// option, are converted using a registered converter.
+func (option *Option) hasConverter() bool {
+	tp := option.value.Type()
+
+	if tp.Kind() == reflect.Slice || tp.Kind() == reflect.Map {
+		if _, ok := lookupConverter(tp); !ok {
+			tp = tp.Elem()
+		}
+	}
+
+	_, ok := lookupConverter(tp)
+	return ok
+}
+
 func (option *Option) isBool() bool {
 	tp := option.value.Type()
 
+	if tp == getterType {
+		return option.value.Interface().(Getter).isBoolFlag()
+	}
+
 	switch tp.Kind() {
 	case reflect.Bool:
 		return true
@@ -53,54 +647,313 @@ func (option *Option) call(value *string) error {
 	}
 
 	if len(retval) == 1 && retval[0].Type() == reflect.TypeOf((*error)(nil)).Elem() {
-		return retval[0].Interface().(error)
+		// A nil error does not convert to error
+		err, _ := retval[0].Interface().(error)
+		return err
+	}
+
+	return nil
+}
+
+// checkType returns the reason why options cannot be stored in values of
+// the given type, or "" if they can.
+func checkType(tp reflect.Type) string {
+	if tp.Kind() == reflect.Func {
+		errtype := reflect.TypeOf((*error)(nil)).Elem()
+
+		if tp.NumIn() > 1 || (tp.NumIn() == 1 && !isSupportedType(tp.In(0))) {
+			return fmt.Sprintf("unsupported function type %s (expected a function with at most one argument of a supported type)", tp)
+		}
+
+		if tp.NumOut() > 1 || (tp.NumOut() == 1 && tp.Out(0) != errtype) {
+			return fmt.Sprintf("unsupported function type %s (expected a function returning nothing or an error)", tp)
+		}
+
+		return ""
+	}
+
+	if !isSupportedType(tp) && tp != getterType {
+		return fmt.Sprintf("unsupported type %s", tp)
+	}
+
+	return ""
+}
+
+// addOption adds the option to the group, returning an error if its short
+// or long name is already used by another option of the group.
+func (g *Group) addOption(option *Option) error {
+	shortnames := option.ShortAliases
+
+	if option.ShortName != 0 {
+		shortnames = append([]rune{option.ShortName}, shortnames...)
+	}
+
+	for _, name := range shortnames {
+		if g.ShortNames[name] != nil {
+			return fmt.Errorf("option `%s' conflicts with option `%s'", option, g.ShortNames[name])
+		}
+	}
+
+	longnames := option.LongAliases
+
+	if option.LongName != "" {
+		longnames = append([]string{option.LongName}, longnames...)
+	}
+
+	for _, name := range longnames {
+		if g.LongNames[name] != nil {
+			return fmt.Errorf("option `%s' conflicts with option `%s'", option, g.LongNames[name])
+		}
+	}
+
+	g.Options = append(g.Options, option)
+
+	for _, name := range shortnames {
+		g.ShortNames[name] = option
+	}
+
+	for _, name := range longnames {
+		g.LongNames[name] = option
+	}
+
+	return nil
+}
+
+func newGroup(name string, data interface{}, namespace string, envNamespace string, tags TagNames) *Group {
+	ret := &Group{
+		Name:         name,
+		LongNames:    make(map[string]*Option),
+		ShortNames:   make(map[rune]*Option),
+		data:         data,
+		namespace:    namespace,
+		envNamespace: envNamespace,
+		tags:         tags,
+	}
+
+	ret.Error = ret.scan()
+	return ret
+}
+
+// groupError returns the error of the group or the first error of its
+// sub-groups, if any.
+func (g *Group) groupError() error {
+	if g.Error != nil {
+		return g.Error
+	}
+
+	for _, grp := range g.Groups {
+		if err := grp.groupError(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// all appends the group followed by all its sub-groups to groups.
+func (g *Group) all(groups []*Group) []*Group {
+	groups = append(groups, g)
+
+	for _, grp := range g.Groups {
+		groups = grp.all(groups)
+	}
+
+	return groups
+}
+
+// structPointer returns a pointer to the struct value of a field of a struct
+// type or a pointer to a struct type, allocating the struct if the pointer
+// is nil. It returns false for fields of other types.
+func structPointer(val reflect.Value) (reflect.Value, bool) {
+	switch {
+	case val.Kind() == reflect.Struct:
+		return val.Addr(), true
+	case val.Kind() == reflect.Ptr && val.Type().Elem().Kind() == reflect.Struct:
+		if val.IsNil() {
+			if !val.CanSet() {
+				return val, false
+			}
+
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+
+		return val, true
+	}
+
+	return val, false
+}
+
+// defaultFunc returns a function calling the method with the given name of
+// the data struct of the group, which returns either a string or a string
+// and an error.
+func (g *Group) defaultFunc(name string) (func() (string, error), error) {
+	method := reflect.ValueOf(g.data).MethodByName(name)
+
+	if !method.IsValid() {
+		return nil, fmt.Errorf("method `%s' does not exist", name)
+	}
+
+	tp := method.Type()
+	stringtype := reflect.TypeOf("")
+	errtype := reflect.TypeOf((*error)(nil)).Elem()
+
+	if tp.NumIn() != 0 || tp.NumOut() < 1 || tp.NumOut() > 2 || tp.Out(0) != stringtype || (tp.NumOut() == 2 && tp.Out(1) != errtype) {
+		return nil, fmt.Errorf("method `%s' has type %s (expected func() string or func() (string, error))", name, tp)
+	}
+
+	return func() (string, error) {
+		ret := method.Call(nil)
+
+		if len(ret) == 2 && !ret[1].IsNil() {
+			return "", ret[1].Interface().(error)
+		}
+
+		return ret[0].String(), nil
+	}, nil
+}
+
+// fieldIndex returns the index of the i-th field of the struct at index,
+// without sharing the underlying array with index.
+func fieldIndex(index []int, i int) []int {
+	return append(append([]int(nil), index...), i)
+}
+
+// scanSubGroup adds the sub-group defined by a field with a group tag.
+func (g *Group) scanSubGroup(field reflect.StructField, tag reflect.StructTag, val reflect.Value, index []int) error {
+	ptr, ok := structPointer(val)
+
+	if !ok {
+		return fmt.Errorf("field `%s' has a group tag but is not a struct", field.Name)
+	}
+
+	namespace := g.namespace
+	envNamespace := g.envNamespace
+
+	if ns := tag.Get("namespace"); ns != "" {
+		namespace += ns + "."
+	}
+
+	if ns := tag.Get("env-namespace"); ns != "" {
+		envNamespace += ns + "_"
+	}
+
+	grp := newGroup(tag.Get("group"), ptr.Interface(), namespace, envNamespace, g.tags)
+
+	if grp.Error != nil {
+		return grp.Error
 	}
 
+	grp.index = index
+	g.Groups = append(g.Groups, grp)
 	return nil
 }
 
-func (g *Group) scan() error {
+// longDescription returns the long description of the option of a field,
+// provided by the struct containing the field (see LongDescriber).
+func longDescription(realval reflect.Value, field string) string {
+	// Embedded structs of unexported types cannot be accessed
+	if !realval.CanAddr() || !realval.Addr().CanInterface() {
+		return ""
+	}
+
+	if d, ok := realval.Addr().Interface().(LongDescriber); ok {
+		return d.LongDescription(field)
+	}
+
+	return ""
+}
+
+func (g *Group) scan() (err error) {
+	// Convert unexpected reflection panics into errors, such that a single
+	// unsupported field does not crash the program
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to scan options of group `%s': %v", g.Name, r)
+		}
+	}()
+
+	// Groups without data only contain options added using AddOption
+	if g.data == nil {
+		return nil
+	}
+
 	// Get all the public fields in the data struct
 	ptrval := reflect.ValueOf(g.data)
 
-	if ptrval.Type().Kind() != reflect.Ptr {
-		panic(ErrNotPointerToStruct)
+	if !ptrval.IsValid() || ptrval.Type().Kind() != reflect.Ptr || ptrval.IsNil() {
+		return ErrNotPointerToStruct
 	}
 
 	stype := ptrval.Type().Elem()
 
 	if stype.Kind() != reflect.Struct {
-		panic(ErrNotPointerToStruct)
+		return ErrNotPointerToStruct
 	}
 
-	realval := reflect.Indirect(ptrval)
+	return g.scanStruct(reflect.Indirect(ptrval), nil)
+}
+
+// scanStruct adds the options defined by the fields of a struct value. The
+// index is the path of the struct within the data struct of the group (see
+// reflect.Value.FieldByIndex).
+func (g *Group) scanStruct(realval reflect.Value, index []int) error {
+	stype := realval.Type()
+	fields := g.tags.structFields(stype)
 
 	for i := 0; i < stype.NumField(); i++ {
 		field := stype.Field(i)
+		tag := fields[i].tag
+
+		if err := fields[i].err; err != nil {
+			return err
+		}
+
+		// Add the options of anonymous embedded structs to the group
+		if field.Anonymous {
+			if ptr, ok := structPointer(realval.Field(i)); ok && tag.Get("no-flag") == "" {
+				if err := g.scanStruct(ptr.Elem(), fieldIndex(index, i)); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
 
 		// PkgName is set only for non-exported fields, which we ignore
+		// unless they are tagged as options, since their value cannot be
+		// set
 		if field.PkgPath != "" {
+			if tag.Get("long") != "" || tag.Get("short") != "" {
+				return fmt.Errorf("field `%s' is tagged as an option but is not exported", field.Name)
+			}
+
 			continue
 		}
 
-		// Skip anonymous fields.
-		// TODO: would be useful to support
-		if field.Anonymous {
+		// Skip fields with the no-flag tag
+		if tag.Get("no-flag") != "" {
 			continue
 		}
 
-		// Skip fields with the no-flag tag
-		if field.Tag.Get("no-flag") != "" {
+		if tag.Get("group") != "" {
+			if err := g.scanSubGroup(field, tag, realval.Field(i), fieldIndex(index, i)); err != nil {
+				return err
+			}
+
 			continue
 		}
 
-		longname := field.Tag.Get("long")
-		shortname := field.Tag.Get("short")
+		longname := tag.Get("long")
+		shortname := tag.Get("short")
 
 		if longname == "" && shortname == "" {
 			continue
 		}
 
+		if reason := checkType(field.Type); reason != "" {
+			return fmt.Errorf("field `%s' has %s", field.Name, reason)
+		}
+
 		short := rune(0)
 		rc := utf8.RuneCountInString(shortname)
 
@@ -110,31 +963,169 @@ func (g *Group) scan() error {
 			short, _ = utf8.DecodeRuneInString(shortname)
 		}
 
-		description := field.Tag.Get("description")
-		def := field.Tag.Get("default")
+		description := tag.Get("description")
+		long := longDescription(realval, field.Name)
+
+		def := tag.Get("default")
+		defaultMask := tag.Get("default-mask")
+
+		optional := (tag.Get("optional") != "")
+		valueName := tag.Get("value-name")
+		noCompletion := (tag.Get("no-completion") != "")
+		envName := tag.Get("env")
+
+		if longname != "" {
+			longname = g.namespace + longname
+		}
+
+		if envName != "" {
+			envName = g.envNamespace + envName
+		}
+
+		var defaultFunc func() (string, error)
+
+		if name := tag.Get("default-func"); name != "" {
+			var err error
+
+			if defaultFunc, err = g.defaultFunc(name); err != nil {
+				return fmt.Errorf("invalid default-func for field `%s': %s", field.Name, err)
+			}
+		}
+
+		var shortAliases []rune
+
+		for _, alias := range tagValues(tag, "short-alias") {
+			if utf8.RuneCountInString(alias) != 1 {
+				return ErrShortNameTooLong
+			}
+
+			r, _ := utf8.DecodeRuneInString(alias)
+			shortAliases = append(shortAliases, r)
+		}
+
+		aliases := tagValues(tag, "long-alias")
+
+		for i, alias := range aliases {
+			aliases[i] = g.namespace + alias
+		}
+		envDelim := tag.Get("env-delim")
+		configFile := (tag.Get("config-file") != "")
+		required := (tag.Get("required") != "")
+		prompt := tag.Get("prompt")
+
+		if err := fields[i].tags.patternErr; err != nil {
+			return fmt.Errorf("invalid pattern for field `%s': %w", field.Name, err)
+		}
+
+		if err := checkBounds(field.Type, fields[i].tags); err != nil {
+			return fmt.Errorf("invalid range for field `%s': %s", field.Name, err)
+		}
+
+		// Fields of the data struct itself share the index of their tags
+		optionIndex := fields[i].tags.index
 
-		optional := (field.Tag.Get("optional") != "")
+		if index != nil {
+			optionIndex = fieldIndex(index, i)
+		}
 
 		option := &Option{
 			Description:      description,
+			LongDescription:  long,
 			ShortName:        short,
 			LongName:         longname,
+			DefaultFunc:      defaultFunc,
+			defaultMethod:    tag.Get("default-func"),
+			DefaultMask:      defaultMask,
+			ShortAliases:     shortAliases,
+			LongAliases:      aliases,
 			Default:          def,
 			OptionalArgument: optional,
+			EnvName:          envName,
+			EnvDelim:         envDelim,
+			ValueName:        valueName,
+			Choices:          tagValues(tag, "choice"),
+			NoCompletion:     noCompletion,
+			ConfigFile:       configFile,
+			Required:         required,
+			Prompt:           prompt != "",
+			Secret:           prompt == "secure" || tag.Get("secret") != "",
+			index:            optionIndex,
 			value:            realval.Field(i),
-			options:          field.Tag,
+			options:          fields[i].tags,
+		}
+
+		decShort := tag.Get("decrement-short")
+		decLong := tag.Get("decrement-long")
+
+		if tag.Get("counter") != "" || decShort != "" || decLong != "" {
+			switch field.Type.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			default:
+				return fmt.Errorf("counter field `%s' is not a signed integer", field.Name)
+			}
+
+			option.counter = 1
+		}
+
+		if err := option.applyDefault(); err != nil {
+			return fmt.Errorf("invalid default value for field `%s': %s", field.Name, err)
 		}
 
-		g.Options = append(g.Options, option)
+		option.initial = copyValue(option.value)
 
-		if option.ShortName != 0 {
-			g.ShortNames[option.ShortName] = option
+		if err := g.addOption(option); err != nil {
+			return err
 		}
 
-		if option.LongName != "" {
-			g.LongNames[option.LongName] = option
+		if decShort != "" || decLong != "" {
+			if err := g.addDecrement(option, decShort, decLong, tag.Get("decrement-description")); err != nil {
+				return fmt.Errorf("invalid decrement option of field `%s': %w", field.Name, err)
+			}
 		}
 	}
 
 	return nil
 }
+
+// addDecrement adds the option decrementing the field of a counter option,
+// with the given names. The decrement option can only be specified on the
+// command line, while other sources set the counter option.
+func (g *Group) addDecrement(counter *Option, shortname string, longname string, description string) error {
+	short := rune(0)
+	rc := utf8.RuneCountInString(shortname)
+
+	if rc > 1 {
+		return ErrShortNameTooLong
+	} else if rc == 1 {
+		short, _ = utf8.DecodeRuneInString(shortname)
+	}
+
+	if longname != "" {
+		longname = g.namespace + longname
+	}
+
+	option := &Option{
+		Description:  description,
+		ShortName:    short,
+		LongName:     longname,
+		NoCompletion: counter.NoCompletion,
+		index:        counter.index,
+		value:        counter.value,
+		initial:      counter.initial,
+		counter:      -1,
+		options: &fieldTags{
+			name:  counter.options.name,
+			index: counter.options.index,
+			pairs: []tagPair{{"sources", "cli"}},
+		},
+	}
+
+	return g.addOption(option)
+}
+
+// sharesField returns whether the option is the decrement option of a
+// counter option, which is not included in configuration dumps since it
+// shares the field of the counter option.
+func (option *Option) sharesField() bool {
+	return option.counter < 0
+}
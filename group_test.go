@@ -0,0 +1,815 @@
+package flags
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAddOption(t *testing.T) {
+	var opts struct {
+		Verbose bool `short:"v" long:"verbose"`
+	}
+
+	grp := NewGroup("Application Options", &opts)
+
+	var port int
+	var names []string
+
+	if _, err := grp.AddOption("port", 'p', "Port", &port); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	option, err := grp.AddOption("name", 0, "Name", &names)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	option.Default = "default"
+
+	plugins := NewGroup("Plugin Options", nil)
+	var called string
+
+	if _, err := plugins.AddOption("plugin", 0, "Plugin", func(name string) { called = name }); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	p := NewNamedParser("test", None, grp, plugins)
+
+	if _, err := p.ParseArgs([]string{"-v", "-p", "80", "--name", "a", "--name", "b", "--plugin", "x"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !opts.Verbose || port != 80 || len(names) != 2 || called != "x" {
+		t.Errorf("Unexpected values: %v, %d, %v, %q", opts.Verbose, port, names, called)
+	}
+
+	tests := []struct {
+		long    string
+		short   rune
+		value   interface{}
+		message string
+	}{
+		{"", 0, &port, "either a long or a short name must be specified"},
+		{"other", 0, port, "value of option `other' is not a pointer or function"},
+		{"other", 0, &[]*int{}, "option `--other' has unsupported type []*int"},
+		{"other", 'v', &port, "option `-v, --other' conflicts with option `-v, --verbose'"},
+		{"port", 0, &port, "option `--port' conflicts with option `-p, --port'"},
+	}
+
+	for _, test := range tests {
+		if _, err := grp.AddOption(test.long, test.short, "", test.value); err == nil || err.Error() != test.message {
+			t.Errorf("Expected error %q but got %v", test.message, err)
+		}
+	}
+}
+
+func TestSetter(t *testing.T) {
+	grp := NewGroup("Application Options", nil)
+
+	var port int
+	option, err := grp.AddOption("port", 'p', "Port", &port)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var values []string
+
+	option.Setter = func(value string) error {
+		values = append(values, value)
+		port = len(value)
+		return nil
+	}
+
+	p := NewNamedParser("test", None, grp)
+
+	if _, err := p.ParseArgs([]string{"--port", "abc"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if port != 3 || !reflect.DeepEqual(values, []string{"abc"}) {
+		t.Errorf("Expected the setter to set the option but got %d (%v)", port, values)
+	}
+
+	// Clones convert the arguments into variables of their own
+	clone := p.Clone()
+
+	if _, err := clone.ParseArgs([]string{"--port", "8080"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if port != 3 || clone.FindOptionByLongName("port").Value() != 8080 {
+		t.Errorf("Expected the clone not to use the setter but got %d and %v", port, clone.FindOptionByLongName("port").Value())
+	}
+}
+
+func TestRemoveOption(t *testing.T) {
+	var opts struct {
+		Verbose bool   `short:"v" long:"verbose" description:"Verbose"`
+		Debug   bool   `long:"debug" description:"Debug"`
+		Name    string `long:"name" description:"Name"`
+	}
+
+	var plugin struct {
+		Plugin string `long:"plugin" description:"Plugin"`
+	}
+
+	grp := NewGroup("Application Options", &opts)
+	plugins := NewGroup("Plugin Options", &plugin)
+	p := NewNamedParser("test", None, grp, plugins)
+
+	if !grp.RemoveOption(grp.LongNames["debug"]) || grp.RemoveOption(plugins.LongNames["plugin"]) {
+		t.Fatalf("Unexpected result of RemoveOption")
+	}
+
+	// Replace the name option by one with a short name
+	grp.RemoveOption(grp.LongNames["name"])
+
+	var name string
+
+	if _, err := grp.AddOption("name", 'n', "Name", &name); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !p.RemoveGroup(plugins) || p.RemoveGroup(plugins) {
+		t.Fatalf("Unexpected result of RemoveGroup")
+	}
+
+	if _, err := p.ParseArgs([]string{"-v", "-n", "x"}); err != nil || !opts.Verbose || name != "x" {
+		t.Fatalf("Unexpected result: %v, %v, %q", err, opts.Verbose, name)
+	}
+
+	for _, args := range [][]string{{"--debug"}, {"--plugin", "x"}} {
+		if _, err := p.ParseArgs(args); err == nil {
+			t.Errorf("Expected error for removed option %v", args)
+		}
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if help := b.String(); strings.Contains(help, "debug") || strings.Contains(help, "Plugin") || !strings.Contains(help, "-n, --name") {
+		t.Errorf("Unexpected help message:\n%s", help)
+	}
+}
+
+func TestFindOption(t *testing.T) {
+	var opts struct {
+		Verbose bool `short:"v" long:"verbose"`
+	}
+
+	var other struct {
+		Verbose bool `short:"x" long:"verbose"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts), NewGroup("Other Options", &other))
+
+	app := p.Groups[0].LongNames["verbose"]
+	otherVerbose := p.Groups[1].LongNames["verbose"]
+
+	tests := []struct {
+		option   *Option
+		expected *Option
+	}{
+		{p.FindOptionByLongName("verbose"), app},
+		{p.FindOptionByShortName('x'), otherVerbose},
+		{p.FindOptionByShortName('y'), nil},
+		{p.FindOption("verbose"), app},
+		{p.FindOption("Other Options.verbose"), otherVerbose},
+		{p.FindOption("Missing Options.verbose"), nil},
+		{p.FindOption("Other Options.missing"), nil},
+	}
+
+	for i, test := range tests {
+		if test.option != test.expected {
+			t.Errorf("Unexpected option for lookup %d: %v", i, test.option)
+		}
+	}
+}
+
+func TestEachOption(t *testing.T) {
+	var opts struct {
+		Verbose bool `short:"v" long:"verbose"`
+		Name    bool `long:"name"`
+	}
+
+	var other struct {
+		Debug bool `long:"debug"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts), NewGroup("Other Options", &other))
+
+	var groups, options []string
+
+	p.EachGroup(func(grp *Group) {
+		groups = append(groups, grp.Name)
+	})
+
+	p.EachOption(func(grp *Group, option *Option) {
+		options = append(options, grp.Name+"."+option.LongName)
+	})
+
+	if strings.Join(groups, ",") != "Application Options,Other Options" {
+		t.Errorf("Unexpected groups: %v", groups)
+	}
+
+	if strings.Join(options, ",") != "Application Options.verbose,Application Options.name,Other Options.debug" {
+		t.Errorf("Unexpected options: %v", options)
+	}
+}
+
+type loggingOptions struct {
+	Level string `long:"log-level" description:"Log level"`
+}
+
+type tlsOptions struct {
+	Cert string `long:"cert" env:"CERT" description:"Certificate"`
+}
+
+type serverOptions struct {
+	Port int        `long:"port" env:"PORT" description:"Port"`
+	TLS  tlsOptions `group:"TLS Options" namespace:"tls" env-namespace:"TLS"`
+}
+
+func TestNestedGroups(t *testing.T) {
+	var opts struct {
+		loggingOptions
+
+		Verbose bool           `short:"v" long:"verbose" description:"Verbose"`
+		Server  serverOptions  `group:"Server Options" namespace:"server" env-namespace:"SERVER"`
+		Client  *serverOptions `group:"Client Options"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if err := p.Groups[0].Error; err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	t.Setenv("SERVER_TLS_CERT", "cert.pem")
+
+	_, err := p.ParseSources(
+		CommandLineSource([]string{"--log-level", "debug", "--server.port", "80", "--port", "8080"}),
+		EnvironmentSource(),
+	)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Level != "debug" || opts.Server.Port != 80 || opts.Server.TLS.Cert != "cert.pem" || opts.Client == nil || opts.Client.Port != 8080 {
+		t.Errorf("Unexpected values: %+v", opts)
+	}
+
+	if option := p.FindOption("TLS Options.server.tls.cert"); option == nil || option.EnvName != "SERVER_TLS_CERT" {
+		t.Errorf("Expected namespaced option but got %v", option)
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	help := b.String()
+
+	for _, s := range []string{"Server Options:\n      --server.port", "TLS Options:\n      --server.tls.cert", "Client Options:\n      --port"} {
+		if !strings.Contains(help, s) {
+			t.Errorf("Expected %q in help message:\n%s", s, help)
+		}
+	}
+
+	var invalid struct {
+		Server int `group:"Server Options"`
+	}
+
+	if grp := NewGroup("Application Options", &invalid); grp.Error == nil {
+		t.Errorf("Expected error for group tag on non-struct field")
+	}
+}
+
+func TestAddGroup(t *testing.T) {
+	var opts struct {
+		Verbose bool `short:"v" long:"verbose" description:"Verbose"`
+	}
+
+	var logging struct {
+		Level string `long:"log-level" description:"Log level"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if grp := p.AddGroup("Logging Options", "Options of the logging package", &logging); grp.Error != nil || p.Groups[1] != grp {
+		t.Fatalf("Unexpected group: %v", grp.Error)
+	}
+
+	if _, err := p.ParseArgs([]string{"-v", "--log-level", "debug"}); err != nil || !opts.Verbose || logging.Level != "debug" {
+		t.Fatalf("Unexpected result: %v, %+v, %+v", err, opts, logging)
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if !strings.Contains(b.String(), "Logging Options:\n  Options of the logging package\n\n      --log-level") {
+		t.Errorf("Expected group description in help message:\n%s", b.String())
+	}
+}
+
+func TestMapGroup(t *testing.T) {
+	values := map[string]interface{}{
+		"host":    "localhost",
+		"port":    8080,
+		"tag":     []string{},
+		"verbose": false,
+	}
+
+	grp := NewMapGroup("Plugin Options", values)
+
+	if grp.Error != nil {
+		t.Fatalf("Unexpected error: %s", grp.Error)
+	}
+
+	grp.LongNames["port"].Description = "Port"
+
+	p := NewNamedParser("test", None, grp)
+
+	if _, err := p.ParseArgs([]string{"--port", "80", "--tag", "a", "--tag", "b", "--verbose"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if values["host"] != "localhost" || values["port"] != 80 || len(values["tag"].([]string)) != 2 || values["verbose"] != true {
+		t.Errorf("Unexpected values: %v", values)
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if help := b.String(); !strings.Contains(help, "--port       Port") || strings.Index(help, "--host") > strings.Index(help, "--port") {
+		t.Errorf("Expected sorted options in help message:\n%s", b.String())
+	}
+
+	if grp := NewMapGroup("Plugin Options", map[string]interface{}{"x": nil}); grp.Error == nil {
+		t.Errorf("Expected error for option without initial value")
+	}
+}
+
+func TestLongAliases(t *testing.T) {
+	var opts struct {
+		Color string `short:"c" long:"color" long-alias:"colour" long-alias:"colr" description:"Color"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--colour", "red"}); err != nil || opts.Color != "red" {
+		t.Fatalf("Unexpected result: %v, %q", err, opts.Color)
+	}
+
+	if p.FindOptionByLongName("colr") != p.FindOptionByLongName("color") {
+		t.Errorf("Expected alias to refer to the option")
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if strings.Contains(b.String(), "colour") {
+		t.Errorf("Unexpected alias in help message:\n%s", b.String())
+	}
+
+	p.Options |= ShowAliases
+	b.Reset()
+	p.WriteHelp(&b)
+
+	if !strings.Contains(b.String(), "-c, --color, --colour, --colr    Color") {
+		t.Errorf("Expected aliases in help message:\n%s", b.String())
+	}
+
+	var conflict struct {
+		Color  string `long:"color"`
+		Colour string `long:"colour" long-alias:"color"`
+	}
+
+	if grp := NewGroup("Application Options", &conflict); grp.Error == nil {
+		t.Errorf("Expected error for conflicting alias")
+	}
+}
+
+func TestShortAliases(t *testing.T) {
+	var opts struct {
+		Quiet bool `short:"q" short-alias:"s" long:"quiet" description:"Quiet"`
+		Usage bool `short:"u" short-alias:"?" description:"Usage"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"-s?"}); err != nil || !opts.Quiet || !opts.Usage {
+		t.Fatalf("Unexpected result: %v, %+v", err, opts)
+	}
+
+	p.Options |= ShowAliases
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	for _, s := range []string{"  -q, -s, --quiet    Quiet", "  -u, -?             Usage"} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("Expected %q in help message:\n%s", s, b.String())
+		}
+	}
+
+	var conflict struct {
+		Quiet  bool `short:"q"`
+		Silent bool `short:"s" short-alias:"q"`
+	}
+
+	if grp := NewGroup("Application Options", &conflict); grp.Error == nil {
+		t.Errorf("Expected error for conflicting alias")
+	}
+}
+
+func TestIsSet(t *testing.T) {
+	var opts struct {
+		Verbose bool   `short:"v" long:"verbose"`
+		Name    string `long:"name" env:"TEST_IS_SET_NAME"`
+		Port    int    `long:"port"`
+	}
+
+	opts.Port = 8080
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	t.Setenv("TEST_IS_SET_NAME", "name")
+
+	if _, err := p.ParseSources(CommandLineSource([]string{"-v"}), EnvironmentSource()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if p.FindOptionByLongName("port").IsSet() || !p.FindOptionByLongName("verbose").IsSet() {
+		t.Errorf("Unexpected IsSet result")
+	}
+
+	var names []string
+
+	for _, option := range p.SpecifiedOptions() {
+		names = append(names, option.LongName)
+	}
+
+	if strings.Join(names, ",") != "verbose,name" {
+		t.Errorf("Unexpected specified options: %v", names)
+	}
+}
+
+type defaultFuncOptions struct {
+	User  string `long:"user" default-func:"DefaultUser"`
+	Cache string `long:"cache"`
+
+	calls int
+}
+
+func (o *defaultFuncOptions) DefaultUser() string {
+	o.calls++
+	return "nobody"
+}
+
+func TestDefaultFunc(t *testing.T) {
+	var opts defaultFuncOptions
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	p.FindOptionByLongName("cache").DefaultFunc = func() (string, error) {
+		return "/tmp/cache", nil
+	}
+
+	if _, err := p.ParseArgs([]string{"--user", "me"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.User != "me" || opts.Cache != "/tmp/cache" || opts.calls != 0 {
+		t.Errorf("Unexpected values: %+v", opts)
+	}
+
+	opts = defaultFuncOptions{}
+	p = NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs(nil); err != nil || opts.User != "nobody" || opts.calls != 1 {
+		t.Errorf("Unexpected result: %v, %+v", err, opts)
+	}
+
+	if p.FindOptionByLongName("user").IsSet() {
+		t.Errorf("Expected option set by default function to not be set")
+	}
+
+	var invalid struct {
+		User string `long:"user" default-func:"Missing"`
+	}
+
+	if grp := NewGroup("Application Options", &invalid); grp.Error == nil {
+		t.Errorf("Expected error for missing default function")
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	var opts struct {
+		Port   int      `long:"port" default:"8080" description:"Port"`
+		Hosts  []string `long:"host" default:"localhost"`
+		Level  string   `long:"level" optional:"yes" default:"debug"`
+		Silent bool     `long:"silent"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if opts.Port != 8080 || len(opts.Hosts) != 1 || opts.Level != "" {
+		t.Fatalf("Expected options to be initialized to defaults: %+v", opts)
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if !strings.Contains(b.String(), "Port (8080)") {
+		t.Errorf("Expected default in help message:\n%s", b.String())
+	}
+
+	if _, err := p.ParseArgs([]string{"--host", "a", "--level"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Port != 8080 || strings.Join(opts.Hosts, ",") != "a" || opts.Level != "debug" {
+		t.Errorf("Unexpected values: %+v", opts)
+	}
+
+	p.ClearDefaults()
+
+	if opts.Port != 0 || len(opts.Hosts) != 1 || opts.Level != "debug" {
+		t.Errorf("Unexpected values after ClearDefaults: %+v", opts)
+	}
+
+	if err := p.ApplyDefaults(); err != nil || opts.Port != 8080 {
+		t.Errorf("Unexpected values after ApplyDefaults: %v, %+v", err, opts)
+	}
+
+	var invalid struct {
+		Port int `long:"port" default:"x"`
+	}
+
+	if grp := NewGroup("Application Options", &invalid); grp.Error == nil {
+		t.Errorf("Expected error for invalid default value")
+	}
+}
+
+func TestBoolDefaults(t *testing.T) {
+	var opts struct {
+		Color   bool `long:"color" default:"true"`
+		Verbose bool `long:"verbose" default:"false"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if !opts.Color || opts.Verbose {
+		t.Fatalf("Expected options to be initialized to defaults: %+v", opts)
+	}
+
+	if _, err := p.ParseArgs([]string{"--verbose"}); err != nil || !opts.Verbose {
+		t.Errorf("Unexpected result: %v, %+v", err, opts)
+	}
+
+	var invalid struct {
+		Verbose bool `long:"verbose" default:"maybe"`
+	}
+
+	if grp := NewGroup("Application Options", &invalid); grp.Error == nil {
+		t.Errorf("Expected error for invalid default value")
+	}
+}
+
+func TestTagNames(t *testing.T) {
+	var opts struct {
+		Port    int  `name:"port" help:"Port" long:"ignored" description:"Ignored"`
+		Verbose bool `short:"v" name:"verbose"`
+		Server  struct {
+			Host string `name:"host" choice:"a" choice:"b"`
+		} `group:"Server Options"`
+	}
+
+	grp := NewGroupWithTags("Application Options", &opts, TagNames{"long": "name", "description": "help"})
+
+	if grp.Error != nil {
+		t.Fatalf("Unexpected error: %s", grp.Error)
+	}
+
+	p := NewNamedParser("test", None, grp)
+
+	if _, err := p.ParseArgs([]string{"--port", "80", "-v", "--host", "b"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Port != 80 || !opts.Verbose || opts.Server.Host != "b" {
+		t.Errorf("Unexpected values: %+v", opts)
+	}
+
+	if option := p.FindOptionByLongName("port"); option.Description != "Port" || p.FindOptionByLongName("ignored") != nil {
+		t.Errorf("Unexpected option: %+v", option)
+	}
+
+	if _, err := p.ParseArgs([]string{"--host", "c"}); err == nil {
+		t.Errorf("Expected error for invalid choice")
+	}
+}
+
+func TestCompactTag(t *testing.T) {
+	var opts struct {
+		Port    int      `flags:"-p, --port, desc='Port to listen on, or 0', default=8080"`
+		Verbose []bool   `flags:"-v,--verbose,max-occurrences=2"`
+		Hosts   []string `flags:"--host" choice:"a" choice:"b"`
+		Server  struct {
+			Name string `flags:"--name"`
+		} `flags:"group='Server Options', ns=server"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if err := p.Groups[0].Error; err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if option := p.FindOptionByShortName('p'); option == nil || option.LongName != "port" || option.Description != "Port to listen on, or 0" || opts.Port != 8080 {
+		t.Errorf("Unexpected option: %+v", option)
+	}
+
+	if _, err := p.ParseArgs([]string{"-vv", "--host", "a", "--server.name", "x"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(opts.Verbose) != 2 || opts.Hosts[0] != "a" || opts.Server.Name != "x" {
+		t.Errorf("Unexpected values: %+v", opts)
+	}
+
+	if _, err := p.ParseArgs([]string{"-vvv"}); err == nil {
+		t.Errorf("Expected error for too many occurrences")
+	}
+
+	var invalid struct {
+		Port int `flags:"--port, desc='Port"`
+	}
+
+	if grp := NewGroup("Application Options", &invalid); grp.Error == nil {
+		t.Errorf("Expected error for invalid compact tag")
+	}
+}
+
+func TestStructFieldsCache(t *testing.T) {
+	type options struct {
+		Verbose bool   `short:"v" opt:"q"`
+		Name    string `long:"name" flags:"--unterminated, desc='"`
+	}
+
+	for i := 0; i < 2; i++ {
+		grp := NewGroup("Application Options", &options{})
+
+		if grp.Error == nil || !strings.Contains(grp.Error.Error(), "unterminated quote") {
+			t.Fatalf("Expected an unterminated quote error but got %v", grp.Error)
+		}
+	}
+
+	type valid struct {
+		Verbose bool `short:"v" opt:"q"`
+	}
+
+	grp := NewGroup("Application Options", &valid{})
+
+	if grp.Error != nil || grp.ShortNames['v'] == nil {
+		t.Fatalf("Expected option -v but got %v", grp.Error)
+	}
+
+	grp = NewGroupWithTags("Application Options", &valid{}, TagNames{"short": "opt"})
+
+	if grp.Error != nil || grp.ShortNames['q'] == nil || grp.ShortNames['v'] != nil {
+		t.Fatalf("Expected only option -q but got %v", grp.Error)
+	}
+}
+
+func BenchmarkNewGroup(b *testing.B) {
+	type options struct {
+		Verbose []bool            `short:"v" long:"verbose" description:"Show verbose debug information"`
+		Port    int               `flags:"-p, --port, desc='Port to listen on', default=8080"`
+		Hosts   []string          `long:"host" env:"HOSTS" env-delim:","`
+		Labels  map[string]string `long:"label" value-name:"KEY:VALUE"`
+	}
+
+	for i := 0; i < b.N; i++ {
+		if grp := NewGroup("Application Options", &options{}); grp.Error != nil {
+			b.Fatal(grp.Error)
+		}
+	}
+}
+
+// BenchmarkManyOptions measures the memory used by the options of a large
+// generated struct (see the B/op and allocs/op of -benchmem).
+func BenchmarkManyOptions(b *testing.B) {
+	fields := make([]reflect.StructField, 500)
+
+	for i := range fields {
+		fields[i] = reflect.StructField{
+			Name: "Field" + strconv.Itoa(i),
+			Type: reflect.TypeOf(""),
+			Tag:  reflect.StructTag(`long:"field-` + strconv.Itoa(i) + `" description:"The value of the field"`),
+		}
+	}
+
+	tp := reflect.StructOf(fields)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if grp := NewGroup("Application Options", reflect.New(tp).Interface()); grp.Error != nil {
+			b.Fatal(grp.Error)
+		}
+	}
+}
+
+func TestInvalidShortName(t *testing.T) {
+	var opts struct {
+		Replacement bool `short:"�"`
+		Verbose     bool `short:"v"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	_, err := p.ParseArgs([]string{"-v\xff"})
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrUnknownFlag || e.Message != "unknown flag `\xff'" {
+		t.Errorf("Expected an unknown flag error but got %v", err)
+	}
+
+	if opts.Replacement {
+		t.Errorf("Expected an invalid byte not to match the replacement character")
+	}
+}
+
+func TestCounter(t *testing.T) {
+	type options struct {
+		Level int    `short:"v" long:"verbose" description:"More output" decrement-short:"q" decrement-long:"quiet" decrement-description:"Less output" env:"LEVEL"`
+		Name  string `long:"name"`
+	}
+
+	tests := []struct {
+		args  []string
+		level int
+		args2 []string
+	}{
+		{[]string{}, 0, nil},
+		{[]string{"-vvv", "-q"}, 2, []string{"--verbose", "--verbose"}},
+		{[]string{"-qq"}, -2, []string{"--quiet", "--quiet"}},
+		{[]string{"--quiet", "--verbose"}, 0, nil},
+	}
+
+	for _, test := range tests {
+		var opts options
+
+		p := NewParser(&opts, None)
+
+		if _, err := p.ParseArgs(test.args); err != nil {
+			t.Fatalf("Unexpected error for %v: %s", test.args, err)
+		}
+
+		if opts.Level != test.level {
+			t.Errorf("Expected level %d for %v but got %d", test.level, test.args, opts.Level)
+		}
+
+		if args := p.MarshalArgs(); !reflect.DeepEqual(args, test.args2) {
+			t.Errorf("Expected arguments %v for %v but got %v", test.args2, test.args, args)
+		}
+	}
+
+	var opts options
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	p.LookupEnv = func(name string) (string, bool) {
+		return map[string]string{"LEVEL": "3", "QUIET": "1"}[name], name == "LEVEL"
+	}
+
+	if _, err := p.ParseSources(EnvironmentSource()); err != nil || opts.Level != 3 {
+		t.Errorf("Expected the level to be set by the environment but got %d (%v)", opts.Level, err)
+	}
+
+	if values := p.Values()["Application Options"].(map[string]interface{}); len(values) != 2 || values["verbose"] != 3 {
+		t.Errorf("Expected the decrement option not to be included in the values but got %v", values)
+	}
+
+	if _, err := p.ParseArgs([]string{"--verbose=2"}); err == nil {
+		t.Errorf("Expected an error for the argument of a counter option")
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if help := b.String(); !strings.Contains(help, "  -q, --quiet      Less output\n") {
+		t.Errorf("Expected the decrement option in the help message:\n%s", help)
+	}
+
+	var invalid struct {
+		Level string `short:"v" counter:"true"`
+	}
+
+	if grp := NewGroup("Application Options", &invalid); grp.Error == nil {
+		t.Errorf("Expected error for a counter which is not an integer")
+	}
+}
@@ -9,79 +9,307 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"text/template"
 	"unicode/utf8"
 )
 
-func (p *Parser) maxLongLen() (int, bool) {
-	maxlonglen := 0
+// LongDescriber is implemented by data structs of groups, or structs
+// embedded in them, which provide long descriptions of their options (see
+// Option.LongDescription), such that lengthy help texts can be written in Go
+// code instead of struct tags. LongDescription is called with the name of
+// the struct field of each option when the group is created, and returns ""
+// for options without a long description. For example:
+//
+//	func (o *Options) LongDescription(field string) string {
+//		switch field {
+//		case "Format":
+//			return formatHelp
+//		}
+//
+//		return ""
+//	}
+type LongDescriber interface {
+	LongDescription(field string) string
+}
+
+// HelpLayout configures the layout of the options in the help message (see
+// Parser.HelpLayout), such that help messages can follow the style guide of
+// an organization. The zero value selects the default layout.
+type HelpLayout struct {
+	// The number of spaces before the options and the descriptions of
+	// groups, two when zero
+	Indent int
+
+	// If not zero, the column at which the descriptions of options start,
+	// instead of a column computed from the longest names of options. The
+	// descriptions of options whose names do not fit start on the next line
+	OptionWidth int
+
+	// If not zero, the maximum width of the descriptions of options, which
+	// are otherwise wrapped at the width of the help message
+	MaxDescriptionWidth int
+
+	// Show the short and long names of options on lines of their own,
+	// instead of on one line separated by commas
+	Stacked bool
+}
+
+// The minimum width at which descriptions of options are wrapped
+const minDescriptionWidth = 10
+
+// helpIndent returns the indentation of the options in the help message.
+func (p *Parser) helpIndent() string {
+	if p.HelpLayout.Indent > 0 {
+		return strings.Repeat(" ", p.HelpLayout.Indent)
+	}
+
+	return "  "
+}
+
+// helpColumn returns the column at which the descriptions of options start
+// in the help message, and whether any option has a short name.
+func (p *Parser) helpColumn() (int, bool) {
 	hasshort := false
 
-	for _, grp := range p.Groups {
+	for _, grp := range p.groups() {
 		for _, info := range grp.Options {
 			if info.ShortName != 0 {
 				hasshort = true
 			}
+		}
+	}
+
+	if p.HelpLayout.OptionWidth > 0 {
+		return p.HelpLayout.OptionWidth, hasshort
+	}
 
-			l := utf8.RuneCountInString(info.LongName)
+	maxlen := 0
 
-			if l > maxlonglen {
-				maxlonglen = l
+	for _, grp := range p.groups() {
+		for _, info := range grp.Options {
+			for _, line := range p.helpLines(info, hasshort) {
+				if l := utf8.RuneCountInString(line); l > maxlen {
+					maxlen = l
+				}
 			}
 		}
 	}
 
-	return maxlonglen, hasshort
+	return len(p.helpIndent()) + maxlen + 4, hasshort
 }
 
-func (p *Parser) writeHelpOption(writer *bufio.Writer, option *Option, maxlen int, hasshort bool, termcol int) {
-	if option.ShortName != 0 {
-		writer.WriteString("  -")
-		writer.WriteRune(option.ShortName)
-	} else if hasshort {
-		writer.WriteString("    ")
+// helpLines returns the lines of the names of the option in the help
+// message, without indentation. The long names are aligned after the
+// space of the short names when any option has a short name, unless the
+// names are stacked (see HelpLayout).
+func (p *Parser) helpLines(option *Option, hasshort bool) []string {
+	names := p.helpNames(option)
+
+	if p.HelpLayout.Stacked {
+		var ret []string
+
+		if option.ShortName != 0 {
+			ret = append(ret, "-"+string(option.ShortName))
+		}
+
+		if names != "" {
+			ret = append(ret, strings.Split(names, ", ")...)
+		}
+
+		return ret
 	}
 
-	written := 0
-	prelen := 4
+	switch {
+	case option.ShortName != 0 && names != "":
+		return []string{"-" + string(option.ShortName) + ", " + names}
+	case option.ShortName != 0:
+		return []string{"-" + string(option.ShortName)}
+	case hasshort:
+		return []string{"    " + names}
+	}
+
+	return []string{names}
+}
+
+// helpNames returns the names of the option which are shown after its short
+// name in the help message: its long name, preceded by its short aliases and
+// followed by its long aliases if the ShowAliases option is set.
+func (p *Parser) helpNames(option *Option) string {
+	var names []string
+	aliases := (p.Options & ShowAliases) != None
+
+	if aliases {
+		for _, alias := range option.ShortAliases {
+			names = append(names, "-"+string(alias))
+		}
+	}
 
 	if option.LongName != "" {
-		if option.ShortName != 0 {
-			writer.WriteString(", ")
-		} else {
-			writer.WriteString("  ")
+		names = append(names, "--"+option.LongName)
+
+		if aliases {
+			for _, alias := range option.LongAliases {
+				names = append(names, "--"+alias)
+			}
 		}
+	}
 
-		fmt.Fprintf(writer, "--%s", option.LongName)
-		written = utf8.RuneCountInString(option.LongName)
+	return strings.Join(names, ", ")
+}
 
-		prelen += written + 4
+func (p *Parser) writeHelpOption(writer *bufio.Writer, option *Option, hasshort bool, col int, width int) {
+	indent := p.helpIndent()
+	written := 0
+
+	for i, line := range p.helpLines(option, hasshort) {
+		if i != 0 {
+			writer.WriteString("\n")
+		}
+
+		writer.WriteString(indent + line)
+		written = len(indent) + utf8.RuneCountInString(line)
 	}
 
-	if option.Description != "" {
-		if written < maxlen {
-			dw := maxlen - written
+	if option.Description != "" || option.LongDescription != "" {
+		// Descriptions are separated from the names by at least two spaces
+		if written+2 > col {
+			writer.WriteString("\n")
+			written = 0
+		}
+
+		writer.WriteString(strings.Repeat(" ", col-written))
+
+		// The default and environment variable are not repeated when the
+		// description refers to them
+		desc, data := option.expandDescription(option.Description)
 
-			writer.WriteString(strings.Repeat(" ", dw))
-			prelen += dw
+		if def := option.helpDefault(); def != "" && !data.usedDefault {
+			desc = fmt.Sprintf("%s (%v)", desc, def)
 		}
 
-		def := convertToString(option.value, option.options)
-		var desc string
+		if rng := option.rangeDescription(); rng != "" {
+			desc += fmt.Sprintf(" (%s)", rng)
+		}
 
-		if def != "" {
-			desc = fmt.Sprintf("%s (%v)", option.Description, def)
-		} else {
-			desc = option.Description
+		if option.EnvName != "" && !data.usedEnv {
+			desc += fmt.Sprintf(" [$%s]", option.EnvName)
 		}
 
-		writer.WriteString(wrapText(desc,
-			termcol-prelen,
-			strings.Repeat(" ", prelen)))
+		writer.WriteString(wrapText(desc, width, strings.Repeat(" ", col)))
+	}
+
+	if option.LongDescription != "" {
+		long, _ := option.expandDescription(option.LongDescription)
+		writeLongDescription(writer, long, option.Description != "", col, width)
 	}
 
 	writer.WriteString("\n")
 }
 
+// helpDefault returns the default value of the option as shown in the help
+// message, which is its current value unless it is masked.
+func (option *Option) helpDefault() string {
+	// The default of a counter option is not repeated by its decrement
+	// option
+	if option.sharesField() {
+		return ""
+	}
+
+	switch option.DefaultMask {
+	case "":
+		if def := convertToString(option.value, option.options); def != "" {
+			return option.argument(def)
+		}
+	case "-":
+	default:
+		return option.DefaultMask
+	}
+
+	return ""
+}
+
+// descriptionData is the data of the templates of descriptions (see
+// expandDescription). Its methods record which placeholders are used.
+type descriptionData struct {
+	option *Option
+
+	usedDefault bool
+	usedEnv     bool
+}
+
+// Default returns the default value of the option, as shown in the help
+// message.
+func (d *descriptionData) Default() string {
+	d.usedDefault = true
+	return d.option.helpDefault()
+}
+
+// Env returns the name of the environment variable of the option.
+func (d *descriptionData) Env() string {
+	d.usedEnv = true
+	return d.option.EnvName
+}
+
+// Name returns the flag of the option, e.g. --port.
+func (d *descriptionData) Name() string {
+	return d.option.synopsis()
+}
+
+// Choices returns the choices of the option, separated by commas.
+func (d *descriptionData) Choices() string {
+	return strings.Join(d.option.Choices, ", ")
+}
+
+// expandDescription expands the placeholders of a description of the
+// option, such as {{.Default}} and {{.Env}}. Descriptions with invalid
+// templates are returned unchanged.
+func (option *Option) expandDescription(text string) (string, *descriptionData) {
+	data := &descriptionData{option: option}
+
+	if !strings.Contains(text, "{{") {
+		return text, data
+	}
+
+	tmpl, err := template.New("description").Parse(text)
+
+	if err != nil {
+		return text, data
+	}
+
+	var b strings.Builder
+
+	if err := tmpl.Execute(&b, data); err != nil {
+		return text, &descriptionData{option: option}
+	}
+
+	return b.String(), data
+}
+
+// description returns the description of the option with its placeholders
+// expanded.
+func (option *Option) description() string {
+	ret, _ := option.expandDescription(option.Description)
+	return ret
+}
+
+// writeLongDescription writes the paragraphs of a long description indented
+// at the column of the description, separated by empty lines. The first
+// paragraph starts on the line of the flag when it has no description.
+func writeLongDescription(writer *bufio.Writer, long string, below bool, prelen int, width int) {
+	prefix := strings.Repeat(" ", prelen)
+
+	for i, paragraph := range strings.Split(strings.TrimSpace(long), "\n\n") {
+		switch {
+		case i != 0:
+			writer.WriteString("\n\n" + prefix)
+		case below:
+			writer.WriteString("\n" + prefix)
+		}
+
+		writer.WriteString(wrapText(strings.Join(strings.Fields(paragraph), " "), width, prefix))
+	}
+}
+
 // WriteHelp writes a help message containing all the possible options and
 // their descriptions to the provided writer. Note that the HelpFlag parser
 // option provides a convenient way to add a -h/--help option group to the
@@ -102,21 +330,59 @@ func (p *Parser) WriteHelp(writer io.Writer) {
 			fmt.Fprintf(wr, " %s", p.Usage)
 		}
 
+		for _, grp := range p.groups() {
+			for _, option := range grp.Options {
+				if option.Required {
+					fmt.Fprintf(wr, " %s", option.usage())
+				}
+			}
+		}
+
+		for _, set := range p.atLeastOneOfSets() {
+			fmt.Fprintf(wr, " %s", set.synopsis())
+		}
+
 		wr.WriteString("\n")
 	}
 
-	maxlonglen, hasshort := p.maxLongLen()
-	maxlen := maxlonglen + 4
+	col, hasshort := p.helpColumn()
+	indent := p.helpIndent()
+
+	termcol := p.HelpWidth
+
+	if termcol == 0 {
+		termcol = getTerminalColumns()
+	}
+
+	// The width is unknown when the standard input is not a terminal, in
+	// which case the help message is as wide as on windows
+	if termcol == 0 {
+		termcol = 80
+	}
+
+	width := termcol - col
+
+	if max := p.HelpLayout.MaxDescriptionWidth; max > 0 && max < width {
+		width = max
+	}
 
-	termcol := getTerminalColumns()
+	// Descriptions are wrapped at a minimum width when the layout leaves
+	// hardly any room for them, and overflow the width of the help message
+	if width < minDescriptionWidth {
+		width = minDescriptionWidth
+	}
 
-	for _, grp := range p.Groups {
+	for _, grp := range p.groups() {
 		wr.WriteString("\n")
 
 		fmt.Fprintf(wr, "%s:\n", grp.Name)
 
+		if grp.Description != "" {
+			fmt.Fprintf(wr, "%s%s\n\n", indent, wrapText(grp.Description, max(termcol-len(indent), minDescriptionWidth), indent))
+		}
+
 		for _, info := range grp.Options {
-			p.writeHelpOption(wr, info, maxlen, hasshort, termcol)
+			p.writeHelpOption(wr, info, hasshort, col, width)
 		}
 	}
 
@@ -0,0 +1,258 @@
+package flags
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDefaultMask(t *testing.T) {
+	var opts struct {
+		Editor string `long:"editor" description:"Editor" default:"/usr/bin/vi" default-mask:"$EDITOR or vi"`
+		Home   string `long:"home" description:"Home" default:"/home/user" default-mask:"-"`
+		Port   int    `long:"port" description:"Port" default:"80"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	help := b.String()
+
+	for _, s := range []string{"Editor ($EDITOR or vi)\n", "Home\n", "Port (80)\n"} {
+		if !strings.Contains(help, s) {
+			t.Errorf("Expected %q in help message:\n%s", s, help)
+		}
+	}
+
+	if opts.Editor != "/usr/bin/vi" {
+		t.Errorf("Expected the actual default to be used but got %q", opts.Editor)
+	}
+}
+
+func TestHelpWidth(t *testing.T) {
+	var opts struct {
+		Verbose bool           `short:"v" long:"verbose" description:"Show verbose debug information, including the arguments of every command which is run"`
+		Labels  map[string]int `long:"label" description:"Labels"`
+	}
+
+	opts.Labels = map[string]int{"c": 3, "a": 1, "b": 2, "d": 4}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	p.HelpWidth = 40
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	help := b.String()
+
+	for _, line := range strings.Split(help, "\n") {
+		if len(line) > 40 {
+			t.Errorf("Expected lines of at most 40 columns but got %q", line)
+		}
+	}
+
+	if !strings.Contains(help, "({1, 2, 3, 4})") {
+		t.Errorf("Expected the map values to be sorted by key in help message:\n%s", help)
+	}
+
+	var again bytes.Buffer
+	p.WriteHelp(&again)
+
+	if again.String() != help {
+		t.Errorf("Expected the help message to be deterministic but got:\n%s\nand:\n%s", help, again.String())
+	}
+
+	// A width narrower than the column of the descriptions
+	var narrow struct {
+		Output string `long:"output-file-of-the-report" description:"Output file of the report"`
+	}
+
+	grp := NewGroup("Application Options", &narrow)
+	grp.Description = "Options of the application"
+
+	p = NewNamedParser("test", None, grp)
+	p.HelpWidth = 20
+
+	b.Reset()
+	p.WriteHelp(&b)
+
+	if help := b.String(); !strings.Contains(help, "Output") || !strings.Contains(help, "the report") {
+		t.Errorf("Expected the descriptions in the help message but got:\n%s", help)
+	}
+}
+
+func TestHelpLayout(t *testing.T) {
+	var opts struct {
+		Verbose bool   `short:"v" long:"verbose" description:"Show verbose debug information"`
+		Output  string `short:"o" long:"output-file" description:"Output file"`
+		Quiet   bool   `short:"q" description:"Quiet"`
+		Name    string `long:"name" description:"Name"`
+	}
+
+	tests := []struct {
+		layout   HelpLayout
+		expected string
+	}{
+		{
+			HelpLayout{},
+			`  -v, --verbose        Show verbose debug information
+  -o, --output-file    Output file
+  -q                   Quiet
+      --name           Name
+`,
+		},
+		{
+			HelpLayout{Indent: 4, OptionWidth: 20, MaxDescriptionWidth: 20},
+			`    -v, --verbose   Show verbose debug
+                    information
+    -o, --output-file
+                    Output file
+    -q              Quiet
+        --name      Name
+`,
+		},
+		{
+			HelpLayout{Stacked: true},
+			`  -v
+  --verbose        Show verbose debug information
+  -o
+  --output-file    Output file
+  -q               Quiet
+  --name           Name
+`,
+		},
+	}
+
+	for _, test := range tests {
+		p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+		p.HelpWidth = 60
+		p.HelpLayout = test.layout
+
+		var b bytes.Buffer
+		p.WriteHelp(&b)
+
+		expected := "Usage:\n  test [OPTIONS]\n\nApplication Options:\n" + test.expected
+
+		if b.String() != expected {
+			t.Errorf("Expected help for %+v:\n%s\nbut got:\n%s", test.layout, expected, b.String())
+		}
+	}
+
+	// Layouts leaving no room for descriptions still show them
+	for _, layout := range []HelpLayout{{OptionWidth: 100}, {Indent: 100}, {MaxDescriptionWidth: 1}} {
+		p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+		p.HelpWidth = 60
+		p.HelpLayout = layout
+
+		var b bytes.Buffer
+		p.WriteHelp(&b)
+
+		if help := b.String(); !strings.Contains(help, "Quiet") || !strings.Contains(help, "--name") {
+			t.Errorf("Expected the options in the help for %+v but got:\n%s", layout, help)
+		}
+	}
+}
+
+type longDescriptionOptions struct {
+	Format string `short:"f" long:"format" description:"Output format"`
+	Quiet  bool   `short:"q" long:"quiet"`
+	Name   string `long:"name" description:"Name"`
+}
+
+func (o *longDescriptionOptions) LongDescription(field string) string {
+	switch field {
+	case "Format":
+		return `The format is either text, which is meant to be read by
+		humans, or json.
+
+		The json format is stable.`
+	case "Quiet":
+		return "Only show errors."
+	}
+
+	return ""
+}
+
+func TestLongDescription(t *testing.T) {
+	var opts longDescriptionOptions
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	p.HelpWidth = 60
+
+	if long := p.FindOptionByLongName("format").LongDescription; !strings.HasPrefix(long, "The format") {
+		t.Errorf("Expected the long description of the method but got %q", long)
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	expected := `Usage:
+  test [OPTIONS]
+
+Application Options:
+  -f, --format    Output format
+                  The format is either text, which is meant
+                  to be read by humans, or json.
+
+                  The json format is stable.
+  -q, --quiet     Only show errors.
+      --name      Name
+`
+
+	if b.String() != expected {
+		t.Errorf("Expected help:\n%s\nbut got:\n%s", expected, b.String())
+	}
+}
+
+func TestTemplatedDescription(t *testing.T) {
+	var opts struct {
+		Port   int    `long:"port" description:"Port, defaults to {{.Default}} (env {{.Env}})" default:"8080" env:"PORT"`
+		Format string `long:"format" description:"Format, one of {{.Choices}}" choice:"text" choice:"json" default:"text"`
+		Name   string `long:"name" description:"Name for {{.Name}}" env:"NAME"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	expected := `Usage:
+  test [OPTIONS]
+
+Application Options:
+  --port      Port, defaults to 8080 (env PORT)
+  --format    Format, one of text, json (text)
+  --name      Name for --name [$NAME]
+`
+
+	if b.String() != expected {
+		t.Errorf("Expected help:\n%s\nbut got:\n%s", expected, b.String())
+	}
+
+	if desc := p.Spec().Groups[0].Options[0].Description; desc != "Port, defaults to 8080 (env PORT)" {
+		t.Errorf("Expected the expanded description in the spec but got %q", desc)
+	}
+
+	// Descriptions which are not templates are shown literally
+	var literal struct {
+		Port  int    `long:"port" description:"Port {{.Default"`
+		Label string `long:"label" description:"Label, e.g. {{\"key\": 1}} or {{.Key}}"`
+	}
+
+	p = NewNamedParser("test", None, NewGroup("Application Options", &literal))
+
+	if err := p.Groups[0].Error; err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	b.Reset()
+	p.WriteHelp(&b)
+
+	for _, s := range []string{"Port {{.Default (0)", "Label, e.g. {{\"key\": 1}} or {{.Key}}"} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("Expected %q in help message:\n%s", s, b.String())
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IniError contains location information on where an error occured while
+// parsing an ini file.
+type IniError struct {
+	// The error message
+	Message string
+
+	// The filename of the file in which the error occurred
+	File string
+
+	// The line number at which the error occurred
+	LineNumber uint
+
+	// The underlying error (e.g. the *Error of the option), if any
+	Err error
+}
+
+// Get the error message including the location of the error.
+func (e *IniError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("%d: %s", e.LineNumber, e.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", e.File, e.LineNumber, e.Message)
+}
+
+// Unwrap returns the underlying error, if any.
+func (e *IniError) Unwrap() error {
+	return e.Err
+}
+
+// IniOptions for writing ini files
+type IniOptions uint
+
+const (
+	// No options
+	IniNone IniOptions = 0
+
+	// Include option descriptions as comments
+	IniIncludeComments = 1 << iota
+
+	// Include options which still have their initial (default) value
+	IniIncludeDefaults
+
+	// Include options which still have their initial (default) value as
+	// comments. This takes precedence over IniIncludeDefaults
+	IniCommentDefaults
+
+	// Include the origin of option values (see Option.Origin) as comments
+	IniIncludeOrigins
+
+	// Write the values of secret options (see Option.Secret) as ***
+	IniMaskSecrets
+
+	// A convenient default set of options
+	IniDefault = IniIncludeComments
+)
+
+// IniParser is a utility to read options from an ini file into the option
+// groups of a parser. Sections in the ini file correspond to groups (by
+// name, case insensitive) and keys correspond to the long names of the
+// options in the group. Keys which appear before the first section are
+// looked up in all the groups of the parser. For example:
+//
+//	; Options of the "Application Options" group
+//	[Application Options]
+//	verbose = true
+//	output = "/tmp/out.txt"
+//
+// Keys of options which can be specified multiple times (e.g. slices) can
+// be repeated. Boolean options accept the values true and false. Values
+// can be quoted using Go string syntax. As with Parser.ParseYAML, the values
+// of options which were already specified on the command line are not
+// changed.
+type IniParser struct {
+	parser *Parser
+}
+
+// NewIniParser creates a new ini parser for a given Parser.
+func NewIniParser(p *Parser) *IniParser {
+	return &IniParser{
+		parser: p,
+	}
+}
+
+// IniParse is a convenience function to parse command line options with
+// default settings from an ini file. The provided data is a pointer to a
+// struct representing the default option group (named
+// "Application Options"). For more control, use flags.NewParser.
+func IniParse(filename string, data interface{}) error {
+	p := NewParser(data, Default)
+	return NewIniParser(p).ParseFile(filename)
+}
+
+// ParseFile parses options from an ini file. See IniParser.Parse for more
+// information.
+func (i *IniParser) ParseFile(filename string) error {
+	fp, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer fp.Close()
+
+	return i.parse(fp, filename)
+}
+
+// Parse parses options from an ini formatted reader and sets the values of
+// the corresponding options. The returned error is of type *IniError when
+// the ini data could not be parsed or an option could not be set.
+func (i *IniParser) Parse(reader io.Reader) error {
+	return i.parse(reader, "")
+}
+
+func (i *IniParser) parse(reader io.Reader, filename string) error {
+	scanner := bufio.NewScanner(reader)
+
+	groups := i.parser.groups()
+	section := ""
+	l := newConfigLayer(filename)
+
+	var lineno uint
+
+	iniError := func(format string, a ...interface{}) error {
+		return &IniError{
+			Message:    fmt.Sprintf(format, a...),
+			File:       filename,
+			LineNumber: lineno,
+		}
+	}
+
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return iniError("malformed section header")
+			}
+
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			group := i.parser.groupByName(name)
+
+			if group == nil {
+				return iniError("unknown group `%s'", name)
+			}
+
+			groups = []*Group{group}
+			section = group.Name + "."
+			continue
+		}
+
+		pos := strings.Index(line, "=")
+
+		if pos < 0 {
+			return iniError("malformed key=value (%s)", line)
+		}
+
+		key := strings.TrimSpace(line[:pos])
+		value := strings.TrimSpace(line[pos+1:])
+
+		if len(value) > 0 && value[0] == '"' {
+			unquoted, err := strconv.Unquote(value)
+
+			if err != nil {
+				return iniError("invalid quoted value (%s)", value)
+			}
+
+			value = unquoted
+		}
+
+		var option *Option
+
+		for _, grp := range groups {
+			if option = grp.LongNames[key]; option != nil {
+				break
+			}
+		}
+
+		if option == nil {
+			return iniError("unknown option `%s'", key)
+		}
+
+		origin := Origin{
+			Type: OriginFile,
+			File: filename,
+			Name: section + key,
+		}
+
+		if !i.parser.claimOption(option, l, origin) {
+			continue
+		}
+
+		if err := i.parser.setConfigValue(option, value); err != nil {
+			return &IniError{
+				Message:    err.Error(),
+				File:       filename,
+				LineNumber: lineno,
+				Err:        err,
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// WriteFile writes the current values of the options of the parser to an
+// ini file. See IniParser.Write for more information.
+func (i *IniParser) WriteFile(filename string, options IniOptions) error {
+	fp, err := os.Create(filename)
+
+	if err != nil {
+		return err
+	}
+
+	if err := i.Write(fp, options); err != nil {
+		fp.Close()
+		return err
+	}
+
+	return fp.Close()
+}
+
+// Write writes the current values of the options of the parser in ini
+// format to the provided writer, such that they can be read back using
+// IniParser.Parse. Each group is written as a section. Options which still
+// have the value they had when their group was created are omitted, unless
+// IniIncludeDefaults or IniCommentDefaults is specified. The latter is
+// useful to generate a documented template configuration file, for example
+// from the initial values of a freshly created parser. Options without a
+// long name and function options are never written. The error of writing
+// to the writer, if any, is returned.
+func (i *IniParser) Write(writer io.Writer, options IniOptions) error {
+	wr := bufio.NewWriter(writer)
+	first := true
+
+	for _, grp := range i.parser.groups() {
+		var written bool
+
+		for _, option := range grp.Options {
+			if option.LongName == "" || option.isFunc() || option.sharesField() {
+				continue
+			}
+
+			prefix := ""
+
+			if option.isInitial() {
+				if (options & IniCommentDefaults) != IniNone {
+					prefix = "; "
+				} else if (options & IniIncludeDefaults) == IniNone {
+					continue
+				}
+			}
+
+			values := option.iniValues()
+
+			if len(values) == 0 {
+				continue
+			}
+
+			if option.Secret && (options&IniMaskSecrets) != IniNone {
+				for i := range values {
+					values[i] = "***"
+				}
+			}
+
+			if !written {
+				if !first {
+					wr.WriteString("\n")
+				}
+
+				fmt.Fprintf(wr, "[%s]\n", grp.Name)
+
+				first = false
+				written = true
+			} else if (options & (IniIncludeComments | IniIncludeOrigins)) != IniNone {
+				wr.WriteString("\n")
+			}
+
+			if (options&IniIncludeComments) != IniNone && option.Description != "" {
+				fmt.Fprintf(wr, "; %s\n", option.description())
+			}
+
+			if (options & IniIncludeOrigins) != IniNone {
+				fmt.Fprintf(wr, "; origin: %s\n", option.origin)
+			}
+
+			for _, value := range values {
+				fmt.Fprintf(wr, "%s%s = %s\n", prefix, option.LongName, value)
+			}
+		}
+	}
+
+	return wr.Flush()
+}
+
+// iniValue formats a single value for an ini file, quoting it when it would
+// otherwise not be read back verbatim.
+func iniValue(val reflect.Value, options *fieldTags) string {
+	s := convertToString(val, options)
+
+	if s != strings.TrimSpace(s) || strings.HasPrefix(s, "\"") || strings.ContainsAny(s, "\n\r") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// iniValues returns the values of the option, one for each key = value
+// line written to the ini file.
+func (option *Option) iniValues() []string {
+	var ret []string
+	val := option.value
+
+	switch val.Kind() {
+	case reflect.Bool:
+		ret = append(ret, strconv.FormatBool(val.Bool()))
+	case reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			if val.Index(i).Kind() == reflect.Bool {
+				ret = append(ret, strconv.FormatBool(val.Index(i).Bool()))
+			} else {
+				ret = append(ret, iniValue(val.Index(i), option.options))
+			}
+		}
+	case reflect.Map:
+		var keys []string
+		values := make(map[string]string)
+
+		for _, key := range val.MapKeys() {
+			k := convertToString(key, option.options)
+
+			keys = append(keys, k)
+			values[k] = convertToString(val.MapIndex(key), option.options)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			ret = append(ret, iniValue(reflect.ValueOf(k+":"+values[k]), nil))
+		}
+	default:
+		ret = append(ret, iniValue(val, option.options))
+	}
+
+	return ret
+}
+
+// groupByName returns the group of the parser with the given name (case
+// insensitive), or nil if there is no such group.
+func (p *Parser) groupByName(name string) *Group {
+	for _, grp := range p.groups() {
+		if strings.EqualFold(grp.Name, name) {
+			return grp
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package flags
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+type iniOptions struct {
+	Verbose []bool            `short:"v" long:"verbose" description:"Show verbose debug information"`
+	Enabled bool              `long:"enabled" description:"Enabled"`
+	Name    string            `long:"name" description:"Name"`
+	Values  []int             `long:"value" description:"Values"`
+	Map     map[string]string `long:"map" description:"Map"`
+}
+
+type iniOtherOptions struct {
+	Level int `long:"level" description:"Level"`
+}
+
+func newIniParser(opts *iniOptions, other *iniOtherOptions) *IniParser {
+	p := NewNamedParser("test", None,
+		NewGroup("Application Options", opts),
+		NewGroup("Other Options", other))
+
+	return NewIniParser(p)
+}
+
+func TestIniParse(t *testing.T) {
+	var opts iniOptions
+	var other iniOtherOptions
+
+	opts.Enabled = true
+
+	ini := `
+; comment
+name = "hello world"
+
+[application options]
+verbose = true
+verbose = true
+enabled = false
+value = 1
+value = 2
+map = a:b
+
+# other comment
+[Other Options]
+level = 3
+`
+
+	if err := newIniParser(&opts, &other).Parse(strings.NewReader(ini)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Name != "hello world" {
+		t.Errorf("Expected name to be %q but got %q", "hello world", opts.Name)
+	}
+
+	if len(opts.Verbose) != 2 {
+		t.Errorf("Expected verbose to be set twice but got %v", opts.Verbose)
+	}
+
+	if opts.Enabled {
+		t.Errorf("Expected enabled to be false")
+	}
+
+	if len(opts.Values) != 2 || opts.Values[0] != 1 || opts.Values[1] != 2 {
+		t.Errorf("Expected values [1 2] but got %v", opts.Values)
+	}
+
+	if opts.Map["a"] != "b" {
+		t.Errorf("Expected map value b but got %v", opts.Map)
+	}
+
+	if other.Level != 3 {
+		t.Errorf("Expected level 3 but got %d", other.Level)
+	}
+}
+
+func TestIniParseFalse(t *testing.T) {
+	values := map[string]interface{}{"enabled": true}
+	p := NewNamedParser("test", None, NewMapGroup("Application Options", values))
+
+	var validated []string
+
+	p.FindOptionByLongName("enabled").Validator = func(value string) error {
+		validated = append(validated, value)
+		return nil
+	}
+
+	var messages []string
+
+	p.Trace = func(message string) {
+		messages = append(messages, message)
+	}
+
+	if err := NewIniParser(p).Parse(strings.NewReader("enabled = off\n")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if values["enabled"] != false || strings.Join(validated, ",") != "false" {
+		t.Errorf("Expected the option to be set to false but got %v (validated %v)", values["enabled"], validated)
+	}
+
+	if len(messages) == 0 || !strings.HasPrefix(messages[len(messages)-1], "set --enabled to") {
+		t.Errorf("Expected the option to be traced but got %q", messages)
+	}
+}
+
+func TestIniParseErrors(t *testing.T) {
+	tests := []struct {
+		ini     string
+		message string
+	}{
+		{"[Unknown]\nname = a", "1: unknown group `Unknown'"},
+		{"\n[Other Options]\nname = a", "3: unknown option `name'"},
+		{"value = a", "1: invalid argument for flag `--value' (expected []int)"},
+		{"enabled = maybe", "1: invalid boolean value `maybe' for option `--enabled'"},
+		{"name", "1: malformed key=value (name)"},
+		{"[Other Options", "1: malformed section header"},
+	}
+
+	for _, test := range tests {
+		var opts iniOptions
+		var other iniOtherOptions
+
+		err := newIniParser(&opts, &other).Parse(strings.NewReader(test.ini))
+
+		if err == nil {
+			t.Errorf("Expected error for %q", test.ini)
+		} else if _, ok := err.(*IniError); !ok || err.Error() != test.message {
+			t.Errorf("Expected error %q for %q but got %q", test.message, test.ini, err)
+		}
+	}
+}
+
+func TestIniWrite(t *testing.T) {
+	var opts iniOptions
+	var other iniOtherOptions
+
+	other.Level = 2
+	ini := newIniParser(&opts, &other)
+
+	opts.Name = " padded"
+	opts.Values = []int{1, 2}
+	opts.Map = map[string]string{"b": "2", "a": "1"}
+
+	var b bytes.Buffer
+	ini.Write(&b, IniDefault)
+
+	expected := `[Application Options]
+; Name
+name = " padded"
+
+; Values
+value = 1
+value = 2
+
+; Map
+map = a:1
+map = b:2
+`
+
+	if b.String() != expected {
+		t.Errorf("Expected ini:\n%s\nbut got:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	ini.Write(&b, IniCommentDefaults)
+
+	if s := b.String(); !strings.Contains(s, "; enabled = false\n") || !strings.Contains(s, "[Other Options]\n; level = 2\n") {
+		t.Errorf("Expected commented defaults but got:\n%s", s)
+	}
+
+	var opts2 iniOptions
+	var other2 iniOtherOptions
+
+	if err := newIniParser(&opts2, &other2).Parse(&b); err != nil {
+		t.Fatalf("Unexpected error reading back ini: %s", err)
+	}
+
+	if opts2.Name != opts.Name || len(opts2.Values) != 2 || opts2.Map["b"] != "2" || other2.Level != 0 {
+		t.Errorf("Unexpected values read back from ini: %+v %+v", opts2, other2)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestIniWriteError(t *testing.T) {
+	var opts iniOptions
+	var other iniOtherOptions
+
+	ini := newIniParser(&opts, &other)
+
+	if err := ini.Write(failingWriter{}, IniIncludeDefaults); err == nil || err.Error() != "disk full" {
+		t.Errorf("Expected the write error but got %v", err)
+	}
+
+	if _, err := os.Stat("/dev/full"); err == nil {
+		if err := ini.WriteFile("/dev/full", IniIncludeDefaults); err == nil {
+			t.Errorf("Expected an error writing to /dev/full")
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// ParseJSONFile reads option values from a JSON file. See Parser.ParseJSON
+// for more information.
+func (p *Parser) ParseJSONFile(filename string) error {
+	fp, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer fp.Close()
+
+	return p.parseJSON(fp, filename)
+}
+
+// ParseJSON reads option values from a JSON document. The document is an
+// object of which the keys are group names (case insensitive) mapping to
+// objects with the option values of the group, or long option names which
+// are looked up in all groups. For example:
+//
+//	{
+//	    "verbose": true,
+//	    "Application Options": {
+//	        "include": ["/usr/include", "/usr/local/include"]
+//	    }
+//	}
+//
+// Arrays set options which can be specified more than once (e.g. slices)
+// once for each element, and objects set map options. As with ParseYAML,
+// the values of options which were already specified on the command line
+// are not changed. The returned error is of type *ConfigError when the
+// document could not be parsed or an option could not be set.
+func (p *Parser) ParseJSON(reader io.Reader) error {
+	return p.parseJSON(reader, "")
+}
+
+func (p *Parser) parseJSON(reader io.Reader, filename string) error {
+	data, err := ioutil.ReadAll(reader)
+
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	// Keep numbers verbatim such that large integers are passed to
+	// options without loss of precision
+	decoder.UseNumber()
+
+	var values map[string]interface{}
+
+	if err := decoder.Decode(&values); err != nil {
+		ret := &ConfigError{
+			Message: err.Error(),
+			File:    filename,
+			Err:     err,
+		}
+
+		var offset int64 = -1
+
+		switch e := err.(type) {
+		case *json.SyntaxError:
+			offset = e.Offset
+		case *json.UnmarshalTypeError:
+			offset = e.Offset
+			ret.Message = "expected an object at the top level"
+		}
+
+		if offset >= 0 && offset <= int64(len(data)) {
+			ret.LineNumber = uint(bytes.Count(data[:offset], []byte("\n")) + 1)
+		}
+
+		return ret
+	}
+
+	return p.applyConfig(values, filename)
+}
@@ -5,14 +5,18 @@
 package flags
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"reflect"
 	"strings"
-	"unicode/utf8"
 )
 
+// The parser was frozen using Parser.Freeze and can only be cloned
+var ErrFrozen = errors.New("parser is frozen (use Clone to obtain a parser which can be modified and parsed)")
+
 // A Parser provides command line option parsing. It can contain several
 // option groups each with their own set of options.
 type Parser struct {
@@ -26,6 +30,108 @@ type Parser struct {
 	Usage string
 
 	Options Options
+
+	// If not nil, OptionValidator is called with the option and its
+	// argument (or "" for options without an argument) before an option
+	// is set from any source. A returned error aborts parsing and is
+	// reported as an error of the option.
+	OptionValidator func(option *Option, value string) error
+
+	// If not nil, StructValidator is called with the data struct of each
+	// group after parsing, when all the values have been set. This allows
+	// validation packages to check the populated structs (e.g. using
+	// validate tags). Errors implementing FieldError are reported as
+	// errors of the option of the corresponding field.
+	StructValidator func(data interface{}) error
+
+	// If not nil, ErrorFormatter renders parser errors for display when
+	// the PrintErrors option is set, instead of the default
+	// "Flags error: <message>". This allows for localized messages, for
+	// example based on the Type and Option of the error.
+	ErrorFormatter func(err *Error) string
+
+	// The writer to which errors are printed (see PrintErrors and
+	// HelpOnError). If nil, errors are printed to Stderr
+	ErrorWriter io.Writer
+
+	// If not nil, Trace is called with a description of each parse event:
+	// the command line arguments which are consumed, the options which
+	// are set and their converted values and origins, and values which
+	// are ignored because a source of higher precedence set the option.
+	// When Trace is nil, the events are printed to os.Stderr if the
+	// GO_FLAGS_DEBUG environment variable is set when the program starts
+	// parsing.
+	Trace func(message string)
+
+	// If not nil, Warning is called for non-fatal events during parsing:
+	// errors which are ignored because of the IgnoreUnknown option, the
+	// use of deprecated options (ErrDeprecated) and options which do not
+	// accept multiple values being set more than once (ErrOverridden)
+	Warning func(warning *Error)
+
+	// If not nil, Confirmer is called by Confirm to ask the question
+	// confirming an action, instead of asking it on the terminal. It
+	// returns whether the action is confirmed
+	Confirmer func(question string) (bool, error)
+
+	// If not nil, IsTerminal is used by Enabled to find out whether an
+	// output is a terminal, for example to simulate a terminal in tests
+	IsTerminal func(file *os.File) bool
+
+	// If not zero, the help message is wrapped at HelpWidth columns instead
+	// of the width of the terminal, which makes the help message
+	// deterministic (e.g. to compare it against a golden file in tests)
+	HelpWidth int
+
+	// The layout of the options in the help message, such as their
+	// indentation and the column of their descriptions (see HelpLayout)
+	HelpLayout HelpLayout
+
+	// The standard input, output and error of the parser, used instead of
+	// those of the process when not nil, e.g. to run programs in SSH
+	// sessions or embedded shells, or to exercise them in tests. Prompts
+	// (see PromptRequired and Confirm) read answers from Stdin and are
+	// written to Stderr. Readers other than files are assumed to be
+	// interactive, while the echo of secrets is only disabled on the
+	// standard input of the process. The help message is printed to Stdout
+	// for HelpNoError, and errors are printed to Stderr unless ErrorWriter
+	// is set
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// If not nil, LookupEnv is used instead of os.LookupEnv to look up
+	// environment variables, by EnvironmentSource and the expand tag, and
+	// to look up GO_FLAGS_DEBUG and GO_FLAGS_COMPLETION
+	LookupEnv func(name string) (string, bool)
+
+	// Whether the parser neither depends on the environment nor uses the
+	// terminal (see ParseStrings)
+	isolated bool
+
+	// Whether the help flag was specified during the last parse
+	helpRequested bool
+
+	// Whether the GO_FLAGS_DEBUG environment variable is set, or nil if it
+	// was not looked up yet (see tracing)
+	debug *bool
+
+	// The builtin help group, added by the HelpFlag option
+	helpGroup *Group
+
+	// Whether the parser was frozen using Freeze
+	frozen bool
+
+	// The source currently being applied by ParseSources
+	layer *layer
+
+	// The groups of the parser (see groups) while parsing, such that they
+	// are not collected for every argument
+	flat []*Group
+
+	// The edits of options given on the command line by ParseSources,
+	// which are applied after the other sources (see applyEdits)
+	edits []edit
 }
 
 // Parser options
@@ -52,6 +158,44 @@ const (
 	// Print any errors which occured during parsing to os.Stderr
 	PrintErrors
 
+	// Continue parsing after an error and return all the errors (of type
+	// Errors when there is more than one), such that all problems can be
+	// fixed at once
+	CollectErrors
+
+	// Print the help message after printing an error
+	HelpOnError
+
+	// When the help flag is specified, print the help message to os.Stdout
+	// and return without an error instead of returning ErrHelp. Use
+	// Parser.HelpRequested to find out whether help was shown
+	HelpNoError
+
+	// Show the long aliases of options in the help message
+	ShowAliases
+
+	// When the input is a terminal (see Parser.Stdin), prompt for the
+	// values of required options which were not set by any source instead
+	// of failing. The default value of the option, if any, is used when the
+	// answer is empty
+	PromptRequired
+
+	// Read arguments of the form @filename from the file, with leading and
+	// trailing white space removed, for example to inject secrets mounted
+	// as files. This applies to the values of all the sources. Use @@ for
+	// arguments starting with a literal @
+	ValuesFromFiles
+
+	// Stop parsing options at the first non-option argument, and pass it
+	// and all the arguments after it as remaining command line arguments
+	// (e.g. for programs running other commands with their own options)
+	PassAfterNonOption
+
+	// Also set options from the environment variables named by their env
+	// tags when parsing the command line using Parse or ParseArgs, as if
+	// the command line and the environment were parsed using ParseSources
+	ParseEnvironment
+
 	// A convenient default set of options
 	Default = HelpFlag | PrintErrors | PassDoubleDash
 )
@@ -64,6 +208,27 @@ func Parse(data interface{}) ([]string, error) {
 	return NewParser(data, Default).Parse()
 }
 
+// ParseStrings parses the given arguments into the provided data, a pointer
+// to a struct representing the default option group, and returns the
+// remaining arguments. Unlike Parse, ParseStrings has no side effects other
+// than setting the options: it uses no parser options (see None), never
+// prints anything or exits, ignores the GO_FLAGS_DEBUG and
+// GO_FLAGS_COMPLETION environment variables and never prompts for values.
+// This makes it suitable as a fuzz target. For example:
+//
+//	func FuzzOptions(f *testing.F) {
+//		f.Fuzz(func(t *testing.T, arg string) {
+//			var opts Options
+//			flags.ParseStrings(&opts, []string{arg})
+//		})
+//	}
+func ParseStrings(data interface{}, args []string) ([]string, error) {
+	p := NewNamedParser("", None, NewGroup("Application Options", data))
+	p.isolated = true
+
+	return p.ParseArgs(args)
+}
+
 // NewParser creates a new parser. It uses os.Args[0] as the application
 // name and then calls Parser.NewNamedParser (see Parser.NewNamedParser for
 // more details). The provided data is a pointer to a struct representing the
@@ -91,12 +256,200 @@ func NewNamedParser(appname string, options Options, groups ...*Group) *Parser {
 	}
 }
 
-// AddGroup adds a new group to the parser with the given name and data. The
-// data needs to be a pointer to a struct from which the fields indicate which
-// options are in the group.
-func (p *Parser) AddGroup(name string, data interface{}) *Parser {
-	p.Groups = append(p.Groups, NewGroup(name, data))
-	return p
+// FindOptionByLongName finds the option with the given long name in the
+// groups of the parser, or returns nil if there is no such option.
+func (p *Parser) FindOptionByLongName(longname string) *Option {
+	for _, grp := range p.groups() {
+		if option := grp.LongNames[longname]; option != nil {
+			return option
+		}
+	}
+
+	return nil
+}
+
+// FindOptionByShortName finds the option with the given short name in the
+// groups of the parser, or returns nil if there is no such option.
+func (p *Parser) FindOptionByShortName(shortname rune) *Option {
+	option, _ := p.getShort(shortname)
+	return option
+}
+
+// FindGroup finds the group with the given name, or returns nil if there is
+// no such group.
+func (p *Parser) FindGroup(name string) *Group {
+	for _, grp := range p.groups() {
+		if grp.Name == name {
+			return grp
+		}
+	}
+
+	return nil
+}
+
+// FindOption finds an option by its path, which is the name of its group
+// and its long name separated by a dot (e.g. "Application Options.verbose"),
+// the same way options are named in ini files. A path without a group name
+// is looked up in all groups. FindOption returns nil if there is no such
+// option.
+func (p *Parser) FindOption(name string) *Option {
+	for _, grp := range p.groups() {
+		if strings.HasPrefix(name, grp.Name+".") {
+			if option := grp.LongNames[name[len(grp.Name)+1:]]; option != nil {
+				return option
+			}
+		}
+	}
+
+	return p.FindOptionByLongName(name)
+}
+
+// EachGroup calls the function for each group of the parser, in order,
+// including sub-groups, which directly follow their parent group.
+func (p *Parser) EachGroup(f func(grp *Group)) {
+	for _, grp := range p.groups() {
+		f(grp)
+	}
+}
+
+// EachOption calls the function for each option of the parser along with
+// the group containing it, in the order in which the options are shown in
+// the help message. This is useful for tools which need to inspect all the
+// options, e.g. to generate documentation.
+func (p *Parser) EachOption(f func(grp *Group, option *Option)) {
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			f(grp, option)
+		}
+	}
+}
+
+// SpecifiedOptions returns the options of the parser which were explicitly
+// set (see Option.IsSet), in the order in which they are shown in the help
+// message.
+func (p *Parser) SpecifiedOptions() []*Option {
+	var ret []*Option
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if option.IsSet() {
+				ret = append(ret, option)
+			}
+		}
+	}
+
+	return ret
+}
+
+// Freeze makes the definition of the options of the parser immutable. A
+// frozen parser cannot be modified (e.g. using AddGroup or Group.AddOption)
+// nor parsed, but can be safely cloned concurrently (see Parser.Clone),
+// which makes it a cheap template to share, e.g. to parse the options of
+// each request of a server in its own clone. The definition of a parser must
+// not be modified concurrently before it is frozen. Functions registering
+// options from package init functions are safe, since those never run
+// concurrently.
+func (p *Parser) Freeze() {
+	p.addHelpGroup()
+	p.frozen = true
+
+	for _, grp := range p.groups() {
+		grp.frozen = true
+	}
+}
+
+// Frozen returns whether the parser was frozen using Freeze.
+func (p *Parser) Frozen() bool {
+	return p.frozen
+}
+
+// ApplyDefaults sets all the options which were not set by any source (see
+// Option.IsSet) and have a Default value to that value. Options are
+// initialized to their default tags when their group is created, so this is
+// only needed to restore defaults, e.g. after ClearDefaults, or for Default
+// values assigned to options added using Group.AddOption.
+func (p *Parser) ApplyDefaults() error {
+	if p.frozen {
+		return ErrFrozen
+	}
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if option.IsSet() {
+				continue
+			}
+
+			if err := option.applyDefault(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ClearDefaults sets all the options which were not set by any source (see
+// Option.IsSet) and have a Default value to the zero value of their type,
+// such that only the values which were explicitly specified remain.
+func (p *Parser) ClearDefaults() {
+	if p.frozen {
+		return
+	}
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if option.IsSet() || !option.hasDefault() {
+				continue
+			}
+
+			option.value.Set(reflect.Zero(option.value.Type()))
+			option.store()
+		}
+	}
+}
+
+// AddGroup adds a new group to the parser with the given name, description
+// and data, and returns the group. The data needs to be a pointer to a struct
+// from which the fields indicate which options are in the group (see
+// NewGroup). This allows independent packages to each add their own options
+// to a shared parser. Errors in the definition of the options are stored in
+// the Error field of the returned group. The group is not added to a frozen
+// parser, in which case its Error is ErrFrozen.
+func (p *Parser) AddGroup(name string, description string, data interface{}) *Group {
+	grp := NewGroup(name, data)
+	grp.Description = description
+
+	if p.frozen {
+		grp.Error = ErrFrozen
+		return grp
+	}
+
+	p.Groups = append(p.Groups, grp)
+	p.flat = nil
+	return grp
+}
+
+// RemoveGroup removes the group, and thereby all of its options and
+// sub-groups, from the parser. It returns false if the group is not part of
+// the parser or the parser is frozen.
+func (p *Parser) RemoveGroup(group *Group) bool {
+	var removed bool
+
+	if p.frozen {
+		return false
+	}
+
+	p.Groups, removed = removeGroup(p.Groups, group)
+	p.flat = nil
+	return removed
+}
+
+// HelpRequested returns whether the help flag was specified during the last
+// parse. This is only useful in combination with the HelpNoError option, in
+// which case the help message was printed and the program should normally
+// exit.
+func (p *Parser) HelpRequested() bool {
+	return p.helpRequested
 }
 
 // Parse parses the command line arguments from os.Args using Parser.ParseArgs.
@@ -115,106 +468,142 @@ func (p *Parser) Parse() ([]string, error) {
 // was specified in the command line arguments, a help message will be
 // automatically printed. Furthermore, the special error type ErrHelp is returned.
 // It is up to the caller to exit the program if so desired.
+//
+// When the CollectErrors option is set, parsing continues after invalid
+// arguments and the returned error lists every problem that was found. When
+// the ParseEnvironment option is set, options are also set from the
+// environment, with the command line taking precedence.
+//
+// When the GO_FLAGS_COMPLETION environment variable is set, the arguments
+// are not parsed. Instead, the completions of the last argument are printed
+// to os.Stdout and the program exits (see WriteCompletion).
 func (p *Parser) ParseArgs(args []string) ([]string, error) {
+	// The command line source parses the arguments using ParseArgs
+	if (p.Options&ParseEnvironment) != None && p.layer == nil {
+		return p.ParseSources(CommandLineSource(args), EnvironmentSource())
+	}
+
 	ret := make([]string, 0, len(args))
-	i := 0
 
-	if (p.Options & HelpFlag) != None {
-		var help struct {
-			ShowHelp func() error `short:"h" long:"help" description:"Show this help message"`
-		}
+	err := p.parseArgs(args, func(arg string) error {
+		ret = append(ret, arg)
+		return nil
+	})
 
-		help.ShowHelp = func() error {
-			var b bytes.Buffer
-			p.WriteHelp(&b)
-			return newError(ErrHelp, b.String())
-		}
+	if err != nil || p.helpRequested {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// ParseArgsFunc parses the command line arguments like ParseArgs, except
+// that the remaining, non-option, arguments are passed to the positional
+// function as soon as they are encountered instead of being collected. This
+// allows very large argument lists (e.g. from xargs or response files) to
+// be processed in a single pass, without keeping the remaining arguments in
+// memory. An error returned by positional stops parsing and is returned.
+func (p *Parser) ParseArgsFunc(args []string, positional func(arg string) error) error {
+	return p.parseArgs(args, positional)
+}
+
+func (p *Parser) parseArgs(args []string, positional func(arg string) error) error {
+	var errs Errors
+	p.helpRequested = false
+
+	if p.frozen {
+		return ErrFrozen
+	}
 
-		p.Groups = append([]*Group{NewGroup("Help Options", &help)}, p.Groups...)
-		p.Options &^= HelpFlag
+	if err := p.groupError(); err != nil {
+		return err
 	}
 
-	for i < len(args) {
-		arg := args[i]
-		i++
+	p.addHelpGroup()
 
-		// When PassDoubleDash is set and we encounter a --, then
-		// simply append all the rest as arguments and break out
-		if (p.Options&PassDoubleDash) != None && arg == "--" {
-			ret = append(ret, args[i:]...)
-			break
-		}
+	if p.flat == nil {
+		p.flat = p.groups()
 
-		// If the argument is not an option, then append it to the rest
-		if arg[0] != '-' {
-			ret = append(ret, arg)
-			continue
-		}
+		defer func() {
+			p.flat = nil
+		}()
+	}
 
-		pos := strings.Index(arg, "=")
-		var argument *string
+	// Occurrences are counted across the sources of ParseSources
+	if p.layer == nil {
+		p.resetOccurrences()
+	}
 
-		if pos >= 0 {
-			rest := arg[pos+1:]
-			argument = &rest
-			arg = arg[:pos]
-		}
+	if mode := p.completionMode(); mode != "" && !p.isolated {
+		p.printCompletions(p.stdout(), args, mode == "verbose")
+		os.Exit(0)
+	}
 
-		var err error
-
-		if strings.HasPrefix(arg, "--") {
-			err, i = p.parseLong(args, arg[2:], argument, i)
-		} else {
-			short := arg[1:]
-
-			for j, c := range short {
-				clen := utf8.RuneLen(c)
-				islast := (j+clen == len(short))
-
-				if !islast && argument == nil {
-					rr := short[j+clen:]
-					next, _ := utf8.DecodeRuneInString(rr)
-					info, _ := p.getShort(c)
-
-					if info != nil && info.canArgument() {
-						if snext, _ := p.getShort(next); snext == nil {
-							// Consider the next stuff as an argument
-							argument = &rr
-							islast = true
-						}
-					}
-				}
+	t := tokenizer{
+		p:    p,
+		args: args,
+	}
 
-				err, i = p.parseShort(args, c, islast, argument, i)
+	for {
+		token, err, ok := t.next()
 
-				if err != nil || islast {
-					break
+		if !ok {
+			break
+		}
+
+		if err == nil {
+			if token.Type == TokenPositional {
+				err = positional(token.Arg)
+
+				if err != nil {
+					return err
 				}
+
+				continue
 			}
+
+			err = p.setCommandLine(token.Option, token.Flag, token.Operator, token.value())
 		}
 
 		if err != nil {
-			if (p.Options & IgnoreUnknown) != None {
-				ret = append(ret, arg)
-			} else {
-				parseErr, ok := err.(*Error)
+			// Any remaining combined short options are dropped
+			t.short = ""
 
-				if !ok {
-					parseErr = newError(ErrUnknown, err.Error())
-				}
+			if e, ok := err.(*Error); ok && e.Type == ErrHelp && (p.Options&HelpNoError) != None {
+				p.helpRequested = true
+				fmt.Fprint(p.stdout(), e.Message)
+				return nil
+			}
 
-				if (p.Options&PrintErrors) != None {
-					if parseErr.Type == ErrHelp {
-						fmt.Fprintln(os.Stderr, err)
-					} else {
-						fmt.Fprintf(os.Stderr, "Flags error: %s\n", err.Error())
-					}
-				}
+			if (p.Options & IgnoreUnknown) != None {
+				p.warn(err)
 
-				return nil, err
+				if err := positional(token.Arg); err != nil {
+					return err
+				}
+			} else if p.collectError(err) {
+				errs = append(errs, err)
+			} else {
+				return p.printError(err)
 			}
 		}
 	}
 
-	return ret, nil
+	// Lazy defaults are applied and constraints between options are checked
+	// by ParseSources after all the sources have been applied
+	if p.layer == nil {
+		if err := p.applyDefaults(); err != nil {
+			errs = errs.append(err)
+		} else if err := p.promptMissing(); err != nil {
+			errs = errs.append(err)
+		} else if err := p.validate(); err != nil {
+			errs = errs.append(err)
+		}
+	}
+
+	if len(errs) != 0 {
+		return p.printError(errs.err())
+	}
+
+	return nil
 }
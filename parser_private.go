@@ -1,71 +1,457 @@
 package flags
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"unicode/utf8"
 )
 
-func (p *Parser) removeGroup(group *Group) {
-	for i, grp := range p.Groups {
+func (p *Parser) addHelpGroup() {
+	if (p.Options & HelpFlag) == None {
+		return
+	}
+
+	var help struct {
+		ShowHelp func() error `short:"h" long:"help" description:"Show this help message"`
+	}
+
+	help.ShowHelp = func() error {
+		var b bytes.Buffer
+		p.WriteHelp(&b)
+		return newError(ErrHelp, b.String())
+	}
+
+	p.helpGroup = NewGroup("Help Options", &help)
+	p.Groups = append([]*Group{p.helpGroup}, p.Groups...)
+	p.flat = nil
+	p.Options &^= HelpFlag
+}
+
+// removeGroup removes the group from groups or their sub-groups.
+func removeGroup(groups []*Group, group *Group) ([]*Group, bool) {
+	for i, grp := range groups {
 		if grp == group {
-			p.Groups = append(p.Groups[:i], p.Groups[i+1:]...)
-			break
+			return append(groups[:i], groups[i+1:]...), true
+		}
+
+		var removed bool
+
+		if grp.Groups, removed = removeGroup(grp.Groups, group); removed {
+			return groups, true
+		}
+	}
+
+	return groups, false
+}
+
+// groups returns all the groups of the parser, each group directly followed
+// by its sub-groups.
+func (p *Parser) groups() []*Group {
+	if p.flat != nil {
+		return p.flat
+	}
+
+	ret := make([]*Group, 0, len(p.Groups))
+
+	for _, grp := range p.Groups {
+		ret = grp.all(ret)
+	}
+
+	return ret
+}
+
+// resetOccurrences resets the number of times each option was specified,
+// which is counted for each parse (see the min-occurrences and
+// max-occurrences tags).
+func (p *Parser) resetOccurrences() {
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			option.occurrences = 0
+		}
+	}
+}
+
+// groupError returns the first error which occurred when creating the
+// groups of the parser, if any.
+func (p *Parser) groupError() error {
+	for _, grp := range p.Groups {
+		if err := grp.groupError(); err != nil {
+			return err
 		}
 	}
+
+	return nil
+}
+
+// errorWriter returns the writer to which errors are printed.
+func (p *Parser) errorWriter() io.Writer {
+	if p.ErrorWriter != nil {
+		return p.ErrorWriter
+	}
+
+	return p.stderr()
 }
 
-func (p *Parser) parseOption(group *Group, args []string, name string, option *Option, canarg bool, argument *string, index int) (error, int) {
-	var err error
+// printError prints the error when the PrintErrors option is set, followed
+// by the help message when the HelpOnError option is set, and returns the
+// error.
+func (p *Parser) printError(err error) error {
+	writer := p.errorWriter()
+	parseErr, ok := err.(*Error)
+	isHelp := ok && parseErr.Type == ErrHelp
+
+	if (p.Options & PrintErrors) != None {
+		if isHelp {
+			fmt.Fprintln(writer, err)
+		} else if errs, ok := err.(Errors); ok {
+			for _, e := range errs {
+				fmt.Fprintln(writer, p.formatError(e))
+			}
+		} else {
+			fmt.Fprintln(writer, p.formatError(err))
+		}
+	}
+
+	if !isHelp && (p.Options&HelpOnError) != None {
+		fmt.Fprintln(writer)
+		p.WriteHelp(writer)
+	}
+
+	return err
+}
+
+// formatError renders an error for display, using the ErrorFormatter of
+// the parser for errors of type *Error if it is set.
+func (p *Parser) formatError(err error) string {
+	if e, ok := err.(*Error); ok && p.ErrorFormatter != nil {
+		return p.ErrorFormatter(e)
+	}
+
+	return fmt.Sprintf("Flags error: %s", err.Error())
+}
+
+// collectError returns whether parsing continues after the given error
+// because the CollectErrors option is set. Help requests always stop
+// parsing.
+func (p *Parser) collectError(err error) bool {
+	if (p.Options & CollectErrors) == None {
+		return false
+	}
+
+	e, ok := err.(*Error)
+	return !ok || e.Type != ErrHelp
+}
+
+// setCommandLine sets the option to a value specified on the command line
+// using the given flag, unless a source of higher precedence already set it.
+func (p *Parser) setCommandLine(option *Option, flag string, operator string, value *string) error {
+	if !option.allowsOrigin(OriginCommandLine) {
+		return newOptionError(ErrRestricted, option,
+			fmt.Sprintf("flag `%s' cannot be specified on the command line", option))
+	}
+
+	if operator != "" {
+		return p.editOption(edit{option, flag, operator, *value})
+	}
+
+	origin := Origin{
+		Type: OriginCommandLine,
+		Name: flag,
+	}
+
+	if !p.claimOption(option, commandLineLayer, origin) {
+		return nil
+	}
+
+	if err := p.setOption(option, value); err != nil {
+		return option.marshalError(err)
+	}
+
+	if option.ConfigFile && value != nil {
+		return p.loadConfigFile(*value)
+	}
+
+	return nil
+}
+
+// setOption validates the value using the validator of the parser (see
+// Parser.OptionValidator) and then sets the option to the value.
+func (p *Parser) setOption(option *Option, value *string) error {
+	if modes := option.options.Get("expand"); value != nil && modes != "" {
+		v := p.expandValue(*value, modes)
+		value = &v
+	}
+
+	if value != nil && (p.Options&ValuesFromFiles) != None && strings.HasPrefix(*value, "@") {
+		v, err := readValueFile(*value)
+
+		if err != nil {
+			return newOptionError(ErrMarshal, option,
+				fmt.Sprintf("failed to read the argument of flag `%s': %s", option, err)).wrap(err)
+		}
+
+		value = &v
+	}
+
+	if p.OptionValidator != nil {
+		v := ""
+
+		if value != nil {
+			v = *value
+		}
+
+		if err := p.OptionValidator(option, v); err != nil {
+			return option.validationError(value, err)
+		}
+	}
+
+	if err := option.Set(value); err != nil {
+		return err
+	}
+
+	p.traceSet(option)
+	p.warnSet(option)
+
+	return nil
+}
+
+// expandValue expands the value according to the comma separated modes of
+// the expand tag: home expands a leading ~ followed by a slash or the end of
+// the value to the home directory of the user, and env expands environment
+// variables ($VAR or ${VAR}, with $$ for a literal $). Like in shells, the ~
+// is expanded before, and not after, the variables.
+func (p *Parser) expandValue(value string, modes string) string {
+	var env, home bool
+
+	for _, mode := range strings.Split(modes, ",") {
+		switch strings.TrimSpace(mode) {
+		case "env":
+			env = true
+		case "home":
+			home = true
+		}
+	}
+
+	if home && (value == "~" || strings.HasPrefix(value, "~/")) {
+		if dir, err := p.homeDir(); err == nil {
+			// The home directory itself is not expanded
+			if env {
+				dir = strings.ReplaceAll(dir, "$", "$$")
+			}
+
+			value = dir + value[1:]
+		}
+	}
+
+	if env {
+		value = os.Expand(value, func(name string) string {
+			if name == "$" {
+				return "$"
+			}
+
+			return p.getenv(name)
+		})
+	}
+
+	return value
+}
+
+// readValueFile returns the contents of the file named by an argument of the
+// form @filename (see ValuesFromFiles), or the argument without the first @
+// for arguments starting with @@.
+func readValueFile(value string) (string, error) {
+	if strings.HasPrefix(value, "@@") {
+		return value[1:], nil
+	}
+
+	data, err := os.ReadFile(value[1:])
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// loadConfigFile loads a configuration file named on the command line. The
+// values of the file are beneath those of the command line: options which
+// were already set on the command line are not modified, while options
+// which are set on the command line later on replace the value of the file.
+func (p *Parser) loadConfigFile(filename string) error {
+	prev := p.layer
+
+	l := newLayer(filename)
+	l.above = prev
+
+	if l.above == nil {
+		l.above = commandLineLayer
+	}
+
+	p.layer = l
+
+	defer func() {
+		p.layer = prev
+	}()
+
+	_, err := fileSource{filename: filename}.apply(p)
+	return err
+}
+
+func (p *Parser) parseOption(group *Group, args []string, flag string, option *Option, canarg bool, argument string, hasArgument bool, index int) (Token, error, int) {
+	token := Token{
+		Type:   TokenOption,
+		Option: option,
+		Flag:   flag,
+	}
 
 	if !option.canArgument() {
-		if canarg && argument != nil {
-			return newError(ErrNoArgumentForBool,
+		if canarg && hasArgument {
+			return token, newOptionError(ErrNoArgumentForBool, option,
 					fmt.Sprintf("bool flag `%s' cannot have an argument", option)),
 				index
 		}
-
-		err = option.Set(nil)
-	} else if canarg && (argument != nil || index < len(args)) {
-		if argument == nil {
-			argument = &args[index]
+	} else if canarg && (hasArgument || index < len(args)) {
+		if !hasArgument {
+			argument = args[index]
 			index++
 		}
 
-		err = option.Set(argument)
+		token.Value, token.HasValue = argument, true
 	} else if option.OptionalArgument {
-		err = option.Set(&option.Default)
+		token.Value, token.HasValue = option.Default, true
 	} else {
-		return newError(ErrExpectedArgument,
+		return token, newOptionError(ErrExpectedArgument, option,
 				fmt.Sprintf("expected argument for flag `%s'", option)),
 			index
 	}
 
-	if err != nil {
-		if _, ok := err.(*Error); !ok {
-			err = newError(ErrMarshal,
-				fmt.Sprintf("invalid argument for flag `%s' (expected %s)",
-					option,
-					option.value.Type()))
+	return token, nil, index
+}
+
+func (p *Parser) parseLong(args []string, flag string, argument string, hasArgument bool, index int) (Token, error, int) {
+	name := flag[2:]
+
+	for _, grp := range p.groups() {
+		if option := grp.LongNames[name]; option != nil {
+			return p.parseOption(grp, args, flag, option, true, argument, hasArgument, index)
 		}
 	}
 
-	return err, index
+	// Arguments of slice and map options can be added and removed using
+	// --name+=value and --name-=value
+	if n := len(name) - 1; hasArgument && n > 0 && (name[n] == '+' || name[n] == '-') {
+		for _, grp := range p.groups() {
+			if option := grp.LongNames[name[:n]]; option != nil && option.isCollection() {
+				token, err, index := p.parseOption(grp, args, flag[:len(flag)-1], option, true, argument, hasArgument, index)
+				token.Operator = name[n:]
+
+				return token, err, index
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("unknown flag `%s'", name)
+
+	token := Token{
+		Type: TokenOption,
+		Flag: flag,
+	}
+
+	// Suggestions are not computed for errors which are ignored without
+	// being reported
+	if p.Options&IgnoreUnknown != None && p.Warning == nil {
+		return token, newError(ErrUnknownFlag, msg), index
+	}
+
+	if suggestions := p.suggestLong(name); len(suggestions) != 0 {
+		msg += fmt.Sprintf(" (did you mean %s?)", strings.Join(suggestions, " or "))
+	}
+
+	return token, newError(ErrUnknownFlag, msg), index
 }
 
-func (p *Parser) parseLong(args []string, name string, argument *string, index int) (error, int) {
-	for _, grp := range p.Groups {
-		if option := grp.LongNames[name]; option != nil {
-			return p.parseOption(grp, args, name, option, true, argument, index)
+// The maximum number of suggestions for an unknown flag
+const maxSuggestions = 3
+
+// suggestLong returns the long options of the parser which are closest to
+// the given unknown long option name, formatted for use in error messages.
+// Only the options at the smallest edit distance are suggested.
+func (p *Parser) suggestLong(name string) []string {
+	var suggestions []string
+
+	n := utf8.RuneCountInString(name)
+	best := n/3 + 1
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if option.LongName == "" {
+				continue
+			}
+
+			// The distance is at least the difference in length, which
+			// avoids computing distances to very long unknown names
+			if l := utf8.RuneCountInString(option.LongName); l-n > best || n-l > best {
+				continue
+			}
+
+			d := editDistance(name, option.LongName)
+
+			if d < best {
+				best = d
+				suggestions = suggestions[:0]
+			}
+
+			if d == best {
+				suggestions = append(suggestions, option.LongName)
+			}
 		}
 	}
 
-	return newError(ErrUnknownFlag,
-			fmt.Sprintf("unknown flag `%s'", name)),
-		index
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	for i, s := range suggestions {
+		suggestions[i] = fmt.Sprintf("`--%s'", s)
+	}
+
+	return suggestions
+}
+
+// editDistance computes the Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(t)]
 }
 
 func (p *Parser) getShort(name rune) (*Option, *Group) {
-	for _, grp := range p.Groups {
+	for _, grp := range p.groups() {
 		option := grp.ShortNames[name]
 
 		if option != nil {
@@ -76,23 +462,22 @@ func (p *Parser) getShort(name rune) (*Option, *Group) {
 	return nil, nil
 }
 
-func (p *Parser) parseShort(args []string, name rune, islast bool, argument *string, index int) (error, int) {
-	names := make([]byte, utf8.RuneLen(name))
-	utf8.EncodeRune(names, name)
-
+func (p *Parser) parseShort(args []string, flag string, name rune, islast bool, argument string, hasArgument bool, index int) (Token, error, int) {
 	option, grp := p.getShort(name)
 
 	if option != nil {
 		if option.canArgument() && !islast && !option.OptionalArgument {
-			return newError(ErrExpectedArgument,
+			return Token{Type: TokenOption, Option: option, Flag: flag},
+				newOptionError(ErrExpectedArgument, option,
 					fmt.Sprintf("expected argument for flag `%s'", option)),
 				index
 		}
 
-		return p.parseOption(grp, args, string(names), option, islast, argument, index)
+		return p.parseOption(grp, args, flag, option, islast, argument, hasArgument, index)
 	}
 
-	return newError(ErrUnknownFlag,
-			fmt.Sprintf("unknown flag `%s'", string(names))),
+	return Token{Type: TokenOption, Flag: flag},
+		newError(ErrUnknownFlag,
+			fmt.Sprintf("unknown flag `%s'", flag[1:])),
 		index
 }
@@ -0,0 +1,291 @@
+package flags
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFreeze(t *testing.T) {
+	var opts struct {
+		Port int `long:"port"`
+	}
+
+	p := NewNamedParser("test", HelpFlag, NewGroup("Application Options", &opts))
+	p.Freeze()
+
+	var extra string
+
+	if _, err := p.ParseArgs(nil); err != ErrFrozen {
+		t.Errorf("Expected ErrFrozen but got %v", err)
+	}
+
+	if _, err := p.Groups[1].AddOption("extra", 0, "", &extra); err != ErrFrozen {
+		t.Errorf("Expected ErrFrozen but got %v", err)
+	}
+
+	if grp := p.AddGroup("Other Options", "", nil); grp.Error != ErrFrozen || len(p.Groups) != 2 {
+		t.Errorf("Expected group to not be added to frozen parser")
+	}
+
+	if p.RemoveGroup(p.Groups[1]) || p.Groups[1].RemoveOption(p.Groups[1].Options[0]) {
+		t.Errorf("Expected removal from frozen parser to fail")
+	}
+
+	done := make(chan int)
+
+	for i := 0; i < 4; i++ {
+		go func(port int) {
+			c := p.Clone()
+
+			if _, err := c.ParseArgs([]string{"--port", strconv.Itoa(port)}); err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+
+			done <- c.FindOptionByLongName("port").Value().(int) - port
+		}(i)
+	}
+
+	for i := 0; i < 4; i++ {
+		if d := <-done; d != 0 {
+			t.Errorf("Unexpected value of cloned parser")
+		}
+	}
+
+	if opts.Port != 0 {
+		t.Errorf("Expected frozen parser to not be modified")
+	}
+}
+
+type benchmarkOptions struct {
+	Verbose []bool            `short:"v" long:"verbose" description:"Show verbose debug information"`
+	Quiet   bool              `short:"q" long:"quiet"`
+	Port    int               `short:"p" long:"port" default:"8080"`
+	Host    string            `long:"host" default:"localhost"`
+	Ratio   float64           `long:"ratio"`
+	Include []string          `short:"I" long:"include"`
+	Labels  map[string]string `long:"label"`
+}
+
+var benchmarkArgs = []string{"-vvq", "-p", "80", "--host=example.com", "--ratio", "0.5",
+	"-I/usr/include", "-I", "/usr/local/include", "--include=/opt/include", "--label", "a:b", "file"}
+
+func TestParseArgsAllocations(t *testing.T) {
+	var opts benchmarkOptions
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		opts.Verbose = opts.Verbose[:0]
+		opts.Include = opts.Include[:0]
+
+		if _, err := p.ParseArgs(benchmarkArgs); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// The remaining allocations are the remaining arguments, the list of
+	// groups, combined short options and the keys and values of maps
+	if allocs > 10 {
+		t.Errorf("Expected at most 10 allocations but got %v", allocs)
+	}
+}
+
+func BenchmarkParseArgs(b *testing.B) {
+	var opts benchmarkOptions
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		opts.Verbose = opts.Verbose[:0]
+		opts.Include = opts.Include[:0]
+
+		if _, err := p.ParseArgs(benchmarkArgs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseArgsFunc(t *testing.T) {
+	var opts struct {
+		Files []string `short:"f"`
+	}
+
+	p := NewNamedParser("test", PassDoubleDash|IgnoreUnknown, NewGroup("Application Options", &opts))
+
+	var args []string
+
+	for i := 0; i < 20000; i++ {
+		args = append(args, "-f", strconv.Itoa(i), "", "--unknown")
+	}
+
+	args = append(args, "--", "-f")
+
+	n := 0
+	var last string
+
+	err := p.ParseArgsFunc(args, func(arg string) error {
+		n++
+		last = arg
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(opts.Files) != 20000 || n != 40001 || last != "-f" {
+		t.Errorf("Expected 20000 files and 40001 arguments but got %d and %d", len(opts.Files), n)
+	}
+
+	stop := errors.New("stop")
+
+	err = p.ParseArgsFunc([]string{"a", "b"}, func(arg string) error {
+		return stop
+	})
+
+	if err != stop {
+		t.Errorf("Expected the error of the function but got %v", err)
+	}
+}
+
+func TestPassAfterNonOption(t *testing.T) {
+	var opts struct {
+		Verbose bool `short:"v" long:"verbose"`
+	}
+
+	p := NewNamedParser("test", PassAfterNonOption, NewGroup("Application Options", &opts))
+
+	ret, err := p.ParseArgs([]string{"-v", "run", "--verbose", "-x"})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !opts.Verbose || !reflect.DeepEqual(ret, []string{"run", "--verbose", "-x"}) {
+		t.Errorf("Expected the arguments after the first non-option to be passed but got %v", ret)
+	}
+
+	if completions := p.complete([]string{"run", "--"}); completions != nil {
+		t.Errorf("Expected no completions after a non-option but got %v", completions)
+	}
+
+	if completions := p.complete([]string{"-v", "--verb"}); len(completions) != 1 {
+		t.Errorf("Expected the completion of --verbose but got %v", completions)
+	}
+}
+
+func TestParseEnvironment(t *testing.T) {
+	var opts struct {
+		Name string `long:"name" env:"NAME"`
+		Port int    `long:"port" env:"PORT" required:"true"`
+	}
+
+	env := map[string]string{"NAME": "env", "PORT": "80"}
+
+	newParser := func(options Options) *Parser {
+		p := NewNamedParser("test", options, NewGroup("Application Options", &opts))
+
+		p.Stdout = ioutil.Discard
+		p.LookupEnv = func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		}
+
+		return p
+	}
+
+	if _, err := newParser(ParseEnvironment).ParseArgs([]string{"--name", "cli"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Name != "cli" || opts.Port != 80 {
+		t.Errorf("Expected the command line to take precedence over the environment but got %+v", opts)
+	}
+
+	delete(env, "PORT")
+
+	if _, err := newParser(ParseEnvironment).ParseArgs(nil); err == nil {
+		t.Errorf("Expected an error for the missing required option")
+	}
+
+	p := newParser(ParseEnvironment | HelpFlag | HelpNoError)
+
+	if _, err := p.ParseArgs([]string{"--help"}); err != nil || !p.HelpRequested() {
+		t.Errorf("Expected help without validating the options but got %v", err)
+	}
+}
+
+func TestParseStrings(t *testing.T) {
+	var opts struct {
+		Verbose  bool   `short:"v" long:"verbose"`
+		Name     string `short:"n" long:"name" required:"true" prompt:"true"`
+		Password string `long:"password" prompt:"secure"`
+	}
+
+	rest, err := ParseStrings(&opts, []string{"-v", "--name", "x", "file"})
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if !opts.Verbose || opts.Name != "x" || len(rest) != 1 || rest[0] != "file" {
+		t.Errorf("Expected the options to be set but got %+v and %v", opts, rest)
+	}
+
+	// Nothing is printed, and missing values are not prompted for
+	_, err = ParseStrings(&opts, []string{"-\xff", "--\xfe"})
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrUnknownFlag || e.Message != "unknown flag `\xff'" {
+		t.Errorf("Expected an unknown flag error but got %v", err)
+	}
+}
+
+func FuzzParseStrings(f *testing.F) {
+	type options struct {
+		Verbose []bool            `short:"v" long:"verbose"`
+		Name    string            `short:"n" long:"name" default:"name"`
+		Count   int               `short:"c" long:"count" min:"0" max:"10"`
+		Level   uint8             `short:"l" long:"level" optional:"yes" default:"1"`
+		Ratio   float64           `long:"ratio"`
+		Timeout time.Duration     `long:"timeout"`
+		Mode    string            `short:"m" long:"mode" choice:"fast" choice:"slow"`
+		Pattern string            `long:"pattern" pattern:"^[a-z]+$"`
+		Tags    []string          `short:"t" long:"tag" max-occurrences:"3"`
+		Labels  map[string]int    `long:"label"`
+		Secret  string            `long:"secret" secret:"true" expand:"env,home"`
+		Key     string            `long:"key" requires:"name"`
+		Unicode bool              `short:"ü" long:"ünicode"`
+		Hex     int               `long:"hex" base:"16"`
+		Env     map[string]string `long:"env"`
+	}
+
+	for _, seed := range []string{
+		"",
+		"-vvv\x00--name\x00x\x00file",
+		"-c5\x00-l\x00--ratio=1.5\x00--timeout=1s",
+		"-mfast\x00--pattern=abc\x00-t\x00a\x00-tb\x00--label=a:1",
+		"--secret=~/$HOME\x00--key=k\x00-ü\x00--ünicode",
+		"--\x00-v\x00-\x00--=\x00-=\x00--name=",
+		"-\xff\x00--\xfe\x00-v\xc3\x00-n\xc3",
+		"--hex=ff\x00--env=a:b:c\x00--nmae\x00--c",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		var opts options
+
+		args := strings.Split(s, "\x00")
+
+		if _, err := ParseStrings(&opts, args); err != nil {
+			if _, ok := err.(*Error); !ok {
+				t.Errorf("Expected a parser error but got %T: %v", err, err)
+			}
+		}
+	})
+}
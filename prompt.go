@@ -0,0 +1,176 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var promptLayer = newLayer("prompt")
+
+// The input from which answers are read, the output to which prompts are
+// written, whether the input is a terminal and the function disabling the
+// echo of the terminal, when the parser has no Stdin and Stderr. They are
+// variables such that they can be replaced in tests.
+var (
+	promptInput  io.Reader = os.Stdin
+	promptOutput io.Writer = os.Stderr
+
+	promptTerminal = func() bool {
+		return isTerminal(os.Stdin)
+	}
+
+	promptDisableEcho = disableEcho
+)
+
+// promptMissing prompts for the values of the options which were not set by
+// any source, when the input of the parser is interactive. These are the options
+// with the prompt tag and, when the PromptRequired option is set, the
+// required options. Options which are still not set afterwards, e.g.
+// because the input was closed, are reported by validate.
+func (p *Parser) promptMissing() error {
+	if p.isolated {
+		return nil
+	}
+
+	interactive := false
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if !p.shouldPrompt(option) {
+				continue
+			}
+
+			if !interactive {
+				if !p.interactive() {
+					return nil
+				}
+
+				interactive = true
+			}
+
+			if err := p.prompt(option); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) shouldPrompt(option *Option) bool {
+	if option.IsSet() || !option.canArgument() {
+		return false
+	}
+
+	return option.Prompt || (option.Required && (p.Options&PromptRequired) != None)
+}
+
+// prompt asks for the value of the option until a valid value is entered or
+// the input is closed.
+func (p *Parser) prompt(option *Option) error {
+	label := option.description()
+
+	if label == "" {
+		label = option.String()
+	} else {
+		label += " (" + option.String() + ")"
+	}
+
+	if option.Default != "" && !option.Secret {
+		label += " [" + option.Default + "]"
+	}
+
+	for {
+		fmt.Fprintf(p.promptWriter(), "%s: ", label)
+
+		line, err := p.readAnswer(option)
+
+		if err != nil && (err != io.EOF || line == "") {
+			fmt.Fprintln(p.promptWriter())
+			return nil
+		}
+
+		value := strings.TrimRight(line, "\r\n")
+
+		if value == "" {
+			if option.Default == "" {
+				continue
+			}
+
+			value = option.Default
+		}
+
+		origin := Origin{
+			Type: OriginPrompt,
+			Name: option.String(),
+		}
+
+		if !p.claimOption(option, promptLayer, origin) {
+			return nil
+		}
+
+		if err := p.setOption(option, &value); err != nil {
+			option.reset()
+			option.layer = nil
+			option.origin = Origin{}
+
+			fmt.Fprintln(p.promptWriter(), p.formatError(option.marshalError(err)))
+			continue
+		}
+
+		return nil
+	}
+}
+
+// readAnswer reads a line of input. The echo of the terminal is disabled
+// while reading the value of a secret option from the standard input of the
+// process.
+func (p *Parser) readAnswer(option *Option) (string, error) {
+	if !option.Secret || p.Stdin != nil {
+		return readLine(p.stdin())
+	}
+
+	restore, err := promptDisableEcho()
+
+	if err != nil {
+		return readLine(p.stdin())
+	}
+
+	line, err := readLine(p.stdin())
+	restore()
+
+	// The newline ending the input was not echoed either
+	fmt.Fprintln(p.promptWriter())
+
+	return line, err
+}
+
+// readLine reads a line of input, including the newline ending it, if any.
+// The input is read one byte at a time, such that nothing beyond the line is
+// consumed and the next question can read the next line.
+func readLine(reader io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+
+	for {
+		n, err := reader.Read(b)
+
+		if n == 1 {
+			line = append(line, b[0])
+
+			if b[0] == '\n' {
+				return string(line), nil
+			}
+		}
+
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
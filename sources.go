@@ -0,0 +1,505 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The format of a configuration file could not be determined from its
+// extension
+var ErrUnknownConfigFormat = errors.New("unknown configuration file format")
+
+// layer identifies a source of option values. Options record the layer
+// which set their value, such that sources of lower precedence do not
+// override them.
+type layer struct {
+	name string
+
+	// The layer directly above this layer, if any. Values of this layer
+	// are replaced (rather than extended) when the layer above sets them.
+	above *layer
+}
+
+var commandLineLayer = newLayer("command line")
+
+// OriginType is the kind of source from which the value of an option was
+// obtained.
+type OriginType uint
+
+const (
+	// The option was not set by any source and has the value of its
+	// struct field
+	OriginDefault OriginType = iota
+
+	// The option was specified on the command line
+	OriginCommandLine
+
+	// The option was set from an environment variable, either of the
+	// process or defined in a dotenv file
+	OriginEnvironment
+
+	// The option was set from a configuration file
+	OriginFile
+
+	// The value of the option was entered at a prompt (see PromptRequired)
+	OriginPrompt
+)
+
+// Get a human friendly readable name of the origin type.
+func (t OriginType) String() string {
+	switch t {
+	case OriginCommandLine:
+		return "command line"
+	case OriginEnvironment:
+		return "environment"
+	case OriginFile:
+		return "file"
+	case OriginPrompt:
+		return "prompt"
+	}
+
+	return "default"
+}
+
+// Origin describes where the value of an option came from.
+type Origin struct {
+	// The kind of source
+	Type OriginType
+
+	// The name of the configuration file or dotenv file from which the
+	// value was read, if any
+	File string
+
+	// The flag as specified on the command line (e.g. --verbose), the name
+	// of the environment variable or the key of the option in the
+	// configuration file (e.g. Application Options.verbose)
+	Name string
+}
+
+// Get a human friendly readable description of the origin.
+func (o Origin) String() string {
+	switch o.Type {
+	case OriginCommandLine:
+		return fmt.Sprintf("command line (%s)", o.Name)
+	case OriginEnvironment:
+		if o.File != "" {
+			return fmt.Sprintf("%s ($%s)", o.File, o.Name)
+		}
+
+		return fmt.Sprintf("environment ($%s)", o.Name)
+	case OriginFile:
+		if o.File != "" {
+			return fmt.Sprintf("%s (%s)", o.File, o.Name)
+		}
+
+		return fmt.Sprintf("configuration (%s)", o.Name)
+	case OriginPrompt:
+		return fmt.Sprintf("prompt (%s)", o.Name)
+	}
+
+	return "default"
+}
+
+func newLayer(name string) *layer {
+	return &layer{
+		name: name,
+	}
+}
+
+// newConfigLayer returns the layer of a configuration file which is parsed
+// on its own (e.g. by IniParser.Parse or Parser.ParseJSON), whose values are
+// replaced by values given later on the command line, and edited by values
+// given as --name+=value or --name-=value.
+func newConfigLayer(filename string) *layer {
+	l := newLayer(filename)
+	l.above = commandLineLayer
+
+	return l
+}
+
+// claimOption returns whether the given layer may set the value of the
+// option and if so, records the layer and origin as the source of the option
+// value.
+// Within Parser.ParseSources, only the source which first set an option may
+// set it again. Otherwise, the command line always overrides other sources,
+// while other sources only set options which were not set yet. Values set
+// by a layer directly beneath the given layer are always replaced.
+func (p *Parser) claimOption(option *Option, l *layer, origin Origin) bool {
+	if !option.allowsOrigin(origin.Type) {
+		p.warn(newOptionError(ErrRestricted, option,
+			fmt.Sprintf("ignoring flag `%s' from %s, which cannot set it", option, origin)))
+		return false
+	}
+
+	if p.layer != nil {
+		l = p.layer
+	}
+
+	switch {
+	case option.layer == nil:
+		option.clearDefault()
+	case option.layer == l:
+	case option.layer.above == l:
+		option.reset()
+	case l == commandLineLayer:
+	default:
+		p.trace("ignoring %s from %s, already set by %s", option, origin, option.origin)
+		return false
+	}
+
+	option.layer = l
+	option.origin = origin
+	return true
+}
+
+// A Source provides values for options. Sources are combined using
+// Parser.ParseSources.
+type Source interface {
+	apply(p *Parser) ([]string, error)
+}
+
+type commandLineSource []string
+
+// CommandLineSource creates a source which parses the given command line
+// arguments (see Parser.ParseArgs). The remaining, non-option, arguments are
+// returned by Parser.ParseSources.
+func CommandLineSource(args []string) Source {
+	return commandLineSource(args)
+}
+
+func (s commandLineSource) apply(p *Parser) ([]string, error) {
+	return p.ParseArgs(s)
+}
+
+type environmentSource struct{}
+
+// EnvironmentSource creates a source which sets options from the
+// environment variables named by their env tags. Options without an
+// argument are set when the variable contains a true value (e.g. 1, true or
+// yes). When the variable of an option is not set but the variable with the
+// _FILE suffix is (e.g. DB_PASSWORD_FILE for DB_PASSWORD), the value is
+// read from the file it names, following the convention of docker images
+// for secrets.
+func EnvironmentSource() Source {
+	return environmentSource{}
+}
+
+func (s environmentSource) apply(p *Parser) ([]string, error) {
+	return nil, p.applyEnvironment(p.lookupEnv, "")
+}
+
+type dotEnvSource struct {
+	filename string
+	optional bool
+}
+
+// DotEnvSource creates a source which sets options like EnvironmentSource,
+// using the variables defined in a dotenv file (see ParseDotEnv) instead of
+// the environment of the process. Combine it with EnvironmentSource to let
+// the environment take precedence over a .env file:
+//
+//	args, err := parser.ParseSources(
+//	    flags.CommandLineSource(os.Args[1:]),
+//	    flags.EnvironmentSource(),
+//	    flags.OptionalDotEnvSource(".env"),
+//	)
+func DotEnvSource(filename string) Source {
+	return dotEnvSource{
+		filename: filename,
+	}
+}
+
+// OptionalDotEnvSource creates a source like DotEnvSource, except that it
+// is not an error when the file does not exist.
+func OptionalDotEnvSource(filename string) Source {
+	return dotEnvSource{
+		filename: filename,
+		optional: true,
+	}
+}
+
+func (s dotEnvSource) apply(p *Parser) ([]string, error) {
+	vars, err := ParseDotEnvFile(s.filename)
+
+	if err != nil {
+		if s.optional && os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	lookup := func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+
+	return nil, p.applyEnvironment(lookup, s.filename)
+}
+
+// applyEnvironment sets options from the variables provided by lookup. The
+// filename is the dotenv file defining the variables, if any.
+func (p *Parser) applyEnvironment(lookup func(string) (string, bool), filename string) error {
+	l := newLayer("environment")
+
+	if filename != "" {
+		l = newLayer(filename)
+	}
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if option.EnvName == "" {
+				continue
+			}
+
+			value, ok := lookup(option.EnvName)
+
+			origin := Origin{
+				Type: OriginEnvironment,
+				File: filename,
+				Name: option.EnvName,
+			}
+
+			// Fall back to a variable naming a file which contains the
+			// value, as used by docker images for secrets
+			var valueFile string
+
+			if !ok {
+				if valueFile, ok = lookup(option.EnvName + "_FILE"); ok {
+					origin.File = valueFile
+					origin.Name = option.EnvName + "_FILE"
+				}
+			}
+
+			if !ok || !p.claimOption(option, l, origin) {
+				continue
+			}
+
+			if valueFile != "" {
+				data, err := os.ReadFile(valueFile)
+
+				if err != nil {
+					return &ConfigError{
+						Message: fmt.Sprintf("failed to read the value of $%s: %s", option.EnvName, err),
+						File:    filename,
+						Key:     "$" + origin.Name,
+						Err:     err,
+					}
+				}
+
+				value = strings.TrimSpace(string(data))
+			}
+
+			values := []string{value}
+
+			if option.EnvDelim != "" {
+				values = strings.Split(value, option.EnvDelim)
+			}
+
+			for _, v := range values {
+				if err := p.setConfigValue(option, v); err != nil {
+					return &ConfigError{
+						Message: err.Error(),
+						File:    filename,
+						Key:     "$" + option.EnvName,
+						Err:     err,
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+type fileSource struct {
+	filename string
+	optional bool
+}
+
+// FileSource creates a source which reads option values from a
+// configuration file. The format of the file is determined by its
+// extension: .ini, .yaml or .yml, .toml and .json are supported.
+func FileSource(filename string) Source {
+	return fileSource{
+		filename: filename,
+	}
+}
+
+// OptionalFileSource creates a source like FileSource, except that it is
+// not an error when the file does not exist.
+func OptionalFileSource(filename string) Source {
+	return fileSource{
+		filename: filename,
+		optional: true,
+	}
+}
+
+func (s fileSource) apply(p *Parser) ([]string, error) {
+	if s.optional {
+		if _, err := os.Stat(s.filename); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(s.filename)) {
+	case ".ini":
+		return nil, NewIniParser(p).ParseFile(s.filename)
+	case ".yaml", ".yml":
+		return nil, p.ParseYAMLFile(s.filename)
+	case ".toml":
+		return nil, p.ParseTOMLFile(s.filename)
+	case ".json":
+		return nil, p.ParseJSONFile(s.filename)
+	}
+
+	return nil, ErrUnknownConfigFormat
+}
+
+// ParseSources sets the values of options from multiple sources, in order
+// of decreasing precedence. An option which is set by a source is not
+// modified by any of the sources which follow it, such that values are
+// resolved consistently. Options which are not set by any source keep the
+// value of their struct field. For example, to give the command line
+// precedence over the environment, which in turn takes precedence over a
+// configuration file:
+//
+//	args, err := parser.ParseSources(
+//	    flags.CommandLineSource(os.Args[1:]),
+//	    flags.EnvironmentSource(),
+//	    flags.OptionalFileSource("/etc/myprog.yaml"),
+//	)
+//
+// The remaining command line arguments of the command line source (if any)
+// are returned. Parsing stops at the first error.
+func (p *Parser) ParseSources(sources ...Source) ([]string, error) {
+	var ret []string
+	p.helpRequested = false
+
+	if p.frozen {
+		return nil, ErrFrozen
+	}
+
+	if err := p.groupError(); err != nil {
+		return nil, err
+	}
+
+	if p.flat == nil {
+		p.flat = p.groups()
+	}
+
+	p.resetOccurrences()
+
+	defer func() {
+		p.layer = nil
+		p.flat = nil
+		p.edits = nil
+	}()
+
+	for _, source := range sources {
+		p.layer = newLayer("source")
+
+		args, err := source.apply(p)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Nothing else is done once help was shown (see HelpNoError)
+		if p.helpRequested {
+			return nil, nil
+		}
+
+		if _, ok := source.(commandLineSource); ok {
+			ret = args
+		}
+	}
+
+	if err := p.applyDefaults(); err != nil {
+		return nil, p.printError(err)
+	}
+
+	if err := p.applyEdits(); err != nil {
+		return nil, p.printError(err)
+	}
+
+	if err := p.promptMissing(); err != nil {
+		return nil, p.printError(err)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, p.printError(err)
+	}
+
+	return ret, nil
+}
+
+// applyDefaults sets the options which were not set by any source and have
+// a DefaultFunc to the value returned by the function.
+func (p *Parser) applyDefaults() error {
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if option.DefaultFunc == nil || option.IsSet() || option.isFunc() {
+				continue
+			}
+
+			value, err := option.DefaultFunc()
+
+			if err != nil {
+				return newOptionError(ErrUnknown, option,
+					fmt.Sprintf("failed to determine the default value of flag `%s': %s", option, err)).wrap(err)
+			}
+
+			option.reset()
+
+			if err := option.marshalError(convert(value, option.value, option.options)); err != nil {
+				return err
+			}
+
+			option.store()
+			p.traceSet(option)
+		}
+	}
+
+	return nil
+}
+
+// allowsOrigin returns whether the option may be set by a source of the
+// given type, according to the sources tag of the option: a comma separated
+// list of cli, env and config. Options without the tag may be set by any
+// source.
+func (option *Option) allowsOrigin(tp OriginType) bool {
+	sources := option.options.Get("sources")
+
+	if sources == "" {
+		return true
+	}
+
+	var name string
+
+	switch tp {
+	case OriginCommandLine:
+		name = "cli"
+	case OriginEnvironment:
+		name = "env"
+	case OriginFile:
+		name = "config"
+	default:
+		return true
+	}
+
+	for _, source := range strings.Split(sources, ",") {
+		if strings.TrimSpace(source) == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,316 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Spec is a machine readable description of the options of a parser (see
+// Parser.Spec), from which external tools can generate user interfaces,
+// forms or clients.
+type Spec struct {
+	// The application name and usage of the parser
+	Name  string `json:"name"`
+	Usage string `json:"usage,omitempty"`
+
+	// The groups of the parser, including sub-groups, in the order in
+	// which they are shown in the help message
+	Groups []GroupSpec `json:"groups"`
+}
+
+// GroupSpec describes a group of options (see Spec).
+type GroupSpec struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Options     []OptionSpec `json:"options"`
+}
+
+// OptionSpec describes an option (see Spec).
+type OptionSpec struct {
+	LongName     string   `json:"long,omitempty"`
+	ShortName    string   `json:"short,omitempty"`
+	LongAliases  []string `json:"longAliases,omitempty"`
+	ShortAliases []string `json:"shortAliases,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	ValueName    string   `json:"valueName,omitempty"`
+
+	LongDescription string `json:"longDescription,omitempty"`
+
+	// The Go type of the option (e.g. int, []string or time.Duration)
+	Type string `json:"type"`
+
+	// The JSON Schema of the value of the option in JSON configuration
+	// files (see Parser.ParseJSON)
+	Schema map[string]interface{} `json:"schema"`
+
+	// Whether the option takes an argument, optionally, and whether the
+	// option can be specified more than once
+	HasArgument      bool `json:"hasArgument"`
+	OptionalArgument bool `json:"optionalArgument,omitempty"`
+	Repeatable       bool `json:"repeatable,omitempty"`
+
+	// The value of the option when it is not specified, or the value used
+	// when the option is specified without its optional argument. Defaults
+	// of secret options and masked defaults (see Option.DefaultMask) are
+	// not included
+	Default       interface{} `json:"default,omitempty"`
+	OptionalValue string      `json:"optionalValue,omitempty"`
+	DefaultMask   string      `json:"defaultMask,omitempty"`
+
+	Choices    []string `json:"choices,omitempty"`
+	Required   bool     `json:"required,omitempty"`
+	Secret     bool     `json:"secret,omitempty"`
+	Deprecated string   `json:"deprecated,omitempty"`
+
+	// The environment variable of the option and its delimiter
+	EnvName  string `json:"env,omitempty"`
+	EnvDelim string `json:"envDelim,omitempty"`
+
+	// The long names of the options required by the option, and the kinds
+	// of sources which may set the option (see the sources tag)
+	Requires []string `json:"requires,omitempty"`
+	Sources  []string `json:"sources,omitempty"`
+}
+
+// Spec returns a description of the options of the parser, including the
+// builtin help option.
+func (p *Parser) Spec() Spec {
+	p.addHelpGroup()
+
+	ret := Spec{
+		Name:   p.ApplicationName,
+		Usage:  p.Usage,
+		Groups: []GroupSpec{},
+	}
+
+	for _, grp := range p.groups() {
+		g := GroupSpec{
+			Name:        grp.Name,
+			Description: grp.Description,
+			Options:     []OptionSpec{},
+		}
+
+		for _, option := range grp.Options {
+			g.Options = append(g.Options, option.spec())
+		}
+
+		ret.Groups = append(ret.Groups, g)
+	}
+
+	return ret
+}
+
+// WriteSpec writes the description of the options of the parser (see
+// Parser.Spec) as JSON.
+func (p *Parser) WriteSpec(writer io.Writer) error {
+	return writeJSON(writer, p.Spec())
+}
+
+// WriteJSONSchema writes a JSON Schema describing the JSON documents read by
+// Parser.ParseJSON, in which the options are given by group, such that
+// configuration files can be validated and edited by generic tools.
+// Function options, which cannot be set by configuration files, are not
+// included.
+func (p *Parser) WriteJSONSchema(writer io.Writer) error {
+	p.addHelpGroup()
+
+	properties := make(map[string]interface{})
+
+	for _, grp := range p.groups() {
+		options := make(map[string]interface{})
+		var required []string
+
+		for _, option := range grp.Options {
+			if option.isFunc() || option.sharesField() {
+				continue
+			}
+
+			options[option.configKey()] = option.schema()
+
+			if option.Required {
+				required = append(required, option.configKey())
+			}
+		}
+
+		if len(options) == 0 {
+			continue
+		}
+
+		group := map[string]interface{}{
+			"type":                 "object",
+			"properties":           options,
+			"additionalProperties": false,
+		}
+
+		if grp.Description != "" {
+			group["description"] = grp.Description
+		}
+
+		if required != nil {
+			group["required"] = required
+		}
+
+		properties[grp.Name] = group
+	}
+
+	return writeJSON(writer, map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      p.ApplicationName,
+		"type":       "object",
+		"properties": properties,
+	})
+}
+
+// spec returns the description of the option.
+func (option *Option) spec() OptionSpec {
+	tp := option.value.Type()
+
+	ret := OptionSpec{
+		LongName:         option.LongName,
+		LongAliases:      option.LongAliases,
+		Description:      option.description(),
+		ValueName:        option.ValueName,
+		Type:             tp.String(),
+		HasArgument:      option.canArgument(),
+		OptionalArgument: option.OptionalArgument,
+		Repeatable:       option.isRepeatable(),
+		DefaultMask:      option.DefaultMask,
+		Choices:          option.Choices,
+		Required:         option.Required,
+		Secret:           option.Secret,
+		Deprecated:       option.options.Get("deprecated"),
+		EnvName:          option.EnvName,
+		EnvDelim:         option.EnvDelim,
+		Requires:         option.options.values("requires"),
+	}
+
+	if option.ShortName != 0 {
+		ret.ShortName = string(option.ShortName)
+	}
+
+	for _, alias := range option.ShortAliases {
+		ret.ShortAliases = append(ret.ShortAliases, string(alias))
+	}
+
+	if sources := option.options.Get("sources"); sources != "" {
+		for _, source := range strings.Split(sources, ",") {
+			ret.Sources = append(ret.Sources, strings.TrimSpace(source))
+		}
+	}
+
+	if option.OptionalArgument {
+		ret.OptionalValue = option.Default
+	} else if !option.Secret {
+		ret.Default = option.specDefault()
+	}
+
+	ret.LongDescription, _ = option.expandDescription(option.LongDescription)
+	ret.Schema = option.schema()
+	return ret
+}
+
+// schema returns the JSON Schema of the value of the option.
+func (option *Option) schema() map[string]interface{} {
+	tp := option.value.Type()
+
+	if option.isFunc() {
+		if tp.NumIn() == 1 {
+			tp = tp.In(0)
+		} else {
+			tp = reflect.TypeOf(false)
+		}
+	}
+
+	ret := typeSchema(tp)
+
+	if option.Description != "" {
+		ret["description"] = option.description()
+	}
+
+	// The constraints apply to the elements of slices and maps
+	elem := ret
+
+	if tp.Kind() == reflect.Slice {
+		elem = ret["items"].(map[string]interface{})
+	} else if tp.Kind() == reflect.Map {
+		elem = ret["additionalProperties"].(map[string]interface{})
+	}
+
+	if len(option.Choices) != 0 {
+		elem["enum"] = option.Choices
+	}
+
+	if pattern := option.options.Get("pattern"); pattern != "" {
+		elem["pattern"] = pattern
+	}
+
+	if elem["type"] == "integer" || elem["type"] == "number" {
+		if f, err := strconv.ParseFloat(option.options.Get("min"), 64); err == nil {
+			elem["minimum"] = f
+		}
+
+		if f, err := strconv.ParseFloat(option.options.Get("max"), 64); err == nil {
+			elem["maximum"] = f
+		}
+	}
+
+	if option.Secret {
+		ret["writeOnly"] = true
+	} else if def := option.specDefault(); def != nil {
+		ret["default"] = def
+	}
+
+	if option.options.Get("deprecated") != "" {
+		ret["deprecated"] = true
+	}
+
+	return ret
+}
+
+// specDefault returns the default value of the option, or nil if it has no
+// default which can be shown.
+func (option *Option) specDefault() interface{} {
+	if option.OptionalArgument || option.DefaultMask != "" || option.isFunc() || option.initial.IsZero() {
+		return nil
+	}
+
+	return jsonValue(option.initial)
+}
+
+// typeSchema returns the JSON Schema of values of the given type, as read
+// from JSON configuration files.
+func typeSchema(tp reflect.Type) map[string]interface{} {
+	if _, ok := lookupConverter(tp); ok || tp == getterType {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	if tp == durationType {
+		return map[string]interface{}{
+			"type":    "string",
+			"pattern": `^[-+]?([0-9]*(\.[0-9]*)?[a-zµμ]+)+$|^0$`,
+		}
+	}
+
+	switch tp.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(tp.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(tp.Elem())}
+	}
+
+	return map[string]interface{}{"type": "string"}
+}
@@ -0,0 +1,102 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// stdin returns the input from which prompts are answered.
+func (p *Parser) stdin() io.Reader {
+	if p.Stdin != nil {
+		return p.Stdin
+	}
+
+	return promptInput
+}
+
+// stdout returns the writer to which the help message is printed when help
+// is requested (see HelpNoError) and to which completions are printed.
+func (p *Parser) stdout() io.Writer {
+	if p.Stdout != nil {
+		return p.Stdout
+	}
+
+	return os.Stdout
+}
+
+// stderr returns the writer to which traces are printed.
+func (p *Parser) stderr() io.Writer {
+	if p.Stderr != nil {
+		return p.Stderr
+	}
+
+	return os.Stderr
+}
+
+// promptWriter returns the writer to which prompts are printed.
+func (p *Parser) promptWriter() io.Writer {
+	if p.Stderr != nil {
+		return p.Stderr
+	}
+
+	return promptOutput
+}
+
+// interactive returns whether questions can be asked on the input of the
+// parser: the standard input of the process or a file must be a terminal,
+// while other readers are assumed to be interactive.
+func (p *Parser) interactive() bool {
+	if p.Stdin == nil {
+		return promptTerminal()
+	}
+
+	file, ok := p.Stdin.(*os.File)
+
+	if !ok {
+		return true
+	}
+
+	if p.IsTerminal != nil {
+		return p.IsTerminal(file)
+	}
+
+	return isTerminal(file)
+}
+
+// lookupEnv looks up an environment variable using the LookupEnv function
+// of the parser, or in the environment of the process.
+func (p *Parser) lookupEnv(name string) (string, bool) {
+	if p.LookupEnv != nil {
+		return p.LookupEnv(name)
+	}
+
+	return os.LookupEnv(name)
+}
+
+// homeDir returns the home directory of the user. With a LookupEnv function,
+// it is given by the HOME (or USERPROFILE) variable.
+func (p *Parser) homeDir() (string, error) {
+	if p.LookupEnv == nil {
+		return os.UserHomeDir()
+	}
+
+	for _, name := range []string{"HOME", "USERPROFILE"} {
+		if dir, ok := p.LookupEnv(name); ok && dir != "" {
+			return dir, nil
+		}
+	}
+
+	return "", errors.New("the home directory is not defined in the environment")
+}
+
+// getenv returns the value of an environment variable (see lookupEnv), or
+// "" if it is not set.
+func (p *Parser) getenv(name string) string {
+	value, _ := p.lookupEnv(name)
+	return value
+}
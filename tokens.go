@@ -0,0 +1,334 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"iter"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenType is the type of a Token.
+type TokenType uint
+
+const (
+	// TokenOption is an option, together with its argument if the option
+	// takes one.
+	TokenOption TokenType = iota
+
+	// TokenPositional is a remaining, non-option, argument.
+	TokenPositional
+)
+
+// Token is a single event produced by splitting command line arguments into
+// options and positional arguments (see Parser.Tokens).
+type Token struct {
+	// The type of the token
+	Type TokenType
+
+	// The matched option. Option is nil for positional arguments and for
+	// unknown flags
+	Option *Option
+
+	// The flag as given on the command line (e.g. -v or --verbose)
+	Flag string
+
+	// The argument of the option, if HasValue is true
+	Value    string
+	HasValue bool
+
+	// The operator of arguments of slice and map options given as
+	// --name+=value or --name-=value: "+" adds the argument to the values
+	// of the option and "-" removes it, while "" sets the option
+	Operator string
+
+	// The command line argument from which the token was produced, without
+	// any argument given after =. For positional arguments, this is the
+	// argument itself
+	Arg string
+
+	// The index in the command line arguments of Arg
+	Index int
+}
+
+// Tokens returns an iterator over the tokens of the given command line
+// arguments. Each token is a matched option with its argument, or a
+// positional argument. Errors, such as unknown flags or missing arguments,
+// are yielded together with the token at which they occurred, after which
+// iteration continues with the next argument.
+//
+// Tokens does not set any option. The tokens can be applied to the parser
+// using Apply, which allows embedders such as shells or REPLs to drive
+// parsing incrementally, and to skip, reorder or interleave tokens with their
+// own processing. Unlike ParseArgs, lazy defaults are not applied and
+// constraints between options are not checked.
+//
+// For example:
+//
+//	for token, err := range parser.Tokens(args) {
+//		if err == nil {
+//			err = parser.Apply(token)
+//		}
+//
+//		if err != nil {
+//			return err
+//		}
+//	}
+func (p *Parser) Tokens(args []string) iter.Seq2[Token, error] {
+	return func(yield func(Token, error) bool) {
+		if err := p.groupError(); err != nil {
+			yield(Token{}, err)
+			return
+		}
+
+		p.addHelpGroup()
+
+		if p.flat == nil {
+			p.flat = p.groups()
+
+			defer func() {
+				p.flat = nil
+			}()
+		}
+
+		t := tokenizer{
+			p:    p,
+			args: args,
+		}
+
+		for {
+			token, err, ok := t.next()
+
+			if !ok || !yield(token, err) {
+				return
+			}
+		}
+	}
+}
+
+// Apply sets the option of an option token, as if it was specified on the
+// command line. Positional tokens and tokens of unknown flags are ignored.
+func (p *Parser) Apply(token Token) error {
+	if p.frozen {
+		return ErrFrozen
+	}
+
+	if token.Type != TokenOption || token.Option == nil {
+		return nil
+	}
+
+	return p.setCommandLine(token.Option, token.Flag, token.Operator, token.value())
+}
+
+// value returns the argument of the option, or nil if there is none.
+func (t *Token) value() *string {
+	if !t.HasValue {
+		return nil
+	}
+
+	return &t.Value
+}
+
+// A tokenizer splits command line arguments into tokens, one token at a time.
+// Several tokens are produced from combined short options (e.g. -aux).
+type tokenizer struct {
+	p     *Parser
+	args  []string
+	index int
+
+	// The index, argument and remaining short options of the short options
+	// being split, and the argument given after =
+	start       int
+	arg         string
+	short       string
+	argument    string
+	hasArgument bool
+
+	// Whether all the remaining arguments are positional
+	dashdash bool
+}
+
+// next returns the next token, and false when all the arguments have been
+// consumed.
+func (t *tokenizer) next() (Token, error, bool) {
+	if t.short != "" {
+		return t.nextShort()
+	}
+
+	p := t.p
+
+	for t.index < len(t.args) {
+		start := t.index
+		arg := t.args[start]
+		t.index++
+
+		if t.dashdash {
+			return Token{Type: TokenPositional, Arg: arg, Index: start}, nil, true
+		}
+
+		if p.tracing() {
+			p.trace("argument `%s'", p.maskArgument(arg))
+		}
+
+		// When PassDoubleDash is set and we encounter a --, then
+		// simply pass all the rest as arguments
+		if (p.Options&PassDoubleDash) != None && arg == "--" {
+			t.dashdash = true
+			continue
+		}
+
+		// If the argument is not an option, then pass it on, along with
+		// all the rest when PassAfterNonOption is set
+		if arg == "" || arg[0] != '-' {
+			t.dashdash = (p.Options & PassAfterNonOption) != None
+			return Token{Type: TokenPositional, Arg: arg, Index: start}, nil, true
+		}
+
+		var argument string
+		pos := strings.Index(arg, "=")
+
+		if pos >= 0 {
+			argument = arg[pos+1:]
+			arg = arg[:pos]
+		}
+
+		if strings.HasPrefix(arg, "--") {
+			token, err, index := p.parseLong(t.args, arg, argument, pos >= 0, t.index)
+
+			t.index = index
+			token.Arg = arg
+			token.Index = start
+
+			return token, err, true
+		}
+
+		// A single dash does not specify any option
+		if len(arg) == 1 {
+			continue
+		}
+
+		t.start = start
+		t.arg = arg
+		t.short = arg[1:]
+		t.argument = argument
+		t.hasArgument = pos >= 0
+
+		return t.nextShort()
+	}
+
+	return Token{}, nil, false
+}
+
+// maskArgument returns a command line argument for use in traces, in which
+// the argument of a secret option (see Option.Secret) given after = or
+// combined with its short name (e.g. -pvalue) is masked.
+func (p *Parser) maskArgument(arg string) string {
+	if arg == "" || arg[0] != '-' {
+		return arg
+	}
+
+	pos := strings.Index(arg, "=")
+
+	if strings.HasPrefix(arg, "--") {
+		if pos < 0 {
+			return arg
+		}
+
+		name := strings.TrimRight(arg[2:pos], "+-")
+
+		for _, grp := range p.groups() {
+			if option := grp.LongNames[name]; option != nil && option.Secret {
+				return arg[:pos+1] + "***"
+			}
+		}
+
+		return arg
+	}
+
+	short := arg[1:]
+
+	if pos >= 0 {
+		short = arg[1:pos]
+	}
+
+	for i := 0; i < len(short); {
+		c, clen := decodeShort(short[i:])
+		option, _ := p.getShort(c)
+
+		if option == nil {
+			break
+		}
+
+		i += clen
+
+		if option.Secret && option.canArgument() && len(arg) > 1+i {
+			if pos >= 0 && i == len(short) {
+				return arg[:pos+1] + "***"
+			}
+
+			return arg[:1+i] + "***"
+		}
+	}
+
+	return arg
+}
+
+// nextShort returns the token of the next of the combined short options.
+func (t *tokenizer) nextShort() (Token, error, bool) {
+	p := t.p
+
+	c, clen := decodeShort(t.short)
+	islast := clen == len(t.short)
+	argument, hasArgument := t.argument, t.hasArgument
+
+	if !islast && !hasArgument {
+		next, _ := decodeShort(t.short[clen:])
+		info, _ := p.getShort(c)
+
+		if info != nil && info.canArgument() {
+			if snext, _ := p.getShort(next); snext == nil {
+				// Consider the next stuff as an argument
+				argument, hasArgument = t.short[clen:], true
+				islast = true
+			}
+		}
+	}
+
+	// Reuse the argument as the flag unless multiple short options are
+	// combined
+	flag := t.arg
+
+	if clen != len(t.arg)-1 {
+		flag = "-" + t.short[:clen]
+	}
+
+	token, err, index := p.parseShort(t.args, flag, c, islast, argument, hasArgument, t.index)
+
+	t.index = index
+	token.Arg = t.arg
+	token.Index = t.start
+
+	if err != nil || islast {
+		t.short = ""
+	} else {
+		t.short = t.short[clen:]
+	}
+
+	return token, err, true
+}
+
+// decodeShort decodes the first short option name of s. Bytes which are not
+// valid UTF-8 are returned as a name of -1, which does not match any option,
+// instead of as utf8.RuneError, which could match an option named by the
+// replacement character.
+func decodeShort(s string) (rune, int) {
+	c, clen := utf8.DecodeRuneInString(s)
+
+	if c == utf8.RuneError && clen <= 1 {
+		return -1, clen
+	}
+
+	return c, clen
+}
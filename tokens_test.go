@@ -0,0 +1,76 @@
+package flags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	var opts struct {
+		Verbose bool   `short:"v"`
+		Port    int    `short:"p"`
+		Host    string `long:"host"`
+	}
+
+	p := NewNamedParser("test", PassDoubleDash, NewGroup("Application Options", &opts))
+
+	args := []string{"-vp80", "file", "--unknown", "--host=example.com", "--", "-v"}
+
+	type token struct {
+		tp    TokenType
+		flag  string
+		value string
+		index int
+		err   ErrorType
+	}
+
+	expected := []token{
+		{TokenOption, "-v", "", 0, ErrUnknown},
+		{TokenOption, "-p", "80", 0, ErrUnknown},
+		{TokenPositional, "", "file", 1, ErrUnknown},
+		{TokenOption, "--unknown", "", 2, ErrUnknownFlag},
+		{TokenOption, "--host", "example.com", 3, ErrUnknown},
+		{TokenPositional, "", "-v", 5, ErrUnknown},
+	}
+
+	var tokens []token
+
+	for tok, err := range p.Tokens(args) {
+		tk := token{tok.Type, tok.Flag, tok.Value, tok.Index, ErrUnknown}
+
+		if tok.Type == TokenPositional {
+			tk.value = tok.Arg
+		}
+
+		if err != nil {
+			tk.err = err.(*Error).Type
+		} else if err := p.Apply(tok); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		tokens = append(tokens, tk)
+	}
+
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected tokens %v but got %v", expected, tokens)
+	}
+
+	if !opts.Verbose || opts.Port != 80 || opts.Host != "example.com" {
+		t.Errorf("Expected the tokens to be applied but got %+v", opts)
+	}
+
+	if o := p.Groups[0].LongNames["host"]; !o.IsSet() || o.Origin().Type != OriginCommandLine {
+		t.Errorf("Expected host to be set from the command line")
+	}
+
+	n := 0
+
+	for range p.Tokens(args) {
+		n++
+		break
+	}
+
+	if n != 1 {
+		t.Errorf("Expected iteration to stop after the first token")
+	}
+}
@@ -0,0 +1,562 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseTOMLFile reads option values from a TOML file. See Parser.ParseTOML
+// for more information.
+func (p *Parser) ParseTOMLFile(filename string) error {
+	fp, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer fp.Close()
+
+	return p.parseTOML(fp, filename)
+}
+
+// ParseTOML reads option values from a TOML document. Tables correspond to
+// option groups (case insensitive, quote names containing spaces) and keys
+// to long option names. Keys outside of any table are looked up in all
+// groups. For example:
+//
+//	verbose = true
+//
+//	["Application Options"]
+//	include = ["/usr/include", "/usr/local/include"]
+//
+// Arrays set options which can be specified more than once (e.g. slices)
+// once for each element, and inline tables or sub tables set map options.
+// As with ParseYAML, the values of options which were already specified on
+// the command line are not changed.
+//
+// Arrays of tables are not supported. Date and time values are passed to
+// options verbatim. The returned error is of type *ConfigError when the
+// document could not be parsed or an option could not be set.
+func (p *Parser) ParseTOML(reader io.Reader) error {
+	return p.parseTOML(reader, "")
+}
+
+func (p *Parser) parseTOML(reader io.Reader, filename string) error {
+	values, err := decodeTOML(reader, filename)
+
+	if err != nil {
+		return err
+	}
+
+	return p.applyConfig(values, filename)
+}
+
+type tomlDecoder struct {
+	data     string
+	pos      int
+	filename string
+}
+
+func decodeTOML(reader io.Reader, filename string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadAll(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := &tomlDecoder{
+		data:     string(data),
+		filename: filename,
+	}
+
+	return d.parseDocument()
+}
+
+func (d *tomlDecoder) errorf(format string, a ...interface{}) error {
+	return &ConfigError{
+		Message:    fmt.Sprintf(format, a...),
+		File:       d.filename,
+		LineNumber: uint(strings.Count(d.data[:d.pos], "\n") + 1),
+	}
+}
+
+func (d *tomlDecoder) eof() bool {
+	return d.pos >= len(d.data)
+}
+
+func (d *tomlDecoder) peek() byte {
+	if d.eof() {
+		return 0
+	}
+
+	return d.data[d.pos]
+}
+
+// skipSpace skips spaces and tabs, and if newlines is true also newlines and
+// comments.
+func (d *tomlDecoder) skipSpace(newlines bool) {
+	for !d.eof() {
+		switch d.peek() {
+		case ' ', '\t':
+			d.pos++
+		case '\r', '\n':
+			if !newlines {
+				return
+			}
+
+			d.pos++
+		case '#':
+			if !newlines {
+				return
+			}
+
+			for !d.eof() && d.peek() != '\n' {
+				d.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// expectEndOfLine skips trailing white space and a comment, and errors on
+// anything else before the end of the line.
+func (d *tomlDecoder) expectEndOfLine() error {
+	d.skipSpace(false)
+
+	if d.peek() == '#' {
+		for !d.eof() && d.peek() != '\n' {
+			d.pos++
+		}
+	}
+
+	if d.peek() == '\r' {
+		d.pos++
+	}
+
+	if d.eof() {
+		return nil
+	}
+
+	if d.peek() != '\n' {
+		return d.errorf("expected end of line")
+	}
+
+	d.pos++
+	return nil
+}
+
+func (d *tomlDecoder) parseDocument() (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	table := root
+
+	for {
+		d.skipSpace(true)
+
+		if d.eof() {
+			return root, nil
+		}
+
+		if d.peek() == '[' {
+			if strings.HasPrefix(d.data[d.pos:], "[[") {
+				return nil, d.errorf("arrays of tables are not supported")
+			}
+
+			d.pos++
+			d.skipSpace(false)
+
+			path, err := d.parseKey()
+
+			if err != nil {
+				return nil, err
+			}
+
+			d.skipSpace(false)
+
+			if d.peek() != ']' {
+				return nil, d.errorf("expected `]' after table name")
+			}
+
+			d.pos++
+
+			if table, err = d.table(root, path); err != nil {
+				return nil, err
+			}
+		} else if err := d.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+
+		if err := d.expectEndOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// table returns the (possibly new) table at the given path.
+func (d *tomlDecoder) table(root map[string]interface{}, path []string) (map[string]interface{}, error) {
+	table := root
+
+	for _, key := range path {
+		value, ok := table[key]
+
+		if !ok {
+			value = make(map[string]interface{})
+			table[key] = value
+		}
+
+		if table, ok = value.(map[string]interface{}); !ok {
+			return nil, d.errorf("key `%s' is not a table", key)
+		}
+	}
+
+	return table, nil
+}
+
+// parseKeyValue parses a key = value pair into table.
+func (d *tomlDecoder) parseKeyValue(table map[string]interface{}) error {
+	path, err := d.parseKey()
+
+	if err != nil {
+		return err
+	}
+
+	d.skipSpace(false)
+
+	if d.peek() != '=' {
+		return d.errorf("expected `=' after key")
+	}
+
+	d.pos++
+	d.skipSpace(false)
+
+	value, err := d.parseValue()
+
+	if err != nil {
+		return err
+	}
+
+	if table, err = d.table(table, path[:len(path)-1]); err != nil {
+		return err
+	}
+
+	key := path[len(path)-1]
+
+	if _, ok := table[key]; ok {
+		return d.errorf("duplicate key `%s'", key)
+	}
+
+	table[key] = value
+	return nil
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_'
+}
+
+// parseKey parses a bare, quoted or dotted key.
+func (d *tomlDecoder) parseKey() ([]string, error) {
+	var path []string
+
+	for {
+		var key string
+
+		switch c := d.peek(); {
+		case c == '"' || c == '\'':
+			s, err := d.parseString()
+
+			if err != nil {
+				return nil, err
+			}
+
+			key = s
+		case isTOMLBareKeyChar(c):
+			start := d.pos
+
+			for !d.eof() && isTOMLBareKeyChar(d.peek()) {
+				d.pos++
+			}
+
+			key = d.data[start:d.pos]
+		default:
+			return nil, d.errorf("expected key")
+		}
+
+		path = append(path, key)
+		d.skipSpace(false)
+
+		if d.peek() != '.' {
+			return path, nil
+		}
+
+		d.pos++
+		d.skipSpace(false)
+	}
+}
+
+func (d *tomlDecoder) parseValue() (interface{}, error) {
+	switch d.peek() {
+	case '"', '\'':
+		return d.parseString()
+	case '[':
+		return d.parseArray()
+	case '{':
+		return d.parseInlineTable()
+	}
+
+	return d.parseScalar()
+}
+
+func (d *tomlDecoder) parseArray() (interface{}, error) {
+	ret := make([]interface{}, 0)
+	d.pos++
+
+	for {
+		d.skipSpace(true)
+
+		if d.peek() == ']' {
+			d.pos++
+			return ret, nil
+		}
+
+		value, err := d.parseValue()
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, value)
+		d.skipSpace(true)
+
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case ']':
+		default:
+			return nil, d.errorf("expected `,' or `]' in array")
+		}
+	}
+}
+
+func (d *tomlDecoder) parseInlineTable() (interface{}, error) {
+	ret := make(map[string]interface{})
+	d.pos++
+	d.skipSpace(false)
+
+	if d.peek() == '}' {
+		d.pos++
+		return ret, nil
+	}
+
+	for {
+		d.skipSpace(false)
+
+		if err := d.parseKeyValue(ret); err != nil {
+			return nil, err
+		}
+
+		d.skipSpace(false)
+
+		switch d.peek() {
+		case ',':
+			d.pos++
+		case '}':
+			d.pos++
+			return ret, nil
+		default:
+			return nil, d.errorf("expected `,' or `}' in inline table")
+		}
+	}
+}
+
+// parseScalar parses booleans, numbers and dates.
+func (d *tomlDecoder) parseScalar() (interface{}, error) {
+	start := d.pos
+
+	for !d.eof() && strings.IndexByte(" \t\r\n,]}#", d.peek()) < 0 {
+		d.pos++
+	}
+
+	// Date times may separate the date and time by a space
+	if d.pos-start == 10 && strings.Count(d.data[start:d.pos], "-") == 2 &&
+		d.pos+1 < len(d.data) && d.data[d.pos] == ' ' && d.data[d.pos+1] >= '0' && d.data[d.pos+1] <= '9' {
+		d.pos++
+
+		for !d.eof() && strings.IndexByte(" \t\r\n,]}#", d.peek()) < 0 {
+			d.pos++
+		}
+	}
+
+	s := d.data[start:d.pos]
+
+	switch {
+	case s == "true" || s == "false":
+		return s, nil
+	case s == "":
+		return nil, d.errorf("expected value")
+	}
+
+	// Leading zeros are not allowed, contrary to Go octal literals
+	digits := strings.TrimLeft(s, "+-")
+
+	if len(digits) < 2 || digits[0] != '0' || digits[1] < '0' || digits[1] > '9' {
+		if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+
+		f := strings.Replace(s, "_", "", -1)
+
+		switch strings.TrimLeft(f, "+-") {
+		case "inf", "nan":
+			return strings.Replace(f, "nan", "NaN", 1), nil
+		}
+
+		if _, err := strconv.ParseFloat(f, 64); err == nil {
+			return f, nil
+		}
+	}
+
+	if len(s) >= 8 && (s[2] == ':' || s[4] == '-') {
+		return s, nil
+	}
+
+	return nil, d.errorf("invalid value `%s'", s)
+}
+
+// parseString parses basic, literal and multi-line strings.
+func (d *tomlDecoder) parseString() (string, error) {
+	quote := d.data[d.pos : d.pos+1]
+	multiline := strings.HasPrefix(d.data[d.pos:], strings.Repeat(quote, 3))
+
+	if multiline {
+		quote = strings.Repeat(quote, 3)
+	}
+
+	d.pos += len(quote)
+
+	// Newlines directly after the opening quotes are trimmed
+	if multiline {
+		if strings.HasPrefix(d.data[d.pos:], "\r\n") {
+			d.pos += 2
+		} else if d.peek() == '\n' {
+			d.pos++
+		}
+	}
+
+	start := d.pos
+
+	for {
+		if d.eof() || (!multiline && d.peek() == '\n') {
+			return "", d.errorf("unterminated string")
+		}
+
+		if quote[0] == '"' && d.peek() == '\\' {
+			d.pos += 2
+			continue
+		}
+
+		if strings.HasPrefix(d.data[d.pos:], quote) {
+			break
+		}
+
+		d.pos++
+	}
+
+	s := d.data[start:d.pos]
+	d.pos += len(quote)
+
+	if quote[0] == '\'' {
+		return s, nil
+	}
+
+	ret, err := unescapeTOML(s)
+
+	if err != nil {
+		return "", d.errorf("%s", err)
+	}
+
+	return ret, nil
+}
+
+// unescapeTOML processes the escape sequences of a basic string.
+func unescapeTOML(s string) (string, error) {
+	var ret []byte
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			ret = append(ret, s[i])
+			continue
+		}
+
+		i++
+
+		if i >= len(s) {
+			return "", fmt.Errorf("invalid escape sequence at end of string")
+		}
+
+		switch c := s[i]; c {
+		case 'b':
+			ret = append(ret, '\b')
+		case 't':
+			ret = append(ret, '\t')
+		case 'n':
+			ret = append(ret, '\n')
+		case 'f':
+			ret = append(ret, '\f')
+		case 'r':
+			ret = append(ret, '\r')
+		case '"', '\\':
+			ret = append(ret, c)
+		case 'u', 'U':
+			n := 4
+
+			if c == 'U' {
+				n = 8
+			}
+
+			if i+1+n > len(s) {
+				return "", fmt.Errorf("invalid unicode escape sequence")
+			}
+
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", fmt.Errorf("invalid unicode escape sequence \\%c%s", c, s[i+1:i+1+n])
+			}
+
+			var buf [utf8.UTFMax]byte
+			ret = append(ret, buf[:utf8.EncodeRune(buf[:], rune(r))]...)
+			i += n
+		case ' ', '\t', '\r', '\n':
+			// A line ending backslash trims all white space up to the
+			// next non white space character
+			j := i
+
+			for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+				j++
+			}
+
+			if j < len(s) && s[j] != '\r' && s[j] != '\n' {
+				return "", fmt.Errorf("invalid escape sequence \\%c", c)
+			}
+
+			for j < len(s) && strings.IndexByte(" \t\r\n", s[j]) >= 0 {
+				j++
+			}
+
+			i = j - 1
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c", c)
+		}
+	}
+
+	return string(ret), nil
+}
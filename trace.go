@@ -0,0 +1,94 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// trace reports a parse event to the Trace callback of the parser. When no
+// callback is set and the GO_FLAGS_DEBUG environment variable is not empty,
+// the event is printed to the standard error instead (see Parser.Stderr).
+func (p *Parser) trace(format string, a ...interface{}) {
+	if !p.tracing() {
+		return
+	}
+
+	message := fmt.Sprintf(format, a...)
+
+	if p.Trace != nil {
+		p.Trace(message)
+	} else {
+		fmt.Fprintf(p.stderr(), "go-flags: %s\n", message)
+	}
+}
+
+// warn reports a non-fatal event to the Warning callback of the parser.
+func (p *Parser) warn(err error) {
+	if p.Warning == nil {
+		return
+	}
+
+	e, ok := err.(*Error)
+
+	if !ok {
+		e = newError(ErrUnknown, err.Error()).wrap(err)
+	}
+
+	p.Warning(e)
+}
+
+// warnSet reports warnings about an option which was just set: the use of
+// a deprecated option and options which are not repeatable being set more
+// than once, in which case the earlier values are lost.
+func (p *Parser) warnSet(option *Option) {
+	if p.Warning == nil {
+		return
+	}
+
+	if deprecated := option.options.Get("deprecated"); deprecated != "" {
+		p.warn(newOptionError(ErrDeprecated, option,
+			fmt.Sprintf("flag `%s' is deprecated: %s", option, deprecated)))
+	}
+
+	if option.occurrences > 1 && !option.isRepeatable() && !option.isFunc() && option.value.Kind() != reflect.Bool {
+		p.warn(newOptionError(ErrOverridden, option,
+			fmt.Sprintf("flag `%s' was specified more than once, using the last value", option)))
+	}
+}
+
+// debugEnv returns whether the GO_FLAGS_DEBUG environment variable is set
+// (see Parser.LookupEnv). The variable is only looked up once per parser,
+// since looking it up for every argument is slow for large argument lists.
+func (p *Parser) debugEnv() bool {
+	if p.debug == nil {
+		debug := p.getenv("GO_FLAGS_DEBUG") != ""
+		p.debug = &debug
+	}
+
+	return *p.debug
+}
+
+// tracing returns whether parse events are reported (see trace). It is used
+// to avoid formatting events which are not reported. GO_FLAGS_DEBUG is
+// ignored by isolated parsers (see ParseStrings).
+func (p *Parser) tracing() bool {
+	return p.Trace != nil || (!p.isolated && p.debugEnv())
+}
+
+// traceSet reports that the option was set, including its converted value
+// and the origin of the value.
+func (p *Parser) traceSet(option *Option) {
+	if !p.tracing() {
+		return
+	}
+
+	if option.isFunc() {
+		p.trace("called %s (%s)", option, option.origin)
+	} else {
+		p.trace("set %s to %s (%s)", option, option.argument(convertToString(option.value, option.options)), option.origin)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Validator is implemented by data structs of groups which validate the
+// values of their options as a whole, for example to check invariants which
+// involve multiple options. Validate is called after parsing, when all the
+// values have been set. A returned error which is not of type *Error is
+// reported as an error of type ErrValidation.
+type Validator interface {
+	Validate() error
+}
+
+// validate checks the constraints between options once all the values have
+// been set. It is called at the end of Parser.ParseArgs and
+// Parser.ParseSources.
+// When the CollectErrors option is set, all the errors are returned.
+func (p *Parser) validate() error {
+	var errs Errors
+
+	// report records the error and returns whether to stop validating
+	report := func(err error) bool {
+		if err == nil {
+			return false
+		}
+
+		errs = errs.append(err)
+		return !p.collectError(err)
+	}
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			if report(option.validateRequired()) ||
+				report(p.validateRequires(option)) ||
+				report(p.validateRequiredIf(option)) ||
+				report(option.validateMinOccurrences()) {
+				return errs.err()
+			}
+		}
+	}
+
+	for _, set := range p.atLeastOneOfSets() {
+		if report(set.validate()) {
+			return errs.err()
+		}
+	}
+
+	for _, grp := range p.groups() {
+		validator, ok := grp.data.(Validator)
+
+		if !ok {
+			continue
+		}
+
+		if err := validator.Validate(); err != nil {
+			if _, ok := err.(*Error); !ok {
+				err = newError(ErrValidation, err.Error()).wrap(err)
+			}
+
+			if report(err) {
+				return errs.err()
+			}
+		}
+	}
+
+	if p.StructValidator != nil {
+		for _, grp := range p.groups() {
+			if grp.data == nil {
+				continue
+			}
+
+			if err := p.StructValidator(grp.data); err != nil {
+				if report(grp.structValidationError(err)) {
+					return errs.err()
+				}
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+// FieldError is implemented by errors which refer to a field of a struct by
+// its name, such as the field errors of common struct tag based validation
+// packages. It is used to attribute errors returned by
+// Parser.StructValidator to options.
+type FieldError interface {
+	error
+
+	// The name of the struct field
+	StructField() string
+}
+
+// structValidationError converts an error returned by a struct validator
+// into an error of type ErrValidation. Field errors, including those
+// contained in a slice of errors or wrapped using errors.Join, are reported
+// as errors of the corresponding options.
+func (g *Group) structValidationError(err error) error {
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+
+	var errs []error
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else if val := reflect.ValueOf(err); val.Kind() == reflect.Slice {
+		for i := 0; i < val.Len(); i++ {
+			if e, ok := val.Index(i).Interface().(error); ok {
+				errs = append(errs, e)
+			}
+		}
+	} else {
+		errs = []error{err}
+	}
+
+	messages := make([]string, 0, len(errs))
+
+	for _, e := range errs {
+		var option *Option
+
+		if fe, ok := e.(FieldError); ok {
+			option = g.optionByField(fe.StructField())
+		}
+
+		if option != nil {
+			messages = append(messages, fmt.Sprintf("invalid value for flag `%s': %s", option, e))
+		} else {
+			messages = append(messages, e.Error())
+		}
+	}
+
+	return newError(ErrValidation, strings.Join(messages, "\n")).wrap(err)
+}
+
+// validateRequired checks that a required option is set.
+func (option *Option) validateRequired() error {
+	if !option.Required || option.IsSet() {
+		return nil
+	}
+
+	return newOptionError(ErrRequired, option,
+		fmt.Sprintf("the required flag `%s' was not specified", option))
+}
+
+// validateRequires checks that the options listed in the requires tags of
+// the option are set when the option itself is set.
+func (p *Parser) validateRequires(option *Option) error {
+	if !option.IsSet() {
+		return nil
+	}
+
+	for _, name := range option.options.values("requires") {
+		other := p.FindOptionByLongName(name)
+
+		if other == nil {
+			return newOptionError(ErrUnknownFlag, option,
+				fmt.Sprintf("unknown flag `%s' required by flag `%s'", name, option))
+		}
+
+		if !other.IsSet() {
+			return newOptionError(ErrRequired, option,
+				fmt.Sprintf("flag `%s' requires flag `%s'", option, other))
+		}
+	}
+
+	return nil
+}
+
+// validateRequiredIf checks that the option is set when one of the
+// conditions given by its required-if tags holds. A condition is either the
+// long name of another option, which holds when that option is set, or of
+// the form name=value, which holds when the other option has the given
+// value.
+func (p *Parser) validateRequiredIf(option *Option) error {
+	if option.IsSet() {
+		return nil
+	}
+
+	for _, condition := range option.options.values("required-if") {
+		name := condition
+		var value *string
+
+		if pos := strings.Index(condition, "="); pos >= 0 {
+			name = condition[:pos]
+			v := condition[pos+1:]
+			value = &v
+		}
+
+		other := p.FindOptionByLongName(name)
+
+		if other == nil {
+			return newOptionError(ErrUnknownFlag, option,
+				fmt.Sprintf("unknown flag `%s' in condition of flag `%s'", name, option))
+		}
+
+		if value == nil {
+			if other.IsSet() {
+				return newOptionError(ErrRequired, option,
+					fmt.Sprintf("flag `%s' is required when flag `%s' is specified", option, other))
+			}
+		} else if other.hasValue(*value) {
+			return newOptionError(ErrRequired, option,
+				fmt.Sprintf("flag `%s' is required when flag `%s' is `%s'", option, other, *value))
+		}
+	}
+
+	return nil
+}
+
+// hasValue returns whether the value of the option, or one of its values
+// for slices, equals the given value.
+func (option *Option) hasValue(value string) bool {
+	val := option.value
+
+	if val.Kind() == reflect.Slice {
+		for i := 0; i < val.Len(); i++ {
+			if convertToString(val.Index(i), option.options) == value {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return convertToString(val, option.options) == value
+}
+
+// validateMinOccurrences checks that the option was specified at least as
+// often as given by its min-occurrences tag.
+func (option *Option) validateMinOccurrences() error {
+	min := option.options.Get("min-occurrences")
+
+	if min == "" {
+		return nil
+	}
+
+	if n, err := strconv.Atoi(min); err == nil && option.occurrences < n {
+		return newOptionError(ErrOccurrences, option,
+			fmt.Sprintf("flag `%s' must be specified at least %d times", option, n))
+	}
+
+	return nil
+}
+
+// optionSet is a named set of options of which at least one must be
+// specified, as declared by at-least-one-of tags.
+type optionSet struct {
+	name    string
+	options []*Option
+}
+
+// atLeastOneOfSets returns the sets of options declared by the
+// at-least-one-of tags of the options, in the order in which they are
+// first declared.
+func (p *Parser) atLeastOneOfSets() []*optionSet {
+	var ret []*optionSet
+	sets := make(map[string]*optionSet)
+
+	for _, grp := range p.groups() {
+		for _, option := range grp.Options {
+			for _, name := range option.options.values("at-least-one-of") {
+				set := sets[name]
+
+				if set == nil {
+					set = &optionSet{name: name}
+					sets[name] = set
+					ret = append(ret, set)
+				}
+
+				set.options = append(set.options, option)
+			}
+		}
+	}
+
+	return ret
+}
+
+func (s *optionSet) validate() error {
+	names := make([]string, len(s.options))
+
+	for i, option := range s.options {
+		if option.IsSet() {
+			return nil
+		}
+
+		names[i] = fmt.Sprintf("`%s'", option.synopsis())
+	}
+
+	return newError(ErrRequired,
+		fmt.Sprintf("at least one of the flags %s must be specified", strings.Join(names, ", ")))
+}
+
+// synopsis formats the set for the usage line, e.g. (--file | --url).
+func (s *optionSet) synopsis() string {
+	names := make([]string, len(s.options))
+
+	for i, option := range s.options {
+		names[i] = option.synopsis()
+	}
+
+	return "(" + strings.Join(names, " | ") + ")"
+}
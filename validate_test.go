@@ -0,0 +1,697 @@
+package flags
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validateOptions struct {
+	Name    string `long:"name"`
+	Port    int    `short:"p" long:"port"`
+	Verbose bool   `short:"v" long:"verbose"`
+}
+
+func TestOptionValidators(t *testing.T) {
+	var opts validateOptions
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	p.FindOptionByLongName("name").Validator = func(value string) error {
+		if strings.ToLower(value) != value {
+			return errors.New("must be lower case")
+		}
+
+		return nil
+	}
+
+	var validated []string
+
+	p.OptionValidator = func(option *Option, value string) error {
+		validated = append(validated, option.LongName+"="+value)
+
+		if option.LongName == "port" && value == "0" {
+			return errors.New("must not be zero")
+		}
+
+		return nil
+	}
+
+	if _, err := p.ParseArgs([]string{"--name", "ok", "-v", "-p", "80"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if strings.Join(validated, " ") != "name=ok verbose= port=80" {
+		t.Errorf("Unexpected validated options: %v", validated)
+	}
+
+	tests := []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"--name", "NOK"}, "invalid argument `NOK' for flag `--name': must be lower case"},
+		{[]string{"-p", "0"}, "invalid argument `0' for flag `-p, --port': must not be zero"},
+	}
+
+	for _, test := range tests {
+		_, err := p.ParseArgs(test.args)
+
+		if e, ok := err.(*Error); !ok || e.Type != ErrValidation || e.Message != test.message {
+			t.Errorf("Expected validation error %q for %v but got %v", test.message, test.args, err)
+		}
+	}
+
+	opts = validateOptions{}
+	p.Groups[0] = NewGroup("Application Options", &opts)
+
+	if err := p.ParseYAML(strings.NewReader("port: 0\n")); err == nil || err.Error() != "port: invalid argument `0' for flag `-p, --port': must not be zero" {
+		t.Errorf("Expected validation error for configuration file but got %v", err)
+	}
+}
+
+func TestRange(t *testing.T) {
+	var opts struct {
+		Port   uint16        `long:"port" description:"Port" min:"1" max:"65535"`
+		Levels []int         `long:"level" description:"Level" min:"-1"`
+		Ratio  float64       `long:"ratio" max:"0.5"`
+		Delay  time.Duration `long:"delay" min:"1s"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--port", "80", "--level", "-1", "--ratio", "0.5", "--delay", "1m30s"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Delay != 90*time.Second {
+		t.Errorf("Expected delay 1m30s but got %s", opts.Delay)
+	}
+
+	tests := []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"--port", "0"}, "invalid argument for flag `--port' (expected a value between 1 and 65535)"},
+		{[]string{"--level", "-2"}, "invalid argument for flag `--level' (expected a value of at least -1)"},
+		{[]string{"--ratio", "0.75"}, "invalid argument for flag `--ratio' (expected a value of at most 0.5)"},
+		{[]string{"--delay", "500ms"}, "invalid argument for flag `--delay' (expected a value of at least 1s)"},
+	}
+
+	for _, test := range tests {
+		_, err := p.ParseArgs(test.args)
+
+		if e, ok := err.(*Error); !ok || e.Type != ErrOutOfRange || e.Message != test.message {
+			t.Errorf("Expected range error %q for %v but got %v", test.message, test.args, err)
+		}
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if !strings.Contains(b.String(), "Port (80) (between 1 and 65535)") {
+		t.Errorf("Expected range in help but got:\n%s", b.String())
+	}
+}
+
+func TestInvalidRange(t *testing.T) {
+	tests := []struct {
+		data    interface{}
+		message string
+	}{
+		{&struct {
+			Port uint `long:"port" min:"-1"`
+		}{}, "invalid range for field `Port': min `-1' is not a valid uint"},
+		{&struct {
+			Level int `long:"level" min:"abc"`
+		}{}, "invalid range for field `Level': min `abc' is not a valid int"},
+		{&struct {
+			Port uint16 `long:"port" max:"70000"`
+		}{}, "invalid range for field `Port': max `70000' is not a valid uint16"},
+		{&struct {
+			Ratios []float64 `long:"ratio" max:"x"`
+		}{}, "invalid range for field `Ratios': max `x' is not a valid float64"},
+		{&struct {
+			Name string `long:"name" min:"1"`
+		}{}, "invalid range for field `Name': min and max are not supported for values of type string"},
+		{&struct {
+			Delay time.Duration `long:"delay" min:"1"`
+		}{}, "invalid range for field `Delay': min `1' is not a valid time.Duration"},
+	}
+
+	for _, test := range tests {
+		if grp := NewGroup("Application Options", test.data); grp.Error == nil || grp.Error.Error() != test.message {
+			t.Errorf("Expected error %q but got %v", test.message, grp.Error)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	var opts struct {
+		Name  string   `long:"name" pattern:"^[a-z][a-z0-9-]*$"`
+		Hosts []string `long:"host" pattern:"^[a-z.]+$"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--name", "my-name2", "--host", "example.com"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err := p.ParseArgs([]string{"--host", "a", "--host", "B"})
+	message := "invalid argument `B' for flag `--host' (expected to match ^[a-z.]+$)"
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrValidation || e.Message != message {
+		t.Errorf("Expected validation error %q but got %v", message, err)
+	}
+
+	var invalid struct {
+		Name string `long:"name" pattern:"("`
+	}
+
+	if grp := NewGroup("Application Options", &invalid); grp.Error == nil {
+		t.Errorf("Expected error for invalid pattern")
+	}
+}
+
+func TestRequires(t *testing.T) {
+	var opts struct {
+		Key  string `long:"key" requires:"cert"`
+		Cert string `long:"cert"`
+		CA   string `long:"ca" requires:"cert" requires:"key"`
+	}
+
+	newParser := func() *Parser {
+		return NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	}
+
+	if _, err := newParser().ParseArgs([]string{"--cert", "c", "--key", "k"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"--key", "k"}, "flag `--key' requires flag `--cert'"},
+		{[]string{"--ca", "a", "--cert", "c"}, "flag `--ca' requires flag `--key'"},
+	}
+
+	for _, test := range tests {
+		_, err := newParser().ParseArgs(test.args)
+
+		if e, ok := err.(*Error); !ok || e.Type != ErrRequired || e.Message != test.message {
+			t.Errorf("Expected error %q for %v but got %v", test.message, test.args, err)
+		}
+	}
+
+	// The prerequisite may be provided by another source
+	p := newParser()
+
+	if _, err := p.ParseSources(CommandLineSource([]string{"--key", "k"}), CommandLineSource([]string{"--cert", "c"})); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+type validatedOptions struct {
+	Min int `long:"min"`
+	Max int `long:"max"`
+}
+
+func (o *validatedOptions) Validate() error {
+	if o.Min > o.Max {
+		return errors.New("min must not be larger than max")
+	}
+
+	return nil
+}
+
+func TestGroupValidator(t *testing.T) {
+	var opts validatedOptions
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"--min", "1", "--max", "2"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err := p.ParseArgs([]string{"--min", "3"})
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrValidation || e.Message != "min must not be larger than max" {
+		t.Errorf("Expected validation error but got %v", err)
+	}
+}
+
+func TestRequiredIf(t *testing.T) {
+	var opts struct {
+		Mode   string   `long:"mode"`
+		Cert   string   `long:"cert" required-if:"mode=tls"`
+		Output string   `long:"output" required-if:"format"`
+		Format []string `long:"format"`
+		Debug  bool     `long:"debug" required-if:"format=trace"`
+	}
+
+	newParser := func() *Parser {
+		return NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	}
+
+	if _, err := newParser().ParseArgs([]string{"--mode", "plain"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"--mode", "tls"}, "flag `--cert' is required when flag `--mode' is `tls'"},
+		{[]string{"--format", "json"}, "flag `--output' is required when flag `--format' is specified"},
+		{[]string{"--output", "o", "--format", "json", "--format", "trace"}, "flag `--debug' is required when flag `--format' is `trace'"},
+	}
+
+	for _, test := range tests {
+		opts.Mode = ""
+		opts.Format = nil
+
+		_, err := newParser().ParseArgs(test.args)
+
+		if e, ok := err.(*Error); !ok || e.Type != ErrRequired || e.Message != test.message {
+			t.Errorf("Expected error %q for %v but got %v", test.message, test.args, err)
+		}
+	}
+}
+
+func TestAtLeastOneOf(t *testing.T) {
+	var opts struct {
+		File  string `long:"file" at-least-one-of:"input"`
+		URL   string `long:"url" at-least-one-of:"input"`
+		Stdin bool   `short:"s" at-least-one-of:"input"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	if _, err := p.ParseArgs([]string{"-s"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	p = NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	_, err := p.ParseArgs(nil)
+	message := "at least one of the flags `--file', `--url', `-s' must be specified"
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrRequired || e.Message != message {
+		t.Errorf("Expected error %q but got %v", message, err)
+	}
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	if !strings.HasPrefix(b.String(), "Usage:\n  test [OPTIONS] (--file | --url | -s)\n") {
+		t.Errorf("Expected option set in usage but got:\n%s", b.String())
+	}
+}
+
+type testFieldError struct {
+	field string
+}
+
+func (e testFieldError) Error() string {
+	return "failed on the 'min' tag"
+}
+
+func (e testFieldError) StructField() string {
+	return e.field
+}
+
+type testFieldErrors []testFieldError
+
+func (e testFieldErrors) Error() string {
+	return "validation failed"
+}
+
+func TestStructValidator(t *testing.T) {
+	var opts validateOptions
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	p.StructValidator = func(data interface{}) error {
+		o := data.(*validateOptions)
+
+		if o.Port < 1024 {
+			return testFieldErrors{{"Port"}, {"Other"}}
+		}
+
+		return nil
+	}
+
+	if _, err := p.ParseArgs([]string{"-p", "8080"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err := p.ParseArgs([]string{"-p", "80"})
+	message := "invalid value for flag `-p, --port': failed on the 'min' tag\nfailed on the 'min' tag"
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrValidation || e.Message != message {
+		t.Errorf("Expected error %q but got %v", message, err)
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	var opts struct {
+		Verbose []bool   `short:"v" max-occurrences:"3"`
+		Hosts   []string `long:"host" min-occurrences:"2"`
+	}
+
+	newParser := func() *Parser {
+		opts.Verbose = nil
+		opts.Hosts = nil
+
+		return NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	}
+
+	if _, err := newParser().ParseArgs([]string{"-vvv", "--host", "a", "--host", "b"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"-vvvv", "--host", "a", "--host", "b"}, "flag `-v' cannot be specified more than 3 times"},
+		{[]string{"--host", "a"}, "flag `--host' must be specified at least 2 times"},
+	}
+
+	for _, test := range tests {
+		_, err := newParser().ParseArgs(test.args)
+
+		if e, ok := err.(*Error); !ok || e.Type != ErrOccurrences || e.Message != test.message {
+			t.Errorf("Expected error %q for %v but got %v", test.message, test.args, err)
+		}
+	}
+
+	var limited struct {
+		Level int `long:"level" pattern:"^[0-9]$" max-occurrences:"1"`
+	}
+
+	p := NewNamedParser("test", CollectErrors, NewGroup("Application Options", &limited))
+
+	// Rejected values are not counted
+	if _, err := p.ParseArgs([]string{"--level", "10", "--level", "1"}); err == nil || strings.Contains(err.Error(), "more than") || limited.Level != 1 {
+		t.Errorf("Expected only a pattern error but got %v", err)
+	}
+
+	if _, err := p.ParseArgs([]string{"--level", "1"}); err != nil || limited.Level != 1 {
+		t.Errorf("Unexpected result: %v, %+v", err, limited)
+	}
+
+	if _, err := p.ParseArgs([]string{"--level", "2"}); err != nil || limited.Level != 2 {
+		t.Errorf("Expected occurrences to be counted for each parse but got %v", err)
+	}
+
+	if _, err := p.ParseSources(CommandLineSource([]string{"--level", "3"})); err != nil {
+		t.Errorf("Expected occurrences to be counted for each parse but got %v", err)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	var opts struct {
+		Name string `long:"name" required:"true"`
+	}
+
+	newParser := func() *Parser {
+		return NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	}
+
+	if _, err := newParser().ParseArgs([]string{"--name", "n"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err := newParser().ParseArgs(nil)
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrRequired || e.Message != "the required flag `--name' was not specified" {
+		t.Errorf("Expected a required error but got %v", err)
+	}
+}
+
+func TestRequiredUsage(t *testing.T) {
+	var opts struct {
+		Name    string `long:"name" value-name:"NAME" required:"true"`
+		Level   int    `short:"l" required:"true"`
+		Color   string `long:"color" optional:"true" default:"always" required:"true"`
+		Force   bool   `long:"force" required:"true"`
+		Verbose bool   `short:"v" long:"verbose"`
+		JSON    bool   `long:"json" at-least-one-of:"format"`
+		YAML    bool   `long:"yaml" at-least-one-of:"format"`
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	var b bytes.Buffer
+	p.WriteHelp(&b)
+
+	expected := "Usage:\n  test [OPTIONS] --name=NAME -l VALUE --color[=VALUE] --force (--json | --yaml)\n"
+
+	if !strings.HasPrefix(b.String(), expected) {
+		t.Errorf("Expected usage:\n%s\nbut got:\n%s", expected, b.String())
+	}
+}
+
+func TestPromptRequired(t *testing.T) {
+	defer func(input io.Reader, output io.Writer, terminal func() bool) {
+		promptInput, promptOutput, promptTerminal = input, output, terminal
+	}(promptInput, promptOutput, promptTerminal)
+
+	var opts struct {
+		Name string `long:"name" description:"Your name" required:"true"`
+		Port int    `long:"port" default:"80" required:"true"`
+		Host string `long:"host"`
+	}
+
+	var output bytes.Buffer
+
+	promptInput = strings.NewReader("\nJane\nx\n\n")
+	promptOutput = &output
+	promptTerminal = func() bool { return true }
+
+	newParser := func() *Parser {
+		return NewNamedParser("test", PromptRequired, NewGroup("Application Options", &opts))
+	}
+
+	p := newParser()
+
+	if _, err := p.ParseArgs(nil); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Name != "Jane" || opts.Port != 80 {
+		t.Errorf("Expected the prompted values but got %+v", opts)
+	}
+
+	if origin := p.Groups[0].LongNames["name"].Origin(); origin.Type != OriginPrompt {
+		t.Errorf("Expected the name to originate from the prompt but got %s", origin)
+	}
+
+	expected := "Your name (--name): Your name (--name): --port [80]: " +
+		"Flags error: invalid argument for flag `--port' (expected int)\n--port [80]: "
+
+	if output.String() != expected {
+		t.Errorf("Expected prompts %q but got %q", expected, output.String())
+	}
+
+	// Options which are still missing are reported at the end of the input
+	promptInput = strings.NewReader("")
+
+	_, err := newParser().ParseArgs([]string{"--port", "1"})
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrRequired {
+		t.Errorf("Expected a required error but got %v", err)
+	}
+
+	// Nothing is prompted when the input is not a terminal
+	promptTerminal = func() bool { return false }
+	output.Reset()
+
+	if _, err := newParser().ParseArgs(nil); err == nil || output.Len() != 0 {
+		t.Errorf("Expected an error without prompting but got %v and %q", err, output.String())
+	}
+}
+
+func TestPromptSecret(t *testing.T) {
+	defer func(input io.Reader, output io.Writer, terminal func() bool, disable func() (func(), error)) {
+		promptInput, promptOutput, promptTerminal, promptDisableEcho = input, output, terminal, disable
+	}(promptInput, promptOutput, promptTerminal, promptDisableEcho)
+
+	var opts struct {
+		Password string `long:"password" description:"The password" default:"changeme" prompt:"secure" pattern:"^[a-z]+$"`
+		User     string `long:"user" prompt:"true"`
+	}
+
+	var output bytes.Buffer
+	echo := true
+
+	promptInput = strings.NewReader("s3cret\nsecret\njane\n")
+	promptOutput = &output
+	promptTerminal = func() bool { return true }
+	promptDisableEcho = func() (func(), error) {
+		echo = false
+		return func() { echo = true }, nil
+	}
+
+	p := NewNamedParser("test", None, NewGroup("Application Options", &opts))
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+
+	if strings.Contains(help.String(), "changeme") {
+		t.Errorf("Expected the default of the secret option not to be shown but got:\n%s", help.String())
+	}
+
+	if _, err := p.ParseArgs(nil); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Password != "secret" || opts.User != "jane" || !echo {
+		t.Errorf("Expected the prompted values but got %+v", opts)
+	}
+
+	expected := "The password (--password): \nFlags error: invalid argument `***' for flag `--password' (expected to match ^[a-z]+$)\n" +
+		"The password (--password): \n--user: "
+
+	if output.String() != expected {
+		t.Errorf("Expected prompts %q but got %q", expected, output.String())
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	defer func(input io.Reader, output io.Writer, terminal func() bool) {
+		promptInput, promptOutput, promptTerminal = input, output, terminal
+	}(promptInput, promptOutput, promptTerminal)
+
+	var opts struct {
+		Yes Confirmation `short:"y" long:"yes"`
+	}
+
+	var output bytes.Buffer
+	promptOutput = &output
+
+	newParser := func() *Parser {
+		opts.Yes = false
+		return NewNamedParser("test", None, NewGroup("Application Options", &opts))
+	}
+
+	p := newParser()
+
+	if _, err := p.ParseArgs([]string{"-y"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if err := p.Confirm(&opts.Yes, "Delete?"); err != nil {
+		t.Errorf("Expected the flag to confirm the action but got %v", err)
+	}
+
+	// Without a terminal, the action must be confirmed using the flag
+	promptTerminal = func() bool { return false }
+	p = newParser()
+
+	err := p.Confirm(&opts.Yes, "Delete?")
+
+	if e, ok := err.(*Error); !ok || e.Type != ErrConfirmation || e.Option != p.Groups[0].LongNames["yes"] ||
+		e.Message != "confirmation required (specify flag `-y, --yes')" {
+		t.Errorf("Expected a confirmation error but got %v", err)
+	}
+
+	promptTerminal = func() bool { return true }
+
+	for _, test := range []struct {
+		answer string
+		ok     bool
+	}{
+		{"yes\n", true},
+		{"Y\n", true},
+		{"\n", false},
+		{"", false},
+	} {
+		promptInput = strings.NewReader(test.answer)
+		output.Reset()
+
+		err := newParser().Confirm(&opts.Yes, "Delete?")
+
+		if (err == nil) != test.ok || !strings.HasPrefix(output.String(), "Delete? [y/N]: ") {
+			t.Errorf("Expected %q to confirm the action: %v, but got %v (%q)", test.answer, test.ok, err, output.String())
+		}
+	}
+
+	p = newParser()
+	p.Confirmer = func(question string) (bool, error) {
+		return question == "Delete?", nil
+	}
+
+	if err := p.Confirm(&opts.Yes, "Delete?"); err != nil {
+		t.Errorf("Expected the confirmer to confirm the action but got %v", err)
+	}
+
+	if err := p.Confirm(&opts.Yes, "Format?"); !errors.Is(err, ErrConfirmation) {
+		t.Errorf("Expected the confirmer to decline the action but got %v", err)
+	}
+}
+
+func TestStdio(t *testing.T) {
+	defer func(terminal func() bool, disable func() (func(), error)) {
+		promptTerminal, promptDisableEcho = terminal, disable
+	}(promptTerminal, promptDisableEcho)
+
+	// The standard streams of the process are not used
+	promptTerminal = func() bool { return false }
+	promptDisableEcho = func() (func(), error) {
+		t.Errorf("Expected the echo of the process not to be disabled")
+		return func() {}, nil
+	}
+
+	var opts struct {
+		Name     string       `long:"name" required:"true"`
+		Password string       `long:"password" prompt:"secure"`
+		Port     int          `long:"port" env:"PORT"`
+		Dir      string       `long:"dir" env:"DIR" expand:"env,home"`
+		Yes      Confirmation `long:"yes"`
+	}
+
+	env := map[string]string{"PORT": "8080", "DIR": "~/$SUB", "SUB": "data", "HOME": "/home/jane"}
+
+	var stdout, stderr bytes.Buffer
+
+	p := NewNamedParser("test", HelpFlag|HelpNoError|PrintErrors|PromptRequired, NewGroup("Application Options", &opts))
+	p.Stdin = strings.NewReader("jane\ns3cret\ny\n")
+	p.Stdout = &stdout
+	p.Stderr = &stderr
+	p.LookupEnv = func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	if _, err := p.ParseSources(CommandLineSource(nil), EnvironmentSource()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if opts.Name != "jane" || opts.Password != "s3cret" || opts.Port != 8080 || opts.Dir != "/home/jane/data" {
+		t.Errorf("Expected the values of the streams and environment but got %+v", opts)
+	}
+
+	if err := p.Confirm(&opts.Yes, "Continue?"); err != nil {
+		t.Errorf("Expected the action to be confirmed but got %v", err)
+	}
+
+	if expected := "--name: --password: Continue? [y/N]: "; stderr.String() != expected {
+		t.Errorf("Expected prompts %q but got %q", expected, stderr.String())
+	}
+
+	if _, err := p.ParseArgs([]string{"--help"}); err != nil || !strings.Contains(stdout.String(), "Usage:") {
+		t.Errorf("Expected the help message on the standard output but got %v and %q", err, stdout.String())
+	}
+
+	stderr.Reset()
+
+	if _, err := p.ParseArgs([]string{"--port", "x"}); err == nil || !strings.Contains(stderr.String(), "invalid argument for flag `--port'") {
+		t.Errorf("Expected the error on the standard error but got %v and %q", err, stderr.String())
+	}
+}
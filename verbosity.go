@@ -0,0 +1,53 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"log/slog"
+)
+
+// Verbosity provides the standard -v/--verbose and -q/--quiet options,
+// such that tools handle verbosity consistently. The verbose option can be
+// specified more than once to increase the verbosity. Verbosity is embedded
+// in the options of a tool, or added as a group of its own:
+//
+//	type Options struct {
+//		flags.Verbosity
+//
+//		Output string `short:"o" long:"output" description:"Output file"`
+//	}
+//
+//	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+//		Level: opts.LogLevel(),
+//	}))
+type Verbosity struct {
+	Verbose []bool `short:"v" long:"verbose" description:"Show more output, specify more than once to show more"`
+	Quiet   bool   `short:"q" long:"quiet" description:"Only show errors"`
+}
+
+// Level returns the resolved verbosity: -1 when the quiet option is set,
+// which takes precedence over the verbose option, and otherwise the number
+// of times the verbose option was specified.
+func (v *Verbosity) Level() int {
+	if v.Quiet {
+		return -1
+	}
+
+	return len(v.Verbose)
+}
+
+// LogLevel returns the log level corresponding to the verbosity: errors
+// only when quiet, informational messages by default, debug messages when
+// verbose, and lower levels each time the verbose option is repeated.
+func (v *Verbosity) LogLevel() slog.Level {
+	switch level := v.Level(); {
+	case level < 0:
+		return slog.LevelError
+	case level == 0:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug - slog.Level(4*(level-1))
+	}
+}
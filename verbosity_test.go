@@ -0,0 +1,39 @@
+package flags
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestVerbosity(t *testing.T) {
+	tests := []struct {
+		args     []string
+		level    int
+		logLevel slog.Level
+	}{
+		{[]string{}, 0, slog.LevelInfo},
+		{[]string{"-v"}, 1, slog.LevelDebug},
+		{[]string{"-vvv"}, 3, slog.LevelDebug - 8},
+		{[]string{"--quiet", "-v"}, -1, slog.LevelError},
+	}
+
+	for _, test := range tests {
+		var opts struct {
+			Verbosity
+
+			Name string `long:"name"`
+		}
+
+		if _, err := ParseStrings(&opts, test.args); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if level := opts.Level(); level != test.level {
+			t.Errorf("Expected verbosity %d for %v but got %d", test.level, test.args, level)
+		}
+
+		if level := opts.LogLevel(); level != test.logLevel {
+			t.Errorf("Expected log level %s for %v but got %s", test.logLevel, test.args, level)
+		}
+	}
+}
@@ -0,0 +1,513 @@
+// Copyright 2012 Jesse van den Kieboom. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flags
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseYAMLFile reads option values from a YAML file. See Parser.ParseYAML
+// for more information.
+func (p *Parser) ParseYAMLFile(filename string) error {
+	fp, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer fp.Close()
+
+	return p.parseYAML(fp, filename)
+}
+
+// ParseYAML reads option values from a YAML document. The structure of the
+// document mirrors the option groups of the parser: top level keys are
+// group names (case insensitive) mapping to the option values of the group,
+// or long option names which are looked up in all groups. For example:
+//
+//	Application Options:
+//	    verbose: true
+//	    include:
+//	        - /usr/include
+//	        - /usr/local/include
+//
+// Sequences set options which can be specified more than once (e.g. slices)
+// once for each element, and mappings set map options. The values of
+// options which were already specified on the command line are not changed,
+// i.e. the YAML document is merged beneath the command line. To achieve
+// this, call ParseYAML after parsing the command line arguments.
+//
+// A commonly used subset of YAML is supported: block and flow mappings and
+// sequences, plain and quoted scalars and comments. Anchors, aliases, tags,
+// block scalars and multiple documents are not supported. The returned
+// error is of type *ConfigError when the document could not be parsed or an
+// option could not be set.
+func (p *Parser) ParseYAML(reader io.Reader) error {
+	return p.parseYAML(reader, "")
+}
+
+func (p *Parser) parseYAML(reader io.Reader, filename string) error {
+	values, err := decodeYAML(reader, filename)
+
+	if err != nil {
+		return err
+	}
+
+	return p.applyConfig(values, filename)
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+	lineno uint
+}
+
+type yamlDecoder struct {
+	lines    []yamlLine
+	pos      int
+	filename string
+}
+
+func decodeYAML(reader io.Reader, filename string) (map[string]interface{}, error) {
+	d := &yamlDecoder{
+		filename: filename,
+	}
+
+	if err := d.readLines(reader); err != nil {
+		return nil, err
+	}
+
+	if len(d.lines) == 0 {
+		return make(map[string]interface{}), nil
+	}
+
+	value, err := d.parseBlock(d.lines[0].indent)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos < len(d.lines) {
+		return nil, d.errorf(d.lines[d.pos].lineno, "unexpected indentation")
+	}
+
+	m, ok := value.(map[string]interface{})
+
+	if !ok {
+		return nil, d.errorf(d.lines[0].lineno, "expected a mapping at the top level")
+	}
+
+	return m, nil
+}
+
+func (d *yamlDecoder) errorf(lineno uint, format string, a ...interface{}) error {
+	return &ConfigError{
+		Message:    fmt.Sprintf(format, a...),
+		File:       d.filename,
+		LineNumber: lineno,
+	}
+}
+
+// readLines reads all the lines of the document, stripping comments and
+// blank lines.
+func (d *yamlDecoder) readLines(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	var lineno uint
+
+	for scanner.Scan() {
+		lineno++
+
+		line := stripYAMLComment(scanner.Text())
+		text := strings.TrimLeft(line, " ")
+
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "\t") {
+			return d.errorf(lineno, "tabs cannot be used for indentation")
+		}
+
+		if text == "---" || strings.HasPrefix(text, "--- ") {
+			if len(d.lines) != 0 {
+				return d.errorf(lineno, "multiple documents are not supported")
+			}
+
+			continue
+		}
+
+		if text == "..." {
+			break
+		}
+
+		d.lines = append(d.lines, yamlLine{
+			indent: len(line) - len(text),
+			text:   text,
+			lineno: lineno,
+		})
+	}
+
+	return scanner.Err()
+}
+
+// stripYAMLComment removes a trailing comment and trailing white space from
+// line. A comment starts with a # at the start of the line or after white
+// space, outside of quoted strings.
+func stripYAMLComment(line string) string {
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" \t:,[{-", line[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return strings.TrimRight(line[:i], " \t")
+			}
+		}
+	}
+
+	return strings.TrimRight(line, " \t")
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits a "key: value" line into its key and the (possibly
+// empty) remaining value.
+func splitYAMLKey(text string) (string, string, bool) {
+	var key string
+	rest := text
+
+	if len(text) > 0 && (text[0] == '"' || text[0] == '\'') {
+		end := quotedYAMLEnd(text)
+
+		if end < 0 {
+			return "", "", false
+		}
+
+		unquoted, err := unquoteYAML(text[:end])
+
+		if err != nil {
+			return "", "", false
+		}
+
+		key = unquoted
+		rest = text[end:]
+
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+
+		rest = rest[1:]
+	} else {
+		if len(text) > 0 && strings.IndexByte("[{&*!|>", text[0]) >= 0 {
+			return "", "", false
+		}
+
+		pos := strings.Index(text, ": ")
+
+		if pos < 0 {
+			if !strings.HasSuffix(text, ":") {
+				return "", "", false
+			}
+
+			pos = len(text) - 1
+		}
+
+		key = strings.TrimSpace(text[:pos])
+		rest = text[pos+1:]
+	}
+
+	if rest != "" && rest[0] != ' ' {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(rest), true
+}
+
+// quotedYAMLEnd returns the index just after the closing quote of the quoted
+// string at the start of s, or -1 if the string is not terminated.
+func quotedYAMLEnd(s string) int {
+	quote := s[0]
+
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+func unquoteYAML(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+
+	return strconv.Unquote(s)
+}
+
+func (d *yamlDecoder) parseBlock(indent int) (interface{}, error) {
+	if isYAMLSequenceItem(d.lines[d.pos].text) {
+		return d.parseSequence(indent)
+	}
+
+	return d.parseMapping(indent)
+}
+
+func (d *yamlDecoder) parseMapping(indent int) (interface{}, error) {
+	ret := make(map[string]interface{})
+
+	for d.pos < len(d.lines) {
+		line := d.lines[d.pos]
+
+		if line.indent < indent || (line.indent == indent && isYAMLSequenceItem(line.text)) {
+			break
+		}
+
+		if line.indent > indent {
+			return nil, d.errorf(line.lineno, "unexpected indentation")
+		}
+
+		key, rest, ok := splitYAMLKey(line.text)
+
+		if !ok {
+			return nil, d.errorf(line.lineno, "expected `key: value'")
+		}
+
+		if _, ok := ret[key]; ok {
+			return nil, d.errorf(line.lineno, "duplicate key `%s'", key)
+		}
+
+		d.pos++
+
+		var value interface{}
+		var err error
+
+		if rest != "" {
+			value, err = d.parseInline(rest, line.lineno)
+		} else if d.pos < len(d.lines) {
+			next := d.lines[d.pos]
+
+			if next.indent > indent {
+				value, err = d.parseBlock(next.indent)
+			} else if next.indent == indent && isYAMLSequenceItem(next.text) {
+				value, err = d.parseSequence(indent)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret[key] = value
+	}
+
+	return ret, nil
+}
+
+func (d *yamlDecoder) parseSequence(indent int) (interface{}, error) {
+	ret := make([]interface{}, 0)
+
+	for d.pos < len(d.lines) {
+		line := d.lines[d.pos]
+
+		if line.indent != indent || !isYAMLSequenceItem(line.text) {
+			if line.indent > indent {
+				return nil, d.errorf(line.lineno, "unexpected indentation")
+			}
+
+			break
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+
+		var value interface{}
+		var err error
+
+		if rest == "" {
+			d.pos++
+
+			if d.pos < len(d.lines) && d.lines[d.pos].indent > indent {
+				value, err = d.parseBlock(d.lines[d.pos].indent)
+			}
+		} else if _, _, ok := splitYAMLKey(rest); ok || isYAMLSequenceItem(rest) {
+			// The item is a nested block starting on the same line,
+			// continue parsing it as if it started on its own line
+			itemIndent := indent + len(line.text) - len(rest)
+
+			d.lines[d.pos] = yamlLine{
+				indent: itemIndent,
+				text:   rest,
+				lineno: line.lineno,
+			}
+
+			value, err = d.parseBlock(itemIndent)
+		} else {
+			d.pos++
+			value, err = d.parseInline(rest, line.lineno)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, value)
+	}
+
+	return ret, nil
+}
+
+// parseInline parses a value which is specified on a single line.
+func (d *yamlDecoder) parseInline(text string, lineno uint) (interface{}, error) {
+	if strings.IndexByte("&*!|>", text[0]) >= 0 {
+		return nil, d.errorf(lineno, "unsupported YAML syntax `%s'", text)
+	}
+
+	value, rest, err := d.parseFlow(text, lineno, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(rest) != "" {
+		return nil, d.errorf(lineno, "unexpected `%s' after value", strings.TrimSpace(rest))
+	}
+
+	return value, nil
+}
+
+// parseFlow parses a scalar, flow sequence or flow mapping at the start of
+// text and returns it together with the remaining text. Inside of flow
+// collections, plain scalars end at a flow indicator.
+func (d *yamlDecoder) parseFlow(text string, lineno uint, inFlow bool) (interface{}, string, error) {
+	text = strings.TrimLeft(text, " ")
+
+	if text == "" {
+		return nil, "", d.errorf(lineno, "unexpected end of line")
+	}
+
+	switch text[0] {
+	case '[':
+		ret := make([]interface{}, 0)
+		text = strings.TrimLeft(text[1:], " ")
+
+		for !strings.HasPrefix(text, "]") {
+			value, rest, err := d.parseFlow(text, lineno, true)
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			ret = append(ret, value)
+			text = strings.TrimLeft(rest, " ")
+
+			if strings.HasPrefix(text, ",") {
+				text = strings.TrimLeft(text[1:], " ")
+			} else if !strings.HasPrefix(text, "]") {
+				return nil, "", d.errorf(lineno, "expected `,' or `]' in flow sequence")
+			}
+		}
+
+		return ret, text[1:], nil
+	case '{':
+		ret := make(map[string]interface{})
+		text = strings.TrimLeft(text[1:], " ")
+
+		for !strings.HasPrefix(text, "}") {
+			key, rest, err := d.parseFlow(text, lineno, true)
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			rest = strings.TrimLeft(rest, " ")
+
+			if !strings.HasPrefix(rest, ":") {
+				return nil, "", d.errorf(lineno, "expected `:' in flow mapping")
+			}
+
+			value, rest, err := d.parseFlow(rest[1:], lineno, true)
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			ret[configString(key)] = value
+			text = strings.TrimLeft(rest, " ")
+
+			if strings.HasPrefix(text, ",") {
+				text = strings.TrimLeft(text[1:], " ")
+			} else if !strings.HasPrefix(text, "}") {
+				return nil, "", d.errorf(lineno, "expected `,' or `}' in flow mapping")
+			}
+		}
+
+		return ret, text[1:], nil
+	case '"', '\'':
+		end := quotedYAMLEnd(text)
+
+		if end < 0 {
+			return nil, "", d.errorf(lineno, "unterminated quoted string")
+		}
+
+		value, err := unquoteYAML(text[:end])
+
+		if err != nil {
+			return nil, "", d.errorf(lineno, "invalid quoted string %s", text[:end])
+		}
+
+		return value, text[end:], nil
+	}
+
+	end := len(text)
+
+	if inFlow {
+		if pos := strings.IndexAny(text, ",[]{}"); pos >= 0 {
+			end = pos
+		}
+
+		// A colon followed by a space separates a key from its value
+		if pos := strings.Index(text[:end], ": "); pos >= 0 {
+			end = pos
+		} else if strings.HasSuffix(text[:end], ":") {
+			end--
+		}
+	}
+
+	value := strings.TrimSpace(text[:end])
+
+	switch value {
+	case "~", "null", "Null", "NULL":
+		return nil, text[end:], nil
+	}
+
+	return value, text[end:], nil
+}